@@ -3,39 +3,344 @@
 package config
 
 import (
-	"sync"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 )
 
+const (
+	// TransportSSH runs PowerShell on a remote Windows server over SSH.
+	TransportSSH = "ssh"
+	// TransportLocal runs PowerShell in a local pwsh or powershell.exe process.
+	TransportLocal = "local"
+	// TransportWinRM runs PowerShell on a remote Windows server over WinRM.
+	TransportWinRM = "winrm"
+)
+
+// sshTimeout is the timeout of connecting to an SSH server, including the handshake. It is a variable so tests can
+// shorten it.
+var sshTimeout = goph.DefaultTimeout
+
 type Settings struct {
-	SshUsername string
-	SshPassword string
-	SshHostname string
-	DnsServer   string
-	Version     string
+	Transport                string
+	LocalPowerShellPath      string
+	SshUsername              string
+	SshPassword              string
+	SshPrivateKey            string
+	SshPrivateKeyPath        string
+	SshPrivateKeyPassphrase  string
+	SshUseAgent              bool
+	SshKnownHostsFile        string
+	SshHostKey               string
+	SshStrictHostKeyChecking string
+	SshHostname              string
+	SshPort                  int
+	SshPersistentSession     bool
+	SshMaxConnections        int
+	SshIdleTimeout           int
+	SshKeepAliveInterval     int
+	Bastion                  *BastionSettings
+	WinrmHostname            string
+	WinrmUsername            string
+	WinrmPassword            string
+	WinrmPort                int
+	WinrmUseHTTPS            bool
+	WinrmInsecure            bool
+	WinrmCACert              string
+	WinrmUseNTLM             bool
+	WinrmUseKerberos         bool
+	WinrmKerberosRealm       string
+	WinrmKerberosConfig      string
+	WinrmKerberosSPN         string
+	WinrmKerberosCCache      string
+	DnsServer                string
+	MaxRetries               int
+	RetryMaxWait             int
+	Version                  string
+}
+
+// BastionSettings configures an SSH bastion host the connection to ssh_hostname is tunneled through.
+type BastionSettings struct {
+	Hostname             string
+	Port                 int
+	Username             string
+	Password             string
+	PrivateKey           string
+	PrivateKeyPath       string
+	PrivateKeyPassphrase string
+	UseAgent             bool
+	HostKey              string
 }
 
 func NewConfig(d *schema.ResourceData) (*Settings, error) {
+	transport := d.Get("transport").(string)
+	localPowerShellPath := d.Get("local_powershell_path").(string)
 	sshUsername := d.Get("ssh_username").(string)
 	sshPassword := d.Get("ssh_password").(string)
+	sshPrivateKey := d.Get("ssh_private_key").(string)
+	sshPrivateKeyPath := d.Get("ssh_private_key_path").(string)
+	sshPrivateKeyPassphrase := d.Get("ssh_private_key_passphrase").(string)
+	sshUseAgent := d.Get("ssh_use_agent").(bool)
+	sshKnownHostsFile := d.Get("ssh_known_hosts_file").(string)
+	sshHostKey := d.Get("ssh_host_key").(string)
+	sshStrictHostKeyChecking := d.Get("ssh_strict_host_key_checking").(string)
 	sshHost := d.Get("ssh_hostname").(string)
+	sshPort := d.Get("ssh_port").(int)
+	sshPersistentSession := d.Get("ssh_persistent_session").(bool)
+	sshMaxConnections := d.Get("ssh_max_connections").(int)
+	sshIdleTimeout := d.Get("ssh_idle_timeout").(int)
+	sshKeepAliveInterval := d.Get("ssh_keepalive_interval").(int)
+	winrmHostname := d.Get("winrm_hostname").(string)
+	winrmUsername := d.Get("winrm_username").(string)
+	winrmPassword := d.Get("winrm_password").(string)
+	winrmPort := d.Get("winrm_port").(int)
+	winrmUseHTTPS := d.Get("winrm_use_https").(bool)
+	winrmInsecure := d.Get("winrm_insecure").(bool)
+	winrmCACert := d.Get("winrm_ca_cert").(string)
+	winrmUseNTLM := d.Get("winrm_use_ntlm").(bool)
+	winrmUseKerberos := d.Get("winrm_use_kerberos").(bool)
+	winrmKerberosRealm := d.Get("winrm_kerberos_realm").(string)
+	winrmKerberosConfig := d.Get("winrm_kerberos_config").(string)
+	winrmKerberosSPN := d.Get("winrm_kerberos_spn").(string)
+	winrmKerberosCCache := d.Get("winrm_kerberos_ccache").(string)
 	dnsServer := d.Get("dns_server").(string)
+	maxRetries := d.Get("max_retries").(int)
+	retryMaxWait := d.Get("retry_max_wait").(int)
+
+	var bastion *BastionSettings
+	if v := d.Get("bastion").([]interface{}); len(v) > 0 && v[0] != nil {
+		b := v[0].(map[string]interface{})
+		bastion = &BastionSettings{
+			Hostname:             b["host"].(string),
+			Port:                 b["port"].(int),
+			Username:             b["username"].(string),
+			Password:             b["password"].(string),
+			PrivateKey:           b["private_key"].(string),
+			PrivateKeyPath:       b["private_key_path"].(string),
+			PrivateKeyPassphrase: b["private_key_passphrase"].(string),
+			UseAgent:             b["use_agent"].(bool),
+			HostKey:              b["host_key"].(string),
+		}
+	}
 
 	cfg := &Settings{
-		SshHostname: sshHost,
-		SshUsername: sshUsername,
-		SshPassword: sshPassword,
-		DnsServer:   dnsServer,
+		Transport:                transport,
+		LocalPowerShellPath:      localPowerShellPath,
+		SshHostname:              sshHost,
+		SshUsername:              sshUsername,
+		SshPassword:              sshPassword,
+		SshPrivateKey:            sshPrivateKey,
+		SshPrivateKeyPath:        sshPrivateKeyPath,
+		SshPrivateKeyPassphrase:  sshPrivateKeyPassphrase,
+		SshUseAgent:              sshUseAgent,
+		SshKnownHostsFile:        sshKnownHostsFile,
+		SshHostKey:               sshHostKey,
+		SshStrictHostKeyChecking: sshStrictHostKeyChecking,
+		SshPort:                  sshPort,
+		SshPersistentSession:     sshPersistentSession,
+		SshMaxConnections:        sshMaxConnections,
+		SshIdleTimeout:           sshIdleTimeout,
+		SshKeepAliveInterval:     sshKeepAliveInterval,
+		Bastion:                  bastion,
+		WinrmHostname:            winrmHostname,
+		WinrmUsername:            winrmUsername,
+		WinrmPassword:            winrmPassword,
+		WinrmPort:                winrmPort,
+		WinrmUseHTTPS:            winrmUseHTTPS,
+		WinrmInsecure:            winrmInsecure,
+		WinrmCACert:              winrmCACert,
+		WinrmUseNTLM:             winrmUseNTLM,
+		WinrmUseKerberos:         winrmUseKerberos,
+		WinrmKerberosRealm:       winrmKerberosRealm,
+		WinrmKerberosConfig:      winrmKerberosConfig,
+		WinrmKerberosSPN:         winrmKerberosSPN,
+		WinrmKerberosCCache:      winrmKerberosCCache,
+		DnsServer:                dnsServer,
+		MaxRetries:               maxRetries,
+		RetryMaxWait:             retryMaxWait,
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
 	}
 
 	return cfg, nil
 }
 
+func (s *Settings) validate() error {
+	switch s.Transport {
+	case "", TransportSSH:
+	case TransportLocal:
+		if s.Bastion != nil {
+			return fmt.Errorf("bastion can only be used with the %q transport", TransportSSH)
+		}
+		return nil
+	case TransportWinRM:
+		if s.Bastion != nil {
+			return fmt.Errorf("bastion can only be used with the %q transport", TransportSSH)
+		}
+		return s.validateWinRM()
+	default:
+		return fmt.Errorf("unknown transport %q", s.Transport)
+	}
+
+	if s.SshHostname == "" {
+		return fmt.Errorf("ssh_hostname must be set when using the %q transport", TransportSSH)
+	}
+	if s.SshUsername == "" {
+		return fmt.Errorf("ssh_username must be set when using the %q transport", TransportSSH)
+	}
+
+	if s.SshPrivateKey != "" && s.SshPrivateKeyPath != "" {
+		return fmt.Errorf("only one of ssh_private_key and ssh_private_key_path can be set")
+	}
+
+	if s.SshPassword == "" && s.SshPrivateKey == "" && s.SshPrivateKeyPath == "" && !s.SshUseAgent {
+		return fmt.Errorf("no SSH authentication method configured: set ssh_password, ssh_private_key, ssh_private_key_path or ssh_use_agent")
+	}
+
+	if s.Bastion != nil {
+		err := s.Bastion.settings(s).validate()
+		if err != nil {
+			return fmt.Errorf("bastion: %s", err)
+		}
+	}
+	return nil
+}
+
+func (s *Settings) validateWinRM() error {
+	if s.WinrmHostname == "" {
+		return fmt.Errorf("winrm_hostname must be set when using the %q transport", TransportWinRM)
+	}
+	if s.WinrmUseKerberos {
+		if s.WinrmUseNTLM {
+			return fmt.Errorf("winrm_use_ntlm and winrm_use_kerberos can't both be set")
+		}
+		// With a credential cache, the user has already authenticated, e.g. with kinit
+		if s.WinrmKerberosCCache == "" && (s.WinrmUsername == "" || s.WinrmPassword == "" || s.WinrmKerberosRealm == "") {
+			return fmt.Errorf("winrm_username, winrm_password and winrm_kerberos_realm, or winrm_kerberos_ccache, must be set when using Kerberos")
+		}
+	} else if s.WinrmUsername == "" || s.WinrmPassword == "" {
+		return fmt.Errorf("winrm_username and winrm_password must be set when using the %q transport", TransportWinRM)
+	}
+	if (s.WinrmInsecure || s.WinrmCACert != "") && !s.WinrmUseHTTPS {
+		return fmt.Errorf("winrm_insecure and winrm_ca_cert can only be used with winrm_use_https")
+	}
+	return nil
+}
+
+// settings returns the bastion as connection settings. Host key checking uses the known hosts file and mode of the
+// parent settings, unless the bastion has a pinned host key.
+func (b *BastionSettings) settings(parent *Settings) *Settings {
+	return &Settings{
+		SshHostname:              b.Hostname,
+		SshPort:                  b.Port,
+		SshUsername:              b.Username,
+		SshPassword:              b.Password,
+		SshPrivateKey:            b.PrivateKey,
+		SshPrivateKeyPath:        b.PrivateKeyPath,
+		SshPrivateKeyPassphrase:  b.PrivateKeyPassphrase,
+		SshUseAgent:              b.UseAgent,
+		SshHostKey:               b.HostKey,
+		SshKnownHostsFile:        parent.SshKnownHostsFile,
+		SshStrictHostKeyChecking: parent.SshStrictHostKeyChecking,
+	}
+}
+
+// GetSSHAuth returns the configured SSH authentication methods.
+// Public keys from the private key and the ssh-agent are offered first, then the password.
+// The connection to the ssh-agent is needed until the handshake is done, and is closed by calling closeAgent.
+func GetSSHAuth(settings *Settings) (auth goph.Auth, closeAgent func(), err error) {
+	var signers []ssh.Signer
+	closeAgent = func() {}
+
+	if settings.SshPrivateKey != "" {
+		signer, err := goph.GetSignerForRawKey([]byte(settings.SshPrivateKey), settings.SshPrivateKeyPassphrase)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("while parsing ssh_private_key: %s", err)
+		}
+		signers = append(signers, signer)
+	}
+
+	if settings.SshPrivateKeyPath != "" {
+		signer, err := goph.GetSigner(settings.SshPrivateKeyPath, settings.SshPrivateKeyPassphrase)
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("while reading ssh_private_key_path: %s", err)
+		}
+		signers = append(signers, signer)
+	}
+
+	// The ssh package only tries each method type once, so keys and agent signers must share one publickey method.
+	if settings.SshUseAgent {
+		if !goph.HasAgent() {
+			return nil, closeAgent, fmt.Errorf("ssh_use_agent is set, but SSH_AUTH_SOCK is not")
+		}
+		conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+		if err != nil {
+			return nil, closeAgent, fmt.Errorf("could not connect to ssh agent: %s", err)
+		}
+		closeAgent = func() { _ = conn.Close() }
+		agentClient := agent.NewClient(conn)
+		auth = append(auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			agentSigners, err := agentClient.Signers()
+			if err != nil {
+				return nil, err
+			}
+			return append(append([]ssh.Signer{}, signers...), agentSigners...), nil
+		}))
+	} else if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	if settings.SshPassword != "" {
+		auth = append(auth, goph.Password(settings.SshPassword)...)
+	}
+
+	if len(auth) == 0 {
+		return nil, closeAgent, fmt.Errorf("no SSH authentication method configured")
+	}
+	return auth, closeAgent, nil
+}
+
 func GetSSHConnection(settings *Settings) (*goph.Client, error) {
-	auth := goph.Password(settings.SshPassword)
-	client, err := goph.NewUnknown(settings.SshUsername, settings.SshHostname, auth)
+	auth, closeAgent, err := GetSSHAuth(settings)
+	if err != nil {
+		return nil, err
+	}
+	// The agent is only used to sign the handshake, so the connection to it isn't kept open
+	defer closeAgent()
+
+	callback, err := GetHostKeyCallback(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	port := settings.SshPort
+	if port == 0 {
+		port = 22
+	}
+	gophConfig := &goph.Config{
+		User:     settings.SshUsername,
+		Addr:     settings.SshHostname,
+		Port:     uint(port),
+		Auth:     auth,
+		Timeout:  sshTimeout,
+		Callback: callback,
+	}
+
+	if settings.Bastion != nil {
+		return dialThroughBastion(settings, gophConfig)
+	}
+
+	client, err := goph.NewConn(gophConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -43,38 +348,99 @@ func GetSSHConnection(settings *Settings) (*goph.Client, error) {
 	return client, err
 }
 
+// dialThroughBastion connects to the bastion and tunnels the SSH connection to the target host through it,
+// like ProxyJump in OpenSSH. The bastion connection is closed when the target connection is closed.
+func dialThroughBastion(settings *Settings, gophConfig *goph.Config) (*goph.Client, error) {
+	bastion, err := GetSSHConnection(settings.Bastion.settings(settings))
+	if err != nil {
+		return nil, fmt.Errorf("while connecting to bastion %s: %w", settings.Bastion.Hostname, err)
+	}
+
+	addr := net.JoinHostPort(gophConfig.Addr, fmt.Sprint(gophConfig.Port))
+	conn, err := bastion.Dial("tcp", addr)
+	if err != nil {
+		bastion.Close()
+		return nil, fmt.Errorf("while connecting to %s through bastion %s: %w", addr, settings.Bastion.Hostname, err)
+	}
+
+	// ClientConfig.Timeout only applies to dialing, and the tunneled connection doesn't support deadlines, so it is
+	// closed if the handshake doesn't complete in time
+	timer := time.AfterFunc(gophConfig.Timeout, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            gophConfig.User,
+		Auth:            gophConfig.Auth,
+		Timeout:         gophConfig.Timeout,
+		HostKeyCallback: gophConfig.Callback,
+	})
+	if !timer.Stop() {
+		if err == nil {
+			sshConn.Close()
+		}
+		err = fmt.Errorf("ssh handshake with %s through bastion %s timed out after %s: %w", addr, settings.Bastion.Hostname,
+			gophConfig.Timeout, os.ErrDeadlineExceeded)
+	}
+	if err != nil {
+		conn.Close()
+		bastion.Close()
+		return nil, err
+	}
+
+	client := &goph.Client{
+		Client: ssh.NewClient(sshConn, chans, reqs),
+		Config: gophConfig,
+	}
+	go func() {
+		_ = client.Wait()
+		bastion.Close()
+	}()
+	return client, nil
+}
+
 type ProviderConf struct {
-	Settings   *Settings
-	sshClients []*goph.Client
-	mx         *sync.Mutex
+	Settings *Settings
+	Executor Executor
+	sshPool  *sshPool
 }
 
 func NewProviderConf(settings *Settings) *ProviderConf {
 	pcfg := &ProviderConf{
-		Settings:   settings,
-		sshClients: make([]*goph.Client, 0),
-		mx:         &sync.Mutex{},
+		Settings: settings,
+		sshPool: newSshPool(
+			func() (*goph.Client, error) { return GetSSHConnection(settings) },
+			settings.SshMaxConnections,
+			time.Duration(settings.SshIdleTimeout)*time.Second,
+			time.Duration(settings.SshKeepAliveInterval)*time.Second,
+		),
+	}
+	switch settings.Transport {
+	case TransportLocal:
+		pcfg.Executor = NewLocalExecutor(settings.LocalPowerShellPath)
+	case TransportWinRM:
+		pcfg.Executor = NewWinRMExecutor(settings)
+	default:
+		executor := NewSSHExecutor(pcfg)
+		pcfg.sshPool.onClose = executor.closeSession
+		pcfg.Executor = executor
 	}
 	return pcfg
 }
 
-func (c *ProviderConf) AcquireSshClient() (client *goph.Client, err error) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-	if len(c.sshClients) == 0 {
-		client, err = GetSSHConnection(c.Settings)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		client = c.sshClients[0]
-		c.sshClients = c.sshClients[1:]
-	}
-	return client, nil
+// AcquireSshClient returns a pooled SSH connection, waiting for one to be released if ssh_max_connections are in use,
+// or until the context is done. The connection must be given back with ReleaseSshClient, or DiscardSshClient if it failed.
+func (c *ProviderConf) AcquireSshClient(ctx context.Context) (*goph.Client, error) {
+	return c.sshPool.acquire(ctx)
 }
 
 func (c *ProviderConf) ReleaseSshClient(client *goph.Client) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-	c.sshClients = append(c.sshClients, client)
+	c.sshPool.release(client)
+}
+
+// DiscardSshClient closes a connection that failed, so it isn't handed out again.
+func (c *ProviderConf) DiscardSshClient(client *goph.Client) {
+	c.sshPool.discard(client)
+}
+
+// Close closes the pooled SSH connections. It is called when the provider is stopped, or the plugin exits.
+func (c *ProviderConf) Close() {
+	c.sshPool.close()
 }
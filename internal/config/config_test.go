@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestSettings_validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		wantErr  bool
+	}{
+		{"test-password", Settings{SshHostname: "host", SshUsername: "user", SshPassword: "secret"}, false},
+		{"test-private-key", Settings{SshHostname: "host", SshUsername: "user", SshPrivateKey: "key"}, false},
+		{"test-private-key-path", Settings{SshHostname: "host", SshUsername: "user", SshPrivateKeyPath: "/path/to/key"}, false},
+		{"test-agent", Settings{SshHostname: "host", SshUsername: "user", SshUseAgent: true}, false},
+		{"test-none", Settings{SshHostname: "host", SshUsername: "user"}, true},
+		{"test-both-keys", Settings{SshHostname: "host", SshUsername: "user", SshPrivateKey: "key", SshPrivateKeyPath: "/path/to/key"}, true},
+		{"test-bastion", Settings{SshHostname: "host", SshUsername: "user", SshPassword: "secret", Bastion: &BastionSettings{Hostname: "bastion", Username: "user", UseAgent: true}}, false},
+		{"test-bastion-without-auth", Settings{SshHostname: "host", SshUsername: "user", SshPassword: "secret", Bastion: &BastionSettings{Hostname: "bastion", Username: "user"}}, true},
+		{"test-ssh-without-hostname", Settings{Transport: TransportSSH, SshUsername: "user", SshPassword: "secret"}, true},
+		{"test-ssh-without-username", Settings{Transport: TransportSSH, SshHostname: "host", SshPassword: "secret"}, true},
+		{"test-local", Settings{Transport: TransportLocal}, false},
+		{"test-local-with-bastion", Settings{Transport: TransportLocal, Bastion: &BastionSettings{Hostname: "bastion", UseAgent: true}}, true},
+		{"test-winrm", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret"}, false},
+		{"test-winrm-without-hostname", Settings{Transport: TransportWinRM, WinrmUsername: "user", WinrmPassword: "secret"}, true},
+		{"test-winrm-without-password", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user"}, true},
+		{"test-winrm-kerberos", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret", WinrmUseKerberos: true, WinrmKerberosRealm: "EXAMPLE.COM"}, false},
+		{"test-winrm-kerberos-ccache", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUseKerberos: true, WinrmKerberosCCache: "/tmp/krb5cc_1000"}, false},
+		{"test-winrm-kerberos-without-realm", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret", WinrmUseKerberos: true}, true},
+		{"test-winrm-kerberos-and-ntlm", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret", WinrmUseKerberos: true, WinrmKerberosRealm: "EXAMPLE.COM", WinrmUseNTLM: true}, true},
+		{"test-winrm-insecure-without-https", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret", WinrmInsecure: true}, true},
+		{"test-winrm-with-bastion", Settings{Transport: TransportWinRM, WinrmHostname: "host", WinrmUsername: "user", WinrmPassword: "secret", Bastion: &BastionSettings{Hostname: "bastion", UseAgent: true}}, true},
+		{"test-unknown-transport", Settings{Transport: "telnet"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetSSHAuth(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedBlock, err := ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings Settings
+		wantLen  int
+		wantErr  bool
+	}{
+		{"test-password", Settings{SshPassword: "secret"}, 1, false},
+		{"test-private-key", Settings{SshPrivateKey: string(pem.EncodeToMemory(block))}, 1, false},
+		{"test-private-key-and-password", Settings{SshPrivateKey: string(pem.EncodeToMemory(block)), SshPassword: "secret"}, 2, false},
+		{"test-encrypted-private-key", Settings{SshPrivateKey: string(pem.EncodeToMemory(encryptedBlock)), SshPrivateKeyPassphrase: "passphrase"}, 1, false},
+		{"test-wrong-passphrase", Settings{SshPrivateKey: string(pem.EncodeToMemory(encryptedBlock)), SshPrivateKeyPassphrase: "wrong"}, 0, true},
+		{"test-invalid-private-key", Settings{SshPrivateKey: "not a key"}, 0, true},
+		{"test-missing-private-key-path", Settings{SshPrivateKeyPath: "/does/not/exist"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, closeAgent, err := GetSSHAuth(&tt.settings)
+			closeAgent()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSSHAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(auth) != tt.wantLen {
+				t.Errorf("GetSSHAuth() returned %d methods, want %d", len(auth), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestGetSSHAuth_ClosesAgent(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	served := make(chan error, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			served <- err
+			return
+		}
+		// ServeAgent returns when the client closes the connection
+		served <- agent.ServeAgent(agent.NewKeyring(), conn)
+	}()
+
+	_, closeAgent, err := GetSSHAuth(&Settings{SshUseAgent: true})
+	if err != nil {
+		t.Fatalf("GetSSHAuth() error = %v", err)
+	}
+	closeAgent()
+
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection to the ssh agent wasn't closed")
+	}
+}
+
+func TestGetSSHConnection_BastionHandshakeTimeout(t *testing.T) {
+	timeout := sshTimeout
+	sshTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { sshTimeout = timeout })
+
+	bastion, err := fakedns.NewSSHServer(fakedns.NewServer("bastion"), "tester", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bastion.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bastion.Close() })
+
+	// The target accepts the connection, but never starts the handshake
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { target.Close() })
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := target.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	t.Cleanup(func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	})
+
+	start := time.Now()
+	_, err = GetSSHConnection(&Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: "127.0.0.1",
+		SshPort:     target.Addr().(*net.TCPAddr).Port,
+		Bastion: &BastionSettings{
+			Hostname: bastion.Host(),
+			Port:     bastion.Port(),
+			Username: "tester",
+			Password: "secret",
+			HostKey:  ssh.FingerprintSHA256(bastion.HostKey()),
+		},
+	})
+	if !IsNetworkError(err) || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("GetSSHConnection() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GetSSHConnection() returned after %s", elapsed)
+	}
+}
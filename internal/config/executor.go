@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/masterzen/winrm"
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+)
+
+// Executor runs a PowerShell script and returns its output. The script is stopped when the context is done.
+// ProviderConf picks the executor from the transport setting, and tests may replace it with a fake.
+type Executor interface {
+	Execute(ctx context.Context, script string) (*ExecuteResult, error)
+}
+
+// MaxCommandLineLength is the longest command line cmd.exe runs. OpenSSH on Windows and WinRM run commands with
+// cmd.exe, and the scripts are sent base64 encoded in the command line of powershell.exe, so longer scripts must be
+// split. Scripts run in a persistent session are read from stdin and aren't limited.
+const MaxCommandLineLength = 8191
+
+// CommandLineLength returns the length of the powershell.exe command line running the script.
+func CommandLineLength(script string) int {
+	return len(winrm.Powershell(script))
+}
+
+// ExecuteResult holds the stdout, stderr and exit code of a script
+type ExecuteResult struct {
+	Stdout   string
+	StdErr   string
+	ExitCode int
+}
+
+// ConnectionError is returned by Execute when the executor couldn't connect to the server. The script wasn't run,
+// so it is safe to run it again.
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// IsNetworkError returns true for network failures, like timeouts, refused connections and connections or sessions
+// closed by the server, as opposed to e.g. failed authentication or a host key mismatch.
+func IsNetworkError(err error) bool {
+	var (
+		netErr     net.Error
+		missingErr *ssh.ExitMissingError
+	)
+	return errors.As(err, &netErr) || errors.As(err, &missingErr) || errors.Is(err, io.EOF) ||
+		errors.Is(err, errSessionClosed)
+}
+
+// SSHExecutor runs scripts with powershell.exe on the SSH server, using the connections pooled in ProviderConf.
+// With ssh_persistent_session, the scripts are run in a long-lived PowerShell session per connection instead.
+type SSHExecutor struct {
+	conf *ProviderConf
+
+	mx       sync.Mutex
+	sessions map[*goph.Client]*psSession
+}
+
+func NewSSHExecutor(conf *ProviderConf) *SSHExecutor {
+	return &SSHExecutor{
+		conf:     conf,
+		sessions: make(map[*goph.Client]*psSession),
+	}
+}
+
+func (e *SSHExecutor) Execute(ctx context.Context, script string) (*ExecuteResult, error) {
+	conn, err := e.conf.AcquireSshClient(ctx)
+	if err != nil {
+		err = fmt.Errorf("while acquiring ssh client: %w", err)
+		if IsNetworkError(err) {
+			return nil, &ConnectionError{Err: err}
+		}
+		return nil, err
+	}
+
+	var result *ExecuteResult
+	if e.conf.Settings.SshPersistentSession {
+		result, err = e.executeInSession(ctx, conn, script)
+	} else {
+		result, err = e.executeCommand(ctx, conn, script)
+	}
+	if err != nil {
+		// The connection may be broken, so it isn't reused
+		e.conf.DiscardSshClient(conn)
+		return nil, err
+	}
+	e.conf.ReleaseSshClient(conn)
+	return result, nil
+}
+
+func (e *SSHExecutor) executeCommand(ctx context.Context, conn *goph.Client, script string) (*ExecuteResult, error) {
+	var (
+		err      error
+		exitCode int
+		stderr   bytes.Buffer
+		stdout   bytes.Buffer
+	)
+
+	encodedCmd := winrm.Powershell(script)
+
+	cmd, err := conn.Command(encodedCmd)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Session.Stderr = &stderr
+	cmd.Session.Stdout = &stdout
+
+	// Closing the session makes Run return, and the connection is discarded by Execute
+	stop := context.AfterFunc(ctx, func() { _ = cmd.Session.Close() })
+	err = cmd.Run()
+	if !stop() {
+		return nil, fmt.Errorf("while running script: %w", ctx.Err())
+	}
+	if err != nil {
+		if v, ok := err.(*ssh.ExitError); ok {
+			exitCode = v.ExitStatus()
+		} else {
+			return nil, fmt.Errorf("run error: %w", err)
+		}
+	}
+
+	return &ExecuteResult{
+		Stdout:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// executeInSession runs the script in the session of the connection, starting it if needed. If the session is
+// broken, the connection is discarded along with the session.
+func (e *SSHExecutor) executeInSession(ctx context.Context, conn *goph.Client, script string) (*ExecuteResult, error) {
+	e.mx.Lock()
+	session, ok := e.sessions[conn]
+	e.mx.Unlock()
+
+	if !ok {
+		var err error
+		session, err = startPSSession(conn)
+		if err != nil {
+			return nil, err
+		}
+		e.mx.Lock()
+		e.sessions[conn] = session
+		e.mx.Unlock()
+	}
+
+	return session.Execute(ctx, script)
+}
+
+// closeSession closes the session of a connection, if any. It is called when the connection is closed.
+func (e *SSHExecutor) closeSession(conn *goph.Client) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if session, ok := e.sessions[conn]; ok {
+		_ = session.Close()
+		delete(e.sessions, conn)
+	}
+}
+
+// LocalExecutor runs scripts in a local pwsh or powershell.exe process, e.g. on a Windows runner or with the
+// DnsServer module imported through implicit remoting.
+type LocalExecutor struct {
+	path string
+}
+
+// NewLocalExecutor returns an executor running the PowerShell executable at path. If path is empty, pwsh or
+// powershell.exe is looked up in PATH when the first script is run.
+func NewLocalExecutor(path string) *LocalExecutor {
+	return &LocalExecutor{path: path}
+}
+
+func (e *LocalExecutor) Execute(ctx context.Context, script string) (*ExecuteResult, error) {
+	path, err := e.powerShellPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// The script is encoded like for the SSH transport, so quoting works the same way
+	encodedCmd := strings.TrimPrefix(winrm.Powershell(script), "powershell.exe -EncodedCommand ")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "-NoProfile", "-NonInteractive", "-EncodedCommand", encodedCmd)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	exitCode := 0
+	err = cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("while running %s: %w", path, ctxErr)
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("while running %s: %s", path, err)
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	return &ExecuteResult{
+		Stdout:   stdout.String(),
+		StdErr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+func (e *LocalExecutor) powerShellPath() (string, error) {
+	if e.path != "" {
+		return e.path, nil
+	}
+	for _, name := range []string{"pwsh", "powershell.exe"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("neither pwsh nor powershell.exe was found in PATH, set local_powershell_path")
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestLocalExecutor_Execute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as a stand-in for pwsh")
+	}
+
+	path := filepath.Join(t.TempDir(), "pwsh")
+	script := "#!/bin/sh\necho \"$@\"\necho 'something failed' >&2\nexit 3\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewLocalExecutor(path).Execute(context.Background(), "Get-DnsServerZone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.Stdout, "-NoProfile -NonInteractive -EncodedCommand ") {
+		t.Errorf("unexpected arguments: %s", result.Stdout)
+	}
+	if result.StdErr != "something failed\n" {
+		t.Errorf("StdErr = %q", result.StdErr)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", result.ExitCode)
+	}
+}
+
+func TestLocalExecutor_ExecuteMissingExecutable(t *testing.T) {
+	_, err := NewLocalExecutor(filepath.Join(t.TempDir(), "missing")).Execute(context.Background(), "Get-DnsServerZone")
+	if err == nil {
+		t.Error("expected an error for a missing executable")
+	}
+}
+
+func TestDecodeCLIXML(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   string
+	}{
+		{"plain", "something failed\n", "something failed\n"},
+		{"empty", "", ""},
+		{
+			"errors",
+			"#< CLIXML\r\n<Objs Version=\"1.1.0.1\" xmlns=\"http://schemas.microsoft.com/powershell/2004/04\">" +
+				"<Obj S=\"progress\" RefId=\"0\"><TN RefId=\"0\"><T>System.Management.Automation.PSCustomObject</T></TN></Obj>" +
+				"<S S=\"Error\">Get-DnsServerZone : The zone &lt;a&gt; was not found_x000D__x000A_</S>" +
+				"<S S=\"Error\">    + CategoryInfo          : ObjectNotFound_x000D__x000A_</S></Objs>",
+			"Get-DnsServerZone : The zone <a> was not found\r\n    + CategoryInfo          : ObjectNotFound\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeCLIXML(tt.stderr); got != tt.want {
+				t.Errorf("decodeCLIXML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSHExecutor_ConnectionError(t *testing.T) {
+	// Nothing listens on the port once the listener is closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	conf := NewProviderConf(&Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: "127.0.0.1",
+		SshPort:     port,
+		SshHostKey:  ssh.FingerprintSHA256(newTestHostKey(t)),
+	})
+	_, err = conf.Executor.Execute(context.Background(), "Get-DnsServerZone")
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Errorf("Execute() error = %v, want a ConnectionError", err)
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// StrictHostKeyCheckingYes only accepts host keys that are pinned or present in the known hosts file.
+	StrictHostKeyCheckingYes = "yes"
+	// StrictHostKeyCheckingAcceptNew adds unknown host keys to the known hosts file, but rejects changed keys.
+	StrictHostKeyCheckingAcceptNew = "accept-new"
+	// StrictHostKeyCheckingNo accepts any host key.
+	StrictHostKeyCheckingNo = "no"
+)
+
+// GetHostKeyCallback returns a callback verifying the SSH server's host key according to the settings.
+// A pinned host key takes precedence over the known hosts file.
+func GetHostKeyCallback(settings *Settings) (ssh.HostKeyCallback, error) {
+	mode := settings.SshStrictHostKeyChecking
+	if mode == "" {
+		mode = StrictHostKeyCheckingAcceptNew
+	}
+
+	if mode == StrictHostKeyCheckingNo {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	if settings.SshHostKey != "" {
+		return pinnedHostKeyCallback(settings.SshHostKey)
+	}
+
+	knownHostsFile := settings.SshKnownHostsFile
+	if knownHostsFile == "" {
+		path, err := goph.DefaultKnownHostsPath()
+		if err != nil {
+			return nil, fmt.Errorf("while finding the default known hosts file: %s", err)
+		}
+		knownHostsFile = path
+	}
+
+	return knownHostsCallback(knownHostsFile, mode == StrictHostKeyCheckingAcceptNew)
+}
+
+// pinnedHostKeyCallback accepts a host key given either in authorized_keys format or as a SHA256 fingerprint.
+func pinnedHostKeyCallback(hostKey string) (ssh.HostKeyCallback, error) {
+	hostKey = strings.TrimSpace(hostKey)
+
+	if strings.HasPrefix(hostKey, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != hostKey {
+				return hostKeyMismatchError(hostname, key, hostKey)
+			}
+			return nil
+		}, nil
+	}
+
+	pinned, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("ssh_host_key must be a public key in authorized_keys format or a SHA256 fingerprint: %s", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !bytes.Equal(key.Marshal(), pinned.Marshal()) {
+			return hostKeyMismatchError(hostname, key, ssh.FingerprintSHA256(pinned))
+		}
+		return nil
+	}, nil
+}
+
+// knownHostsMx serializes reading and adding host keys, so concurrent connections don't add the same key twice.
+var knownHostsMx sync.Mutex
+
+func knownHostsCallback(knownHostsFile string, acceptNew bool) (ssh.HostKeyCallback, error) {
+	_, err := os.Stat(knownHostsFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) || !acceptNew {
+			return nil, fmt.Errorf("while reading known hosts file: %s", err)
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMx.Lock()
+		defer knownHostsMx.Unlock()
+
+		// The file is read on every connection, so host keys added by other connections are picked up
+		var callback ssh.HostKeyCallback
+		if _, err := os.Stat(knownHostsFile); err == nil {
+			callback, err = knownhosts.New(knownHostsFile)
+			if err != nil {
+				return fmt.Errorf("while reading known hosts file: %s", err)
+			}
+		} else {
+			callback = func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }
+		}
+
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			var want []string
+			for _, k := range keyErr.Want {
+				want = append(want, ssh.FingerprintSHA256(k.Key))
+			}
+			return hostKeyMismatchError(hostname, key, strings.Join(want, ", "))
+		}
+
+		if !acceptNew {
+			return fmt.Errorf("host key for %s is not in %s, the server offered %s %s. Add it to the known hosts file or set ssh_host_key",
+				hostname, knownHostsFile, key.Type(), ssh.FingerprintSHA256(key))
+		}
+
+		err = os.MkdirAll(filepath.Dir(knownHostsFile), 0o700)
+		if err != nil {
+			return fmt.Errorf("while creating known hosts directory: %s", err)
+		}
+		err = addKnownHost(hostname, remote, key, knownHostsFile)
+		if err != nil {
+			return fmt.Errorf("while adding host key %s for %s to %s: %s", ssh.FingerprintSHA256(key), hostname, knownHostsFile, err)
+		}
+		return nil
+	}, nil
+}
+
+// addKnownHost appends the host key to the known hosts file, for the hostname and the IP address of the server. A
+// connection tunneled through a bastion has the zero address as its remote address, so only the hostname is written
+// for it.
+func addKnownHost(hostname string, remote net.Addr, key ssh.PublicKey, knownHostsFile string) error {
+	addresses := []string{knownhosts.Normalize(hostname)}
+	if tcpAddr, ok := remote.(*net.TCPAddr); ok && tcpAddr.IP != nil && !tcpAddr.IP.IsUnspecified() {
+		if address := knownhosts.Normalize(remote.String()); address != addresses[0] {
+			addresses = append(addresses, address)
+		}
+	}
+
+	f, err := os.OpenFile(knownHostsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(knownhosts.Line(addresses, key) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func hostKeyMismatchError(hostname string, key ssh.PublicKey, expected string) error {
+	return fmt.Errorf("host key verification failed for %s: the server offered %s %s, but expected %s. This could be a man-in-the-middle attack",
+		hostname, key.Type(), ssh.FingerprintSHA256(key), expected)
+}
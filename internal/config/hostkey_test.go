@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestGetHostKeyCallback_Pinned(t *testing.T) {
+	key := newTestHostKey(t)
+	otherKey := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+
+	tests := []struct {
+		name    string
+		hostKey string
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{"test-fingerprint", ssh.FingerprintSHA256(key), key, false},
+		{"test-fingerprint-mismatch", ssh.FingerprintSHA256(key), otherKey, true},
+		{"test-authorized-key", string(ssh.MarshalAuthorizedKey(key)), key, false},
+		{"test-authorized-key-mismatch", string(ssh.MarshalAuthorizedKey(key)), otherKey, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := GetHostKeyCallback(&Settings{SshHostKey: tt.hostKey, SshStrictHostKeyChecking: StrictHostKeyCheckingYes})
+			if err != nil {
+				t.Fatalf("GetHostKeyCallback() error = %v", err)
+			}
+			err = callback("jumphost:22", remote, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("callback() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), ssh.FingerprintSHA256(tt.key)) {
+				t.Errorf("callback() error %q does not contain the offered fingerprint", err)
+			}
+		})
+	}
+
+	_, err := GetHostKeyCallback(&Settings{SshHostKey: "not a key"})
+	if err == nil {
+		t.Errorf("GetHostKeyCallback() expected error for invalid ssh_host_key")
+	}
+}
+
+func TestGetHostKeyCallback_KnownHosts(t *testing.T) {
+	key := newTestHostKey(t)
+	otherKey := newTestHostKey(t)
+	remote := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 22}
+	knownHostsFile := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+
+	// Strict checking requires the known hosts file to exist
+	_, err := GetHostKeyCallback(&Settings{SshKnownHostsFile: knownHostsFile, SshStrictHostKeyChecking: StrictHostKeyCheckingYes})
+	if err == nil {
+		t.Fatalf("GetHostKeyCallback() expected error for missing known hosts file")
+	}
+
+	// accept-new adds the unknown host key
+	callback, err := GetHostKeyCallback(&Settings{SshKnownHostsFile: knownHostsFile, SshStrictHostKeyChecking: StrictHostKeyCheckingAcceptNew})
+	if err != nil {
+		t.Fatalf("GetHostKeyCallback() error = %v", err)
+	}
+	if err := callback("jumphost:22", remote, key); err != nil {
+		t.Fatalf("callback() accept-new error = %v", err)
+	}
+	if _, err := os.Stat(knownHostsFile); err != nil {
+		t.Fatalf("known hosts file was not created: %v", err)
+	}
+
+	// A changed host key is rejected, also by accept-new
+	if err := callback("jumphost:22", remote, otherKey); err == nil {
+		t.Errorf("callback() expected error for changed host key")
+	}
+
+	// Strict checking accepts the known key, and rejects unknown hosts
+	callback, err = GetHostKeyCallback(&Settings{SshKnownHostsFile: knownHostsFile, SshStrictHostKeyChecking: StrictHostKeyCheckingYes})
+	if err != nil {
+		t.Fatalf("GetHostKeyCallback() error = %v", err)
+	}
+	if err := callback("jumphost:22", remote, key); err != nil {
+		t.Errorf("callback() error = %v", err)
+	}
+	err = callback("otherhost:22", &net.TCPAddr{IP: net.ParseIP("192.0.2.11"), Port: 22}, otherKey)
+	if err == nil || !strings.Contains(err.Error(), ssh.FingerprintSHA256(otherKey)) {
+		t.Errorf("callback() error = %v, want error with the offered fingerprint", err)
+	}
+
+	// No checking accepts anything
+	callback, err = GetHostKeyCallback(&Settings{SshKnownHostsFile: knownHostsFile, SshStrictHostKeyChecking: StrictHostKeyCheckingNo})
+	if err != nil {
+		t.Fatalf("GetHostKeyCallback() error = %v", err)
+	}
+	if err := callback("jumphost:22", remote, otherKey); err != nil {
+		t.Errorf("callback() error = %v", err)
+	}
+}
+
+func TestGetHostKeyCallback_AcceptNewThroughBastion(t *testing.T) {
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	callback, err := GetHostKeyCallback(&Settings{SshKnownHostsFile: knownHostsFile, SshStrictHostKeyChecking: StrictHostKeyCheckingAcceptNew})
+	if err != nil {
+		t.Fatalf("GetHostKeyCallback() error = %v", err)
+	}
+
+	// Connections tunneled through a bastion have the zero address as their remote address
+	tunneled := &net.TCPAddr{IP: net.IPv4zero, Port: 0}
+	for _, hostname := range []string{"dc1.example.com:22", "dc2.example.com:2222"} {
+		if err := callback(hostname, tunneled, newTestHostKey(t)); err != nil {
+			t.Fatalf("callback() error = %v for %s", err, hostname)
+		}
+	}
+
+	content, err := os.ReadFile(knownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "dc1.example.com ") || !strings.HasPrefix(lines[1], "[dc2.example.com]:2222 ") {
+		t.Errorf("known hosts file is %q, want entries for the hostnames only", content)
+	}
+}
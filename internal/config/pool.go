@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/melbahja/goph"
+)
+
+const (
+	defaultSshMaxConnections = 5
+
+	// sshLivenessTimeout is how long to wait for the reply to a keepalive before a connection is considered dead
+	sshLivenessTimeout = 10 * time.Second
+)
+
+// sshPool is a bounded pool of SSH connections. At most maxSize connections are open at a time, and callers wait
+// for a connection to be released when all of them are in use. Idle connections are closed by a timer after
+// idleTimeout, and checked with a keepalive request before they are reused. A zero idleTimeout or keepAlive disables
+// them. Connections are closed, and onClose called, without holding the lock of the pool.
+type sshPool struct {
+	dial        func() (*goph.Client, error)
+	maxSize     int
+	idleTimeout time.Duration
+	keepAlive   time.Duration
+	// onClose is called when a connection is closed, e.g. to clean up state kept per connection
+	onClose func(*goph.Client)
+
+	mx      sync.Mutex
+	cond    *sync.Cond
+	idle    []*pooledClient
+	clients map[*goph.Client]*pooledClient
+	dialing int
+	closed  bool
+}
+
+type pooledClient struct {
+	client    *goph.Client
+	idleSince time.Time
+	idleTimer *time.Timer
+	done      chan struct{}
+}
+
+func newSshPool(dial func() (*goph.Client, error), maxSize int, idleTimeout, keepAlive time.Duration) *sshPool {
+	if maxSize <= 0 {
+		maxSize = defaultSshMaxConnections
+	}
+	p := &sshPool{
+		dial:        dial,
+		maxSize:     maxSize,
+		idleTimeout: idleTimeout,
+		keepAlive:   keepAlive,
+		clients:     make(map[*goph.Client]*pooledClient),
+	}
+	p.cond = sync.NewCond(&p.mx)
+	return p
+}
+
+// acquire returns an idle connection that is still alive, or opens a new one if the pool isn't full. It waits for
+// a connection to be released until the context is done.
+func (p *sshPool) acquire(ctx context.Context) (*goph.Client, error) {
+	// Wake up the waiting callers when the context is done, so they can return
+	stop := context.AfterFunc(ctx, func() {
+		p.mx.Lock()
+		p.cond.Broadcast()
+		p.mx.Unlock()
+	})
+	defer stop()
+
+	p.mx.Lock()
+	for {
+		if err := ctx.Err(); err != nil {
+			p.mx.Unlock()
+			return nil, err
+		}
+
+		if n := len(p.idle); n > 0 {
+			// The most recently used connection is the least likely to have timed out
+			pc := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			if pc.idleTimer != nil {
+				pc.idleTimer.Stop()
+			}
+			p.mx.Unlock()
+
+			// The timer may not have fired yet for a connection past the idle timeout
+			expired := p.idleTimeout > 0 && time.Since(pc.idleSince) > p.idleTimeout
+			if !expired && isAlive(pc.client) {
+				return pc.client, nil
+			}
+			p.discard(pc.client)
+			p.mx.Lock()
+			continue
+		}
+
+		if len(p.clients)+p.dialing < p.maxSize {
+			// Count the connection while dialing, so concurrent callers don't exceed maxSize
+			p.dialing++
+			p.mx.Unlock()
+
+			client, err := p.dial()
+
+			p.mx.Lock()
+			p.dialing--
+			if err != nil {
+				p.cond.Signal()
+				p.mx.Unlock()
+				return nil, err
+			}
+			pc := &pooledClient{client: client, done: make(chan struct{})}
+			p.clients[client] = pc
+			p.mx.Unlock()
+			if p.keepAlive > 0 {
+				go keepAlive(pc, p.keepAlive)
+			}
+			return client, nil
+		}
+
+		p.cond.Wait()
+	}
+}
+
+// release returns a healthy connection to the pool.
+func (p *sshPool) release(client *goph.Client) {
+	p.mx.Lock()
+	pc, ok := p.clients[client]
+	if !ok {
+		p.mx.Unlock()
+		return
+	}
+	if p.closed {
+		p.removeLocked(pc)
+		p.mx.Unlock()
+		p.closeClient(pc)
+		return
+	}
+	pc.idleSince = time.Now()
+	if p.idleTimeout > 0 {
+		pc.idleTimer = time.AfterFunc(p.idleTimeout, func() { p.expire(pc) })
+	}
+	p.idle = append(p.idle, pc)
+	p.cond.Signal()
+	p.mx.Unlock()
+}
+
+// expire closes a connection that has been idle for idleTimeout, unless it was acquired in the meantime.
+func (p *sshPool) expire(pc *pooledClient) {
+	p.mx.Lock()
+	i := slices.Index(p.idle, pc)
+	if i < 0 {
+		p.mx.Unlock()
+		return
+	}
+	p.idle = slices.Delete(p.idle, i, i+1)
+	p.removeLocked(pc)
+	p.mx.Unlock()
+	p.closeClient(pc)
+}
+
+// discard closes a connection that failed, instead of returning it to the pool.
+func (p *sshPool) discard(client *goph.Client) {
+	p.mx.Lock()
+	pc, ok := p.clients[client]
+	if ok {
+		p.removeLocked(pc)
+	}
+	p.mx.Unlock()
+	if ok {
+		p.closeClient(pc)
+	}
+}
+
+// close closes the idle connections, and connections in use when they are released. The pool can still be used
+// afterwards, but connections are closed when they are released instead of being kept open.
+func (p *sshPool) close() {
+	p.mx.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	for _, pc := range idle {
+		if pc.idleTimer != nil {
+			pc.idleTimer.Stop()
+		}
+		p.removeLocked(pc)
+	}
+	p.cond.Broadcast()
+	p.mx.Unlock()
+
+	for _, pc := range idle {
+		p.closeClient(pc)
+	}
+}
+
+// removeLocked removes a connection from the pool, making room for a new one. It must be closed with closeClient
+// after the lock is released.
+func (p *sshPool) removeLocked(pc *pooledClient) {
+	delete(p.clients, pc.client)
+	close(pc.done)
+	p.cond.Signal()
+}
+
+// closeClient closes a connection removed from the pool. It may block on the network, and onClose may take other
+// locks, so it is called without holding the lock of the pool.
+func (p *sshPool) closeClient(pc *pooledClient) {
+	_ = pc.client.Close()
+	if p.onClose != nil {
+		p.onClose(pc.client)
+	}
+}
+
+// isAlive sends a keepalive request and waits for the reply. The server rejecting the request is fine, since it
+// still proves the connection is alive.
+func isAlive(client *goph.Client) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-time.After(sshLivenessTimeout):
+		return false
+	}
+}
+
+// keepAlive sends keepalive requests until the connection is closed, so idle connections aren't dropped by
+// firewalls, and dead connections are noticed.
+func keepAlive(pc *pooledClient, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pc.done:
+			return
+		case <-ticker.C:
+			_, _, err := pc.client.SendRequest("keepalive@openssh.com", true, nil)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/melbahja/goph"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSshPool returns a pool of connections to a fake SSH server, and a counter of the connections dialed.
+func newTestSshPool(t *testing.T, maxSize int, idleTimeout time.Duration) (*sshPool, *fakedns.SSHServer, *int32) {
+	t.Helper()
+	server, err := fakedns.NewSSHServer(fakedns.NewServer("dc1"), "tester", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	settings := &Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: server.Host(),
+		SshPort:     server.Port(),
+		SshHostKey:  ssh.FingerprintSHA256(server.HostKey()),
+	}
+	var dials int32
+	pool := newSshPool(func() (*goph.Client, error) {
+		atomic.AddInt32(&dials, 1)
+		return GetSSHConnection(settings)
+	}, maxSize, idleTimeout, 0)
+	t.Cleanup(pool.close)
+	return pool, server, &dials
+}
+
+func TestSshPool_MaxConnections(t *testing.T) {
+	pool, _, dials := newTestSshPool(t, 2, 0)
+
+	first, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan *goph.Client)
+	go func() {
+		client, err := pool.acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- client
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire() returned while the pool was full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	pool.release(first)
+	select {
+	case client := <-acquired:
+		if client != first {
+			t.Error("acquire() didn't reuse the released connection")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire() didn't return after a connection was released")
+	}
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dialed %d connections, want 2", n)
+	}
+}
+
+func TestSshPool_AcquireContext(t *testing.T) {
+	pool, _, _ := newTestSshPool(t, 1, 0)
+	if _, err := pool.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := pool.acquire(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire() error = %v, want %v while the pool is full", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSshPool_ReplacesDeadConnection(t *testing.T) {
+	pool, server, dials := newTestSshPool(t, 2, 0)
+
+	var closed []*goph.Client
+	pool.onClose = func(client *goph.Client) { closed = append(closed, client) }
+
+	first, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(first)
+	server.CloseConnections()
+
+	second, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("acquire() reused a dead connection")
+	}
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dialed %d connections, want 2", n)
+	}
+	if len(closed) != 1 || closed[0] != first {
+		t.Errorf("onClose wasn't called for the dead connection")
+	}
+
+	pool.discard(second)
+	if _, err := pool.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(dials); n != 3 {
+		t.Errorf("dialed %d connections, want 3 after discarding a connection", n)
+	}
+}
+
+func TestSshPool_IdleTimeout(t *testing.T) {
+	pool, _, dials := newTestSshPool(t, 1, 50*time.Millisecond)
+
+	first, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(first)
+	time.Sleep(100 * time.Millisecond)
+
+	second, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Error("acquire() reused a connection past the idle timeout")
+	}
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dialed %d connections, want 2", n)
+	}
+}
+
+func TestSshPool_IdleTimeoutClosesConnection(t *testing.T) {
+	pool, server, _ := newTestSshPool(t, 1, 50*time.Millisecond)
+	closed := make(chan bool, 1)
+	pool.onClose = func(*goph.Client) {
+		locked := pool.mx.TryLock()
+		if locked {
+			pool.mx.Unlock()
+		}
+		closed <- locked
+	}
+
+	client, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(client)
+
+	// The connection is closed without the pool being used again
+	select {
+	case locked := <-closed:
+		if !locked {
+			t.Error("onClose was called with the lock of the pool held")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle connection wasn't closed")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Connections() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections still open after the idle timeout", server.Connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSshPool_Close(t *testing.T) {
+	pool, server, _ := newTestSshPool(t, 2, 0)
+
+	idle, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	inUse, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(idle)
+
+	pool.close()
+
+	// Connections in use, and connections opened after the pool was closed, are closed when they are released
+	pool.release(inUse)
+	client, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(client)
+	deadline := time.Now().Add(5 * time.Second)
+	for server.Connections() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d connections still open after the pool was closed", server.Connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/masterzen/winrm"
+	"github.com/melbahja/goph"
+	"golang.org/x/crypto/ssh"
+)
+
+// psSessionResultPrefix marks the line holding the result of a script. Other lines written by the session host,
+// e.g. by scripts writing directly to the console, are skipped.
+const psSessionResultPrefix = "#windns-result "
+
+// psSessionHost is the script run by a persistent PowerShell session. It reads scripts from stdin, one per line as
+// base64 encoded UTF-8, and writes the result of each as a JSON line prefixed by psSessionResultPrefix. The output
+// and errors of a script are formatted like powershell.exe writes them to stdout and stderr, and the exit code is 1
+// if the script wrote an error.
+const psSessionHost = `$ProgressPreference = 'SilentlyContinue'
+while ($null -ne ($line = [Console]::In.ReadLine())) {
+    $script = [System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String($line))
+    $output = @()
+    $errors = @()
+    try {
+        & ([scriptblock]::Create($script)) 2>&1 | ForEach-Object {
+            if ($_ -is [System.Management.Automation.ErrorRecord]) { $errors += $_ } else { $output += $_ }
+        }
+    } catch {
+        $errors += $_
+    }
+    $result = @{
+        Stdout = ($output | Out-String -Width 4096)
+        Stderr = ($errors | Out-String -Width 4096)
+        ExitCode = [int]($errors.Count -gt 0)
+    }
+    [Console]::Out.WriteLine('` + psSessionResultPrefix + `' + ($result | ConvertTo-Json -Compress))
+}`
+
+// errSessionClosed is returned when a script is sent to a session that was closed, e.g. when the connection failed.
+var errSessionClosed = errors.New("the session is closed")
+
+// psSession is a long-lived PowerShell process on an SSH connection. Modules like DnsServer are only loaded once
+// per session, instead of once per script. A session runs one script at a time.
+type psSession struct {
+	session *ssh.Session
+	stdout  *bufio.Reader
+
+	// mx guards writing to and closing stdin, since the SSH channel doesn't allow both at the same time
+	mx     sync.Mutex
+	stdin  io.WriteCloser
+	closed bool
+}
+
+// startPSSession starts the session host on the SSH connection.
+func startPSSession(client *goph.Client) (*psSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("while opening ssh session: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	encodedCmd := strings.TrimPrefix(winrm.Powershell(psSessionHost), "powershell.exe -EncodedCommand ")
+	err = session.Start(fmt.Sprintf("powershell.exe -NoProfile -NonInteractive -EncodedCommand %s", encodedCmd))
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("while starting powershell session: %w", err)
+	}
+
+	return &psSession{
+		session: session,
+		stdin:   stdin,
+		stdout:  bufio.NewReader(stdout),
+	}, nil
+}
+
+// psSessionResult is the result of a script as written by the session host
+type psSessionResult struct {
+	Stdout   string `json:"Stdout"`
+	Stderr   string `json:"Stderr"`
+	ExitCode int    `json:"ExitCode"`
+}
+
+// Execute runs the script in the session. An error means the session is broken and should be closed. If the
+// context is done, the SSH session is closed to stop the script. Unlike stdin, it can be closed while the script
+// is being sent.
+func (s *psSession) Execute(ctx context.Context, script string) (*ExecuteResult, error) {
+	stop := context.AfterFunc(ctx, func() { _ = s.session.Close() })
+	defer stop()
+
+	result, err := s.execute(script)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("while running script in powershell session: %w", ctxErr)
+	}
+	return result, err
+}
+
+func (s *psSession) execute(script string) (*ExecuteResult, error) {
+	err := s.send(script)
+	if err != nil {
+		return nil, fmt.Errorf("while sending script to powershell session: %w", err)
+	}
+
+	for {
+		line, err := s.stdout.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("while reading from powershell session: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, psSessionResultPrefix) {
+			continue
+		}
+
+		var result psSessionResult
+		err = json.Unmarshal([]byte(strings.TrimPrefix(line, psSessionResultPrefix)), &result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid result from powershell session: %s", err)
+		}
+		return &ExecuteResult{
+			Stdout:   result.Stdout,
+			StdErr:   result.Stderr,
+			ExitCode: result.ExitCode,
+		}, nil
+	}
+}
+
+// send writes the script to the session host.
+func (s *psSession) send(script string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return errSessionClosed
+	}
+	_, err := io.WriteString(s.stdin, base64.StdEncoding.EncodeToString([]byte(script))+"\n")
+	return err
+}
+
+// Close closes the SSH session, and then stdin once no script is being sent.
+func (s *psSession) Close() error {
+	err := s.session.Close()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if !s.closed {
+		s.closed = true
+		_ = s.stdin.Close()
+	}
+	return err
+}
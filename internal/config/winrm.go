@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/masterzen/winrm"
+)
+
+const (
+	defaultWinrmPort      = 5985
+	defaultWinrmHTTPSPort = 5986
+
+	defaultKerberosConfig = "/etc/krb5.conf"
+)
+
+// WinRMExecutor runs scripts with powershell.exe on the Windows server over WinRM.
+type WinRMExecutor struct {
+	settings *Settings
+
+	mx     sync.Mutex
+	client *winrm.Client
+}
+
+func NewWinRMExecutor(settings *Settings) *WinRMExecutor {
+	return &WinRMExecutor{settings: settings}
+}
+
+func (e *WinRMExecutor) Execute(ctx context.Context, script string) (*ExecuteResult, error) {
+	client, err := e.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := client.RunWithContext(ctx, winrm.Powershell(script), &stdout, &stderr)
+	if err != nil {
+		// Failing to connect before the shell is created or the command is sent means the script wasn't run
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, &ConnectionError{Err: fmt.Errorf("winrm connection error: %w", err)}
+		}
+		return nil, fmt.Errorf("winrm run error: %w", err)
+	}
+
+	return &ExecuteResult{
+		Stdout:   stdout.String(),
+		StdErr:   decodeCLIXML(stderr.String()),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// getClient returns the WinRM client, which is created on first use. The client doesn't keep a connection open,
+// so it is shared by all scripts.
+func (e *WinRMExecutor) getClient() (*winrm.Client, error) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	if e.client != nil {
+		return e.client, nil
+	}
+
+	s := e.settings
+	port := s.WinrmPort
+	if port == 0 {
+		port = defaultWinrmPort
+		if s.WinrmUseHTTPS {
+			port = defaultWinrmHTTPSPort
+		}
+	}
+
+	var caCert []byte
+	if s.WinrmCACert != "" {
+		caCert = []byte(s.WinrmCACert)
+	}
+	endpoint := winrm.NewEndpoint(s.WinrmHostname, port, s.WinrmUseHTTPS, s.WinrmInsecure, caCert, nil, nil, 0)
+
+	params := *winrm.DefaultParameters
+	switch {
+	case s.WinrmUseNTLM:
+		params.TransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
+	case s.WinrmUseKerberos:
+		proto := "http"
+		if s.WinrmUseHTTPS {
+			proto = "https"
+		}
+		kerberos := &winrm.Settings{
+			WinRMUsername: s.WinrmUsername,
+			WinRMPassword: s.WinrmPassword,
+			WinRMHost:     s.WinrmHostname,
+			WinRMPort:     port,
+			WinRMProto:    proto,
+			KrbRealm:      s.WinrmKerberosRealm,
+			KrbConfig:     s.WinrmKerberosConfig,
+			KrbSpn:        s.WinrmKerberosSPN,
+			KrbCCache:     s.WinrmKerberosCCache,
+		}
+		if kerberos.KrbConfig == "" {
+			kerberos.KrbConfig = defaultKerberosConfig
+		}
+		params.TransportDecorator = func() winrm.Transporter { return winrm.NewClientKerberos(kerberos) }
+	}
+
+	client, err := winrm.NewClientWithParameters(endpoint, s.WinrmUsername, s.WinrmPassword, &params)
+	if err != nil {
+		return nil, fmt.Errorf("while creating winrm client: %s", err)
+	}
+	e.client = client
+	return client, nil
+}
+
+var clixmlEscape = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+// decodeCLIXML converts the error stream powershell.exe writes as CLIXML when run over WinRM to plain text, so
+// errors look the same as over SSH. Other output is returned unchanged.
+func decodeCLIXML(stderr string) string {
+	const header = "#< CLIXML"
+	if !strings.HasPrefix(stderr, header) {
+		return stderr
+	}
+
+	var b strings.Builder
+	decoder := xml.NewDecoder(strings.NewReader(strings.TrimPrefix(stderr, header)))
+	inError := false
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			inError = false
+			if t.Name.Local == "S" {
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "S" && attr.Value == "Error" {
+						inError = true
+					}
+				}
+			}
+		case xml.EndElement:
+			inError = false
+		case xml.CharData:
+			if inError {
+				b.Write(t)
+			}
+		}
+	}
+
+	if b.Len() == 0 {
+		return stderr
+	}
+	return clixmlEscape.ReplaceAllStringFunc(b.String(), func(s string) string {
+		n, _ := strconv.ParseUint(s[2:6], 16, 16)
+		return string(rune(n))
+	})
+}
@@ -0,0 +1,319 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+const (
+	RecordOperationAdd    = "add"
+	RecordOperationRemove = "remove"
+)
+
+// RecordOperationResult is the outcome of adding or removing one value of a record set in a batch.
+// Error is nil if the operation succeeded.
+type RecordOperationResult struct {
+	Operation string
+	Value     string
+	Error     error
+}
+
+// RecordBatchError is returned when some of the operations in a batch failed.
+type RecordBatchError struct {
+	Failed []RecordOperationResult
+}
+
+func (e *RecordBatchError) Error() string {
+	var msgs []string
+	for _, f := range e.Failed {
+		msgs = append(msgs, fmt.Sprintf("failed to %s record data %q: %s", f.Operation, f.Value, f.Error))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the failed operations, so they can be matched with errors.Is.
+func (e *RecordBatchError) Unwrap() []error {
+	var errs []error
+	for _, f := range e.Failed {
+		errs = append(errs, f.Error)
+	}
+	return errs
+}
+
+// RecordUpdateError is returned when changes to a record set failed. The changes that were applied are rolled back,
+// so the record set is left as it was. If the rollback fails too, Actual holds the values read back from the DNS
+// server, unless they couldn't be read either.
+type RecordUpdateError struct {
+	Applied     []RecordOperationResult
+	Failed      []RecordOperationResult
+	RollbackErr error
+	Actual      []string
+}
+
+// Unwrap returns the errors of the failed operations, so they can be matched with errors.Is.
+func (e *RecordUpdateError) Unwrap() []error {
+	return (&RecordBatchError{Failed: e.Failed}).Unwrap()
+}
+
+// RolledBack returns true if the applied changes were rolled back.
+func (e *RecordUpdateError) RolledBack() bool {
+	return e.RollbackErr == nil
+}
+
+func (e *RecordUpdateError) Error() string {
+	msg := (&RecordBatchError{Failed: e.Failed}).Error()
+	if len(e.Applied) == 0 {
+		return msg + ". No changes were applied"
+	}
+
+	var applied []string
+	for _, a := range e.Applied {
+		verb := "added"
+		if a.Operation == RecordOperationRemove {
+			verb = "removed"
+		}
+		applied = append(applied, fmt.Sprintf("%s %q", verb, a.Value))
+	}
+	msg = fmt.Sprintf("%s. Applied before the failure: %s", msg, strings.Join(applied, ", "))
+
+	if e.RolledBack() {
+		return msg + ". The applied changes were rolled back"
+	}
+	msg = fmt.Sprintf("%s. Rolling back the applied changes failed: %s", msg, e.RollbackErr)
+	if e.Actual != nil && len(e.Actual) == 0 {
+		msg += ". The record set is now empty"
+	} else if e.Actual != nil {
+		msg = fmt.Sprintf("%s. The record set now holds: %s", msg, strings.Join(e.Actual, ", "))
+	}
+	return msg
+}
+
+type recordOperation struct {
+	operation string
+	value     string
+	cmd       string
+}
+
+// recordBatch collects the adds and removes for a record set, so they can be applied in as few PowerShell invocations
+// as possible.
+type recordBatch struct {
+	record     *Record
+	operations []recordOperation
+}
+
+func (r *Record) newBatch() *recordBatch {
+	return &recordBatch{record: r}
+}
+
+func (b *recordBatch) add(conf *config.ProviderConf, recordData string) error {
+	cmd, err := b.record.addRecordDataCommand(conf, recordData)
+	if err != nil {
+		return err
+	}
+	b.operations = append(b.operations, recordOperation{operation: RecordOperationAdd, value: recordData, cmd: cmd})
+	return nil
+}
+
+func (b *recordBatch) remove(conf *config.ProviderConf, recordData string) error {
+	cmd, err := b.record.removeRecordDataCommand(conf, recordData)
+	if err != nil {
+		return err
+	}
+	b.operations = append(b.operations, recordOperation{operation: RecordOperationRemove, value: recordData, cmd: cmd})
+	return nil
+}
+
+// script returns a script running the operations at the given indexes, each in its own try/catch so a failing
+// value doesn't stop the others. The result of each operation is written as JSON, identified by its index in the batch.
+func (b *recordBatch) script(indexes []int) string {
+	lines := []string{
+		"$ErrorActionPreference = 'Stop'",
+		"$WarningPreference = 'SilentlyContinue'",
+		"@(",
+	}
+	for _, i := range indexes {
+		lines = append(lines, fmt.Sprintf("try { %s | Out-Null; @{ Index = %d; Error = $null } } catch { @{ Index = %d; Error = %s } }",
+			b.operations[i].cmd, i, i, psErrorObject))
+	}
+	lines = append(lines, ") | ConvertTo-Json -Compress")
+	return strings.Join(lines, "\n")
+}
+
+// batchResultJSON is the result of an operation as written by the batch script
+type batchResultJSON struct {
+	Index int              `json:"Index"`
+	Error *PowerShellError `json:"Error"`
+}
+
+// run applies the batch in as few scripts as the command line length allows, and returns the result of every
+// operation. Operations failing with a transient error are run again, as configured by max_retries and
+// retry_max_wait. A RecordBatchError is returned if any of the operations failed.
+func (b *recordBatch) run(ctx context.Context, conf *config.ProviderConf) ([]RecordOperationResult, error) {
+	if len(b.operations) == 0 {
+		return nil, nil
+	}
+
+	results := make([]RecordOperationResult, len(b.operations))
+	pending := make([]int, len(b.operations))
+	for i := range pending {
+		pending[i] = i
+	}
+	ran := false
+	err := withRetry(ctx, conf.Settings, func() (bool, error) {
+		chunks := b.chunks(conf, pending)
+		for n, chunk := range chunks {
+			err := b.runOperations(ctx, conf, chunk, results)
+			if err != nil && !ran {
+				return isTransientError(err, false), err
+			}
+			if err != nil {
+				// Some of the operations were applied by the scripts before, so the operations that weren't run
+				// fail with the error instead, and the applied ones can be rolled back
+				for _, c := range chunks[n:] {
+					for _, i := range c {
+						op := b.operations[i]
+						results[i] = RecordOperationResult{Operation: op.operation, Value: op.value, Error: err}
+					}
+				}
+				break
+			}
+			ran = true
+		}
+		var retry []int
+		for _, i := range pending {
+			if results[i].Error != nil && isTransientError(results[i].Error, false) {
+				retry = append(retry, i)
+			}
+		}
+		pending = retry
+		return len(retry) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []RecordOperationResult
+	for _, result := range results {
+		if result.Error != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) > 0 {
+		return results, &RecordBatchError{Failed: failed}
+	}
+	return results, nil
+}
+
+// command returns the command running the operations at the given indexes in one script.
+func (b *recordBatch) command(conf *config.ProviderConf, indexes []int) *PSCommand {
+	psOpts := CreatePSCommandOpts{
+		JSONOutput: false,
+		ForceArray: true,
+		// The failed operations are retried by run, which doesn't run the succeeded ones again
+		NoRetry:  true,
+		Username: conf.Settings.SshUsername,
+		Password: conf.Settings.SshPassword,
+	}
+	return NewPSCommand([]string{b.script(indexes)}, psOpts)
+}
+
+// chunks splits the operations at the given indexes into groups small enough to be run in one command line. An
+// operation too long to share a command line with others is run on its own.
+func (b *recordBatch) chunks(conf *config.ProviderConf, indexes []int) [][]int {
+	var chunks [][]int
+	var chunk []int
+	for _, i := range indexes {
+		if len(chunk) > 0 && config.CommandLineLength(b.command(conf, append(chunk, i)).String()) > config.MaxCommandLineLength {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+		chunk = append(chunk, i)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// runOperations runs the operations at the given indexes in one script, and stores their results in results.
+func (b *recordBatch) runOperations(ctx context.Context, conf *config.ProviderConf, indexes []int, results []RecordOperationResult) error {
+	result, err := b.command(conf, indexes).Run(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("updating the record set failed: %w", err)
+	}
+
+	var output []batchResultJSON
+	err = json.Unmarshal([]byte(result.Stdout), &output)
+	if err != nil {
+		return fmt.Errorf("failed while unmarshalling the record set update result: %s, output was: %s", err, result.Stdout)
+	}
+
+	seen := make(map[int]bool, len(indexes))
+	for _, o := range output {
+		if o.Index < 0 || o.Index >= len(b.operations) {
+			return fmt.Errorf("unexpected index %d in the record set update result", o.Index)
+		}
+		op := b.operations[o.Index]
+		results[o.Index] = RecordOperationResult{Operation: op.operation, Value: op.value}
+		if o.Error != nil {
+			results[o.Index].Error = o.Error
+		}
+		seen[o.Index] = true
+	}
+
+	for _, i := range indexes {
+		if !seen[i] {
+			op := b.operations[i]
+			results[i] = RecordOperationResult{Operation: op.operation, Value: op.value, Error: errors.New("no result was returned")}
+		}
+	}
+	return nil
+}
+
+// apply runs the batch, and rolls back the applied operations if any of them failed. Removed values are added back
+// with the settings of original.
+func (b *recordBatch) apply(ctx context.Context, conf *config.ProviderConf, original *Record) error {
+	results, err := b.run(ctx, conf)
+	var batchErr *RecordBatchError
+	if !errors.As(err, &batchErr) {
+		return err
+	}
+
+	updateErr := &RecordUpdateError{Failed: batchErr.Failed}
+	rollback := original.newBatch()
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		updateErr.Applied = append(updateErr.Applied, result)
+		if result.Operation == RecordOperationAdd {
+			err = rollback.remove(conf, result.Value)
+		} else {
+			err = rollback.add(conf, result.Value)
+		}
+		if err != nil {
+			updateErr.RollbackErr = err
+			break
+		}
+	}
+	if updateErr.RollbackErr == nil {
+		_, updateErr.RollbackErr = rollback.run(ctx, conf)
+	}
+
+	if updateErr.RollbackErr != nil {
+		actual, err := GetDNSRecordFromId(ctx, conf, b.record.Id())
+		if err == nil {
+			updateErr.Actual = actual.Records
+		} else if errors.Is(err, ErrNotFound) {
+			updateErr.Actual = []string{}
+		}
+	}
+	return updateErr
+}
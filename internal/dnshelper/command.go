@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// psCommandBuilder builds a cmdlet invocation. String values are written as single-quoted literals, where
+// PowerShell doesn't expand variables or escapes, so user data can't change the script it is part of.
+type psCommandBuilder struct {
+	parts []string
+}
+
+func newCommand(cmdlet string) *psCommandBuilder {
+	return &psCommandBuilder{parts: []string{cmdlet}}
+}
+
+// param adds a parameter with a string value.
+func (b *psCommandBuilder) param(name, value string) *psCommandBuilder {
+	b.parts = append(b.parts, "-"+name, quoteArg(value))
+	return b
+}
+
+// listParam adds a parameter with an array of strings, like -IPAddress '192.0.2.1', '192.0.2.2'.
+func (b *psCommandBuilder) listParam(name string, values []string) *psCommandBuilder {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quoteArg(v)
+	}
+	b.parts = append(b.parts, "-"+name, strings.Join(quoted, ", "))
+	return b
+}
+
+// intParam adds a parameter with a number.
+func (b *psCommandBuilder) intParam(name string, value int64) *psCommandBuilder {
+	b.parts = append(b.parts, "-"+name, fmt.Sprintf("%d", value))
+	return b
+}
+
+// switchParam adds a switch parameter, like -Force.
+func (b *psCommandBuilder) switchParam(name string) *psCommandBuilder {
+	b.parts = append(b.parts, "-"+name)
+	return b
+}
+
+// exprParam adds a parameter with a PowerShell expression as its value. The expression must not hold user data.
+func (b *psCommandBuilder) exprParam(name, expr string) *psCommandBuilder {
+	b.parts = append(b.parts, "-"+name, expr)
+	return b
+}
+
+// computerName adds -ComputerName for the configured DNS server, if any.
+func (b *psCommandBuilder) computerName(conf *config.ProviderConf) *psCommandBuilder {
+	if conf.Settings.DnsServer == "" {
+		return b
+	}
+	return b.param("ComputerName", conf.Settings.DnsServer)
+}
+
+func (b *psCommandBuilder) String() string {
+	return strings.Join(b.parts, " ")
+}
+
+// quoteArg returns the value as a single-quoted PowerShell string literal. Single quotes are doubled, including the
+// typographic quotes PowerShell also accepts as single quotes, which is the only escaping such literals have.
+func quoteArg(value string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, c := range value {
+		if isSingleQuote(c) {
+			b.WriteRune(c)
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func isSingleQuote(c rune) bool {
+	switch c {
+	case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/exp/slices"
+)
+
+// injectionSeeds are values that would break out of, or be expanded in, a bare or double-quoted argument
+var injectionSeeds = []string{
+	"v=spf1 include:example.com ~all",
+	`"; Remove-DnsServerZone -Name example.com -Force; "`,
+	"'; Remove-DnsServerZone -Name example.com -Force; '",
+	"’; Remove-DnsServerZone -Name example.com -Force; ‘",
+	"''",
+	"$env:COMPUTERNAME $(Remove-DnsServerZone -Name example.com -Force)",
+	"`'`; a",
+	"a | Remove-DnsServerZone -Name example.com -Force",
+	"line1\nRemove-DnsServerZone -Name example.com -Force",
+	"@{ a = 1 } (1) {2}",
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"example.com", `'example.com'`},
+		{"", `''`},
+		{"it's", `'it''s'`},
+		{`a "b" $c`, `'a "b" $c'`},
+		{"it’s", "'it’’s'"},
+	}
+	for _, tt := range tests {
+		if got := quoteArg(tt.value); got != tt.want {
+			t.Errorf("quoteArg(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPSCommandBuilder(t *testing.T) {
+	conf := config.NewProviderConf(&config.Settings{DnsServer: "dc1.example.com"})
+	cmd := newCommand("Remove-DnsServerResourceRecord").switchParam("Force").param("Name", "r1").
+		intParam("Preference", 10).exprParam("TimeToLive", "([System.TimeSpan]::FromSeconds(300))").
+		listParam("IPAddress", []string{"203.0.113.53", "it's"}).computerName(conf)
+
+	want := "Remove-DnsServerResourceRecord -Force -Name 'r1' -Preference 10 -TimeToLive ([System.TimeSpan]::FromSeconds(300)) -IPAddress '203.0.113.53', 'it''s' -ComputerName 'dc1.example.com'"
+	if cmd.String() != want {
+		t.Errorf("String() = %q, want %q", cmd, want)
+	}
+}
+
+// unquoteArg reads a single-quoted literal at the start of a script the way the PowerShell tokenizer does, and
+// returns its value and the rest of the script.
+func unquoteArg(script string) (string, string, bool) {
+	runes := []rune(script)
+	if len(runes) == 0 || !isSingleQuote(runes[0]) {
+		return "", "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(runes); i++ {
+		if isSingleQuote(runes[i]) {
+			if i+1 < len(runes) && isSingleQuote(runes[i+1]) {
+				b.WriteRune(runes[i+1])
+				i++
+				continue
+			}
+			return b.String(), string(runes[i+1:]), true
+		}
+		b.WriteRune(runes[i])
+	}
+	return "", "", false
+}
+
+func FuzzQuoteArg(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if !utf8.ValidString(value) {
+			t.Skip()
+		}
+		// The literal must end where quoteArg ends it, so the text after it is still a separate argument
+		got, rest, ok := unquoteArg(quoteArg(value) + " -Force")
+		if !ok || got != value || rest != " -Force" {
+			t.Errorf("quoteArg(%q) is read as %q followed by %q", value, got, rest)
+		}
+	})
+}
+
+// serverExecutor runs scripts on a fake DNS server in-process. Like the SSH and WinRM executors, it fails for
+// scripts too long for a command line.
+type serverExecutor struct {
+	server *fakedns.Server
+}
+
+func (e *serverExecutor) Execute(ctx context.Context, script string) (*config.ExecuteResult, error) {
+	if config.CommandLineLength(script) > config.MaxCommandLineLength {
+		return &config.ExecuteResult{StdErr: "The command line is too long.\r\n", ExitCode: 1}, nil
+	}
+	result := e.server.Run(script)
+	return &config.ExecuteResult{Stdout: result.Stdout, StdErr: result.Stderr, ExitCode: result.ExitCode}, nil
+}
+
+func FuzzRecord_CreateTXT(f *testing.F) {
+	for _, seed := range injectionSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		value, err := SanitizeInputString(RecordTypeTXT, value)
+		if err != nil || value == "" || !utf8.ValidString(value) {
+			t.Skip()
+		}
+
+		server := fakedns.NewServer("dc1")
+		for _, zone := range []string{"example.com", "example.org"} {
+			if err := server.AddZone(zone); err != nil {
+				t.Fatal(err)
+			}
+		}
+		conf := config.NewProviderConf(&config.Settings{})
+		conf.Executor = &serverExecutor{server: server}
+
+		r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeTXT, Records: []string{value}}
+		id, err := r.Create(context.Background(), conf)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		got, err := GetDNSRecordFromId(context.Background(), conf, id)
+		if err != nil {
+			t.Fatalf("GetDNSRecordFromId() error = %v", err)
+		}
+		if !slices.Equal(got.Records, []string{value}) {
+			t.Errorf("GetDNSRecordFromId() = %q, want %q", got.Records, value)
+		}
+
+		// The value must not have run any other command
+		if zones := server.Zones(); !slices.Equal(zones, []string{"example.com", "example.org"}) {
+			t.Errorf("zones = %q after creating the record", zones)
+		}
+		if n := len(server.Zone("example.com").Records); n != 1 {
+			t.Errorf("example.com has %d records, want 1", n)
+		}
+
+		if err := r.Delete(context.Background(), conf); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if n := len(server.Zone("example.com").Records); n != 0 {
+			t.Errorf("example.com has %d records after the delete, want 0", n)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"golang.org/x/exp/slices"
+)
+
+// ZoneDelegation is a child zone delegated to other name servers, e.g. a subdomain run by another team.
+type ZoneDelegation struct {
+	ZoneName      string
+	ChildZoneName string
+	NameServers   []DelegationNameServer
+}
+
+// DelegationNameServer is a name server of a delegated zone, with the glue addresses the parent zone holds for it.
+type DelegationNameServer struct {
+	Name        string
+	IPAddresses []string
+}
+
+// dnsZoneDelegation is a DnsServerZoneDelegation object, which describes one of the name servers of a delegation.
+type dnsZoneDelegation struct {
+	ChildZoneName string      `json:"ChildZoneName"`
+	NameServer    DNSRecord   `json:"NameServer"`
+	IPAddress     []DNSRecord `json:"IPAddress"`
+}
+
+// Id returns the child zone name and the zone name. The child zone name may contain the separator, as in
+// _msdcs_example.com, so the id is split on the last separator.
+func (z *ZoneDelegation) Id() string {
+	return strings.Join([]string{z.ChildZoneName, z.ZoneName}, IDSeparator)
+}
+
+func parseZoneDelegationId(id string) (childZoneName, zoneName string, err error) {
+	i := strings.LastIndex(id, IDSeparator)
+	if i <= 0 || i == len(id)-1 {
+		return "", "", fmt.Errorf("invalid zone delegation id %q, expected <name>%s<zone_name>", id, IDSeparator)
+	}
+	return id[:i], id[i+1:], nil
+}
+
+// NewZoneDelegationFromResource returns a new ZoneDelegation struct populated from resource data
+func NewZoneDelegationFromResource(d *schema.ResourceData) (*ZoneDelegation, error) {
+	zoneName, err := SanitiseTFInput(d, "zone_name")
+	if err != nil {
+		return nil, err
+	}
+	childZoneName, err := SanitiseTFInput(d, "name")
+	if err != nil {
+		return nil, err
+	}
+
+	delegation := &ZoneDelegation{ZoneName: zoneName, ChildZoneName: childZoneName}
+	for _, v := range d.Get("name_server").(*schema.Set).List() {
+		m := v.(map[string]interface{})
+		name, err := SanitizeInputString("", m["name"].(string))
+		if err != nil {
+			return nil, err
+		}
+		ns := DelegationNameServer{Name: name}
+		for _, ip := range m["ip_addresses"].(*schema.Set).List() {
+			sanitized, err := SanitizeInputString("", ip.(string))
+			if err != nil {
+				return nil, err
+			}
+			ns.IPAddresses = append(ns.IPAddresses, sanitized)
+		}
+		delegation.NameServers = append(delegation.NameServers, ns)
+	}
+	sortNameServers(delegation.NameServers)
+	return delegation, nil
+}
+
+func GetZoneDelegationFromId(ctx context.Context, conf *config.ProviderConf, id string) (*ZoneDelegation, error) {
+	childZoneName, zoneName, err := parseZoneDelegationId(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := newCommand("Get-DnsServerZoneDelegation").param("Name", zoneName).param("ChildZoneName", childZoneName)
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: true,
+		JSONDepth:  6,
+		ForceArray: true,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	result, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("Get-DnsServerZoneDelegation failed: %w", err)
+	}
+
+	delegation, err := unmarshallZoneDelegation(ctx, []byte(result.Stdout))
+	if err != nil {
+		return nil, fmt.Errorf("GetZoneDelegationFromId: %w", err)
+	}
+	delegation.ZoneName = zoneName
+	delegation.ChildZoneName = childZoneName
+	return delegation, nil
+}
+
+// Create delegates the child zone to the name servers
+func (z *ZoneDelegation) Create(ctx context.Context, conf *config.ProviderConf) (string, error) {
+	if z.ZoneName == "" || z.ChildZoneName == "" {
+		return "", fmt.Errorf("ZoneDelegation.Create: missing zone_name or name variable")
+	}
+	if len(z.NameServers) == 0 {
+		return "", fmt.Errorf("ZoneDelegation.Create: missing name_server variable")
+	}
+
+	var cmds []string
+	for _, ns := range z.NameServers {
+		cmds = append(cmds, z.addNameServerCommand(conf, ns))
+	}
+	err := z.run(ctx, conf, cmds)
+	if err != nil {
+		return "", fmt.Errorf("Add-DnsServerZoneDelegation failed: %w", err)
+	}
+	return z.Id(), nil
+}
+
+// Update changes the name servers of the delegation from the existing ones. Name servers are added before any are
+// removed, so the delegation isn't removed while it is updated. The glue addresses of a name server are replaced
+// with Set-DnsServerZoneDelegation. It needs at least one address, so a name server whose glue addresses are all
+// removed is removed and added again, after the other name servers are added.
+func (z *ZoneDelegation) Update(ctx context.Context, conf *config.ProviderConf, existing *ZoneDelegation) error {
+	var added, changed, removed []string
+	for _, ns := range z.NameServers {
+		old := findNameServer(existing.NameServers, ns.Name)
+		if old == nil {
+			added = append(added, z.addNameServerCommand(conf, ns))
+		} else if sameIPAddresses(old.IPAddresses, ns.IPAddresses) {
+			continue
+		} else if len(ns.IPAddresses) > 0 {
+			changed = append(changed, z.setNameServerCommand(conf, ns))
+		} else {
+			changed = append(changed, z.removeNameServerCommand(conf, *old), z.addNameServerCommand(conf, ns))
+		}
+	}
+	for _, ns := range existing.NameServers {
+		if findNameServer(z.NameServers, ns.Name) == nil {
+			removed = append(removed, z.removeNameServerCommand(conf, ns))
+		}
+	}
+
+	cmds := append(append(added, changed...), removed...)
+	if len(cmds) == 0 {
+		return nil
+	}
+	err := z.run(ctx, conf, cmds)
+	if err != nil {
+		return fmt.Errorf("updating the name servers of the zone delegation failed: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the delegation with all its name servers
+func (z *ZoneDelegation) Delete(ctx context.Context, conf *config.ProviderConf) error {
+	cmd := newCommand("Remove-DnsServerZoneDelegation").switchParam("Force").param("Name", z.ZoneName).
+		param("ChildZoneName", z.ChildZoneName).computerName(conf)
+	err := z.run(ctx, conf, []string{cmd.String()})
+	if err != nil {
+		return fmt.Errorf("Remove-DnsServerZoneDelegation failed: %w", err)
+	}
+	return nil
+}
+
+func (z *ZoneDelegation) addNameServerCommand(conf *config.ProviderConf, ns DelegationNameServer) string {
+	cmd := newCommand("Add-DnsServerZoneDelegation").param("Name", z.ZoneName).param("ChildZoneName", z.ChildZoneName).
+		param("NameServer", ns.Name)
+	if len(ns.IPAddresses) > 0 {
+		cmd.listParam("IPAddress", ns.IPAddresses)
+	}
+	return cmd.computerName(conf).String()
+}
+
+func (z *ZoneDelegation) setNameServerCommand(conf *config.ProviderConf, ns DelegationNameServer) string {
+	return newCommand("Set-DnsServerZoneDelegation").param("Name", z.ZoneName).param("ChildZoneName", z.ChildZoneName).
+		param("NameServer", ns.Name).listParam("IPAddress", ns.IPAddresses).computerName(conf).String()
+}
+
+func (z *ZoneDelegation) removeNameServerCommand(conf *config.ProviderConf, ns DelegationNameServer) string {
+	return newCommand("Remove-DnsServerZoneDelegation").switchParam("Force").param("Name", z.ZoneName).
+		param("ChildZoneName", z.ChildZoneName).param("NameServer", ns.Name).computerName(conf).String()
+}
+
+// run runs the commands in one script. Every command has its own -ComputerName, rather than using
+// CreatePSCommandOpts.Server, which is only added to the last command.
+func (z *ZoneDelegation) run(ctx context.Context, conf *config.ProviderConf, cmds []string) error {
+	psOpts := CreatePSCommandOpts{
+		JSONOutput: false,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+	}
+	psCmd := NewPSCommand([]string{strings.Join(cmds, "; ")}, psOpts)
+
+	_, err := psCmd.Run(ctx, conf)
+	return err
+}
+
+func unmarshallZoneDelegation(ctx context.Context, input []byte) (*ZoneDelegation, error) {
+	var delegations []dnsZoneDelegation
+
+	t := bytes.TrimSpace(input)
+	if len(t) == 0 {
+		return nil, fmt.Errorf("zone delegation %w", ErrNotFound)
+	}
+
+	err := json.Unmarshal(t, &delegations)
+	if err != nil {
+		tflog.Debug(ctx, fmt.Sprintf("Failed to unmarshall a ZoneDelegation json document with error %q, document was %s", err, string(input)))
+		return nil, fmt.Errorf("failed while unmarshalling ZoneDelegation json document: %s", err)
+	}
+	if len(delegations) == 0 {
+		return nil, fmt.Errorf("zone delegation %w", ErrNotFound)
+	}
+
+	delegation := &ZoneDelegation{}
+	for _, d := range delegations {
+		name, err := recordDataFromProperties(RecordTypeNS, d.NameServer.RecordData.CimInstanceProperties)
+		if err != nil {
+			return nil, err
+		}
+		ns := DelegationNameServer{Name: name}
+		for _, ip := range d.IPAddress {
+			address, err := recordDataFromProperties(ip.RecordType, ip.RecordData.CimInstanceProperties)
+			if err != nil {
+				return nil, err
+			}
+			ns.IPAddresses = append(ns.IPAddresses, address)
+		}
+		delegation.NameServers = append(delegation.NameServers, ns)
+	}
+	sortNameServers(delegation.NameServers)
+	return delegation, nil
+}
+
+// NameServerKey returns the name of a name server as the DNS server compares them, without the trailing dot it
+// adds to names, and ignoring case.
+func NameServerKey(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// IPAddressKey returns an IP address in its canonical form, so differently written IPv6 addresses compare equal.
+func IPAddressKey(address string) string {
+	if ip := net.ParseIP(address); ip != nil {
+		return ip.String()
+	}
+	return address
+}
+
+func findNameServer(nameServers []DelegationNameServer, name string) *DelegationNameServer {
+	for i := range nameServers {
+		if NameServerKey(nameServers[i].Name) == NameServerKey(name) {
+			return &nameServers[i]
+		}
+	}
+	return nil
+}
+
+func sameIPAddresses(a, b []string) bool {
+	keys := func(addresses []string) []string {
+		var k []string
+		for _, address := range addresses {
+			k = append(k, IPAddressKey(address))
+		}
+		slices.Sort(k)
+		return k
+	}
+	return slices.Equal(keys(a), keys(b))
+}
+
+func sortNameServers(nameServers []DelegationNameServer) {
+	sort.Slice(nameServers, func(i, j int) bool {
+		return NameServerKey(nameServers[i].Name) < NameServerKey(nameServers[j].Name)
+	})
+}
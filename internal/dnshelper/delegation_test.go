@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+)
+
+func Test_parseZoneDelegationId(t *testing.T) {
+	tests := []struct {
+		id            string
+		wantChildZone string
+		wantZone      string
+		wantErr       bool
+	}{
+		{"team_example.com", "team", "example.com", false},
+		{"_msdcs_example.com", "_msdcs", "example.com", false},
+		{"example.com", "", "", true},
+		{"team_", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			childZone, zone, err := parseZoneDelegationId(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseZoneDelegationId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if childZone != tt.wantChildZone || zone != tt.wantZone {
+				t.Errorf("parseZoneDelegationId() = %q, %q, want %q, %q", childZone, zone, tt.wantChildZone, tt.wantZone)
+			}
+		})
+	}
+}
+
+func Test_unmarshallZoneDelegation(t *testing.T) {
+	input := `[{"ChildZoneName":"team.example.com","NameServer":{"HostName":"team","RecordType":"NS","RecordData":{"CimInstanceProperties":[{"Name":"NameServer","Value":"ns2.example.net."}]}},"IPAddress":null},
+{"ChildZoneName":"team.example.com","NameServer":{"HostName":"team","RecordType":"NS","RecordData":{"CimInstanceProperties":[{"Name":"NameServer","Value":"ns1.team.example.com."}]}},
+"IPAddress":[{"HostName":"ns1.team","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.53"}]}},
+{"HostName":"ns1.team","RecordType":"AAAA","RecordData":{"CimInstanceProperties":[{"Name":"IPv6Address","Value":"2001:db8::53"}]}}]}]`
+
+	got, err := unmarshallZoneDelegation(context.Background(), []byte(input))
+	if err != nil {
+		t.Fatalf("unmarshallZoneDelegation() error = %v", err)
+	}
+	want := []DelegationNameServer{
+		{Name: "ns1.team.example.com.", IPAddresses: []string{"203.0.113.53", "2001:db8::53"}},
+		{Name: "ns2.example.net."},
+	}
+	if !reflect.DeepEqual(got.NameServers, want) {
+		t.Errorf("unmarshallZoneDelegation() = %+v, want %+v", got.NameServers, want)
+	}
+
+	_, err = unmarshallZoneDelegation(context.Background(), []byte(""))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unmarshallZoneDelegation() error = %v for empty output, want %v", err, ErrNotFound)
+	}
+}
+
+func TestZoneDelegation_CreateUpdateDelete(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("try \\{ Remove-DnsServerZoneDelegation -Force -Name 'example.com' -ChildZoneName 'team' -ComputerName", "")
+	executor.On("Add-DnsServerZoneDelegation ", "")
+	conf := newTestProviderConf(executor)
+
+	delegation := &ZoneDelegation{ZoneName: "example.com", ChildZoneName: "team", NameServers: []DelegationNameServer{
+		{Name: "ns1.team.example.com", IPAddresses: []string{"203.0.113.53"}},
+		{Name: "ns2.example.net"},
+	}}
+	id, err := delegation.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if id != "team_example.com" {
+		t.Errorf("Create() id = %q", id)
+	}
+
+	existing := &ZoneDelegation{ZoneName: "example.com", ChildZoneName: "team", NameServers: []DelegationNameServer{
+		{Name: "ns1.team.example.com.", IPAddresses: []string{"203.0.113.53"}},
+		{Name: "ns2.example.net.", IPAddresses: []string{"2001:DB8::53"}},
+		{Name: "ns3.example.net."},
+		{Name: "ns5.example.net.", IPAddresses: []string{"203.0.113.55"}},
+	}}
+	delegation.NameServers = []DelegationNameServer{
+		{Name: "ns1.team.example.com", IPAddresses: []string{"203.0.113.53"}},
+		{Name: "ns2.example.net", IPAddresses: []string{"2001:db8::53", "203.0.113.54"}},
+		{Name: "ns4.example.net"},
+		{Name: "ns5.example.net"},
+	}
+	err = delegation.Update(context.Background(), conf, existing)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// Nothing is run when the name servers only differ in the trailing dot and how addresses are written
+	existing.NameServers = []DelegationNameServer{
+		{Name: "NS1.team.example.com.", IPAddresses: []string{"203.0.113.53"}},
+		{Name: "ns2.example.net.", IPAddresses: []string{"203.0.113.54", "2001:DB8:0::53"}},
+		{Name: "ns4.example.net."},
+		{Name: "ns5.example.net."},
+	}
+	err = delegation.Update(context.Background(), conf, existing)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	err = delegation.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{
+			"Add-DnsServerZoneDelegation -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns1.team.example.com' -IPAddress '203.0.113.53' -ComputerName 'dc1.example.com'; ",
+			"Add-DnsServerZoneDelegation -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns2.example.net' -ComputerName 'dc1.example.com' }",
+		},
+		{
+			"try { Add-DnsServerZoneDelegation -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns4.example.net' -ComputerName 'dc1.example.com'; " +
+				"Set-DnsServerZoneDelegation -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns2.example.net' -IPAddress '2001:db8::53', '203.0.113.54' -ComputerName 'dc1.example.com'; " +
+				"Remove-DnsServerZoneDelegation -Force -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns5.example.net.' -ComputerName 'dc1.example.com'; " +
+				"Add-DnsServerZoneDelegation -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns5.example.net' -ComputerName 'dc1.example.com'; " +
+				"Remove-DnsServerZoneDelegation -Force -Name 'example.com' -ChildZoneName 'team' -NameServer 'ns3.example.net.' -ComputerName 'dc1.example.com' }",
+		},
+		{"Remove-DnsServerZoneDelegation -Force -Name 'example.com' -ChildZoneName 'team' -ComputerName 'dc1.example.com' }"},
+	})
+}
+
+func TestZoneDelegation_Lifecycle(t *testing.T) {
+	server := fakedns.NewServer("dc1")
+	if err := server.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.NewProviderConf(&config.Settings{})
+	conf.Executor = &serverExecutor{server: server}
+	ctx := context.Background()
+
+	delegation := &ZoneDelegation{ZoneName: "example.com", ChildZoneName: "team", NameServers: []DelegationNameServer{
+		{Name: "ns1.team.example.com", IPAddresses: []string{"203.0.113.53", "2001:DB8::53"}},
+	}}
+	id, err := delegation.Create(ctx, conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	existing, err := GetZoneDelegationFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatalf("GetZoneDelegationFromId() error = %v", err)
+	}
+	want := []DelegationNameServer{{Name: "ns1.team.example.com.", IPAddresses: []string{"203.0.113.53", "2001:db8::53"}}}
+	if !reflect.DeepEqual(existing.NameServers, want) {
+		t.Errorf("GetZoneDelegationFromId() = %+v, want %+v", existing.NameServers, want)
+	}
+
+	delegation.NameServers = []DelegationNameServer{
+		{Name: "ns1.team.example.com", IPAddresses: []string{"203.0.113.54"}},
+		{Name: "ns.example.net"},
+	}
+	if err := delegation.Update(ctx, conf, existing); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := GetZoneDelegationFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatalf("GetZoneDelegationFromId() error = %v", err)
+	}
+	want = []DelegationNameServer{
+		{Name: "ns.example.net."},
+		{Name: "ns1.team.example.com.", IPAddresses: []string{"203.0.113.54"}},
+	}
+	if !reflect.DeepEqual(got.NameServers, want) {
+		t.Errorf("GetZoneDelegationFromId() = %+v after the update, want %+v", got.NameServers, want)
+	}
+
+	if err := delegation.Delete(ctx, conf); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	_, err = GetZoneDelegationFromId(ctx, conf, id)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetZoneDelegationFromId() error = %v after the delete, want %v", err, ErrNotFound)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -23,22 +24,39 @@ const (
 	RecordTypeTXT   = "TXT"
 	RecordTypePTR   = "PTR"
 	RecordTypeCNAME = "CNAME"
+	RecordTypeMX    = "MX"
+	RecordTypeSRV   = "SRV"
+	RecordTypeNS    = "NS"
+	RecordTypeSOA   = "SOA"
+	RecordTypeCAA   = "CAA"
+
+	// caaTypeNumber is the number of the CAA record type. Older DNS servers don't know the type, so CAA records are
+	// added and looked up by number, and returned with an unknown record type.
+	caaTypeNumber = 257
 )
 
+// recordTypes are the record types the record data can be read for
+var recordTypes = []string{RecordTypeA, RecordTypeAAAA, RecordTypeTXT, RecordTypePTR, RecordTypeCNAME, RecordTypeMX,
+	RecordTypeSRV, RecordTypeNS, RecordTypeSOA, RecordTypeCAA}
+
 type Record struct {
 	ZoneName   string   `json:"ZoneName"`
 	HostName   string   `json:"HostName"`
 	RecordType string   `json:"RecordType"`
 	Records    []string `json:"Records"`
 	CreatePtr  bool     `json:"CreatePtr"`
+	TTL        int64    `json:"TTL"`
+	Timestamp  string   `json:"Timestamp"`
 }
 
 type DNSRecord struct {
 	HostName   string     `json:"HostName"`
 	RecordType string     `json:"RecordType"`
+	Type       uint16     `json:"Type"`
 	DN         string     `json:"DistinguishedName"`
 	RecordData RecordData `json:"RecordData"`
 	TimeToLive TTL        `json:"TimeToLive"`
+	Timestamp  Timestamp  `json:"Timestamp"`
 }
 
 // The structure we get from powershell contains more fields, but we're only interested in CimInstanceProperties.
@@ -46,29 +64,116 @@ type RecordData struct {
 	CimInstanceProperties []CimInstanceProperties `json:"CimInstanceProperties"`
 }
 
-// The structure we get from powershell contains more fields, but we're only interested in the Value.
+// The structure we get from powershell contains more fields, but we're only interested in the Name and Value.
 type CimInstanceProperties struct {
+	Name  string `json:"Name"`
 	Value string `json:"value"`
 }
 
+// UnmarshalJSON handles that Value is a string for most properties, but a number for properties like the MX preference.
+func (c *CimInstanceProperties) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name  string          `json:"Name"`
+		Value json.RawMessage `json:"value"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	c.Name = raw.Name
+
+	value := bytes.TrimSpace(raw.Value)
+	if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+		c.Value = ""
+		return nil
+	}
+	if value[0] == '"' {
+		return json.Unmarshal(value, &c.Value)
+	}
+	c.Value = string(value)
+	return nil
+}
+
 // The structure we get from powershell contains more fields, but we're only interested in TotalSeconds.
 type TTL struct {
 	TotalSeconds int64 `json:"TotalSeconds"`
 }
 
+// Timestamp is the time a dynamic record was last refreshed. It is empty for static records.
+// Windows PowerShell serializes DateTime as "/Date(<ms>)/", either directly or wrapped in an object with a value
+// field, while PowerShell 7 uses ISO 8601. The timestamp is normalized to RFC 3339 in UTC.
+type Timestamp string
+
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		*ts = ""
+		return nil
+	}
+
+	var raw string
+	if data[0] == '{' {
+		var wrapped struct {
+			Value *string `json:"value"`
+		}
+		err := json.Unmarshal(data, &wrapped)
+		if err != nil {
+			return err
+		}
+		if wrapped.Value == nil {
+			*ts = ""
+			return nil
+		}
+		raw = *wrapped.Value
+	} else {
+		err := json.Unmarshal(data, &raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	if strings.HasPrefix(raw, "/Date(") && strings.HasSuffix(raw, ")/") {
+		value := strings.TrimSuffix(strings.TrimPrefix(raw, "/Date("), ")/")
+		// The value may have a time zone offset, e.g. /Date(1700000000000+0100)/, which doesn't change the instant.
+		if i := strings.IndexAny(value, "+-"); i > 0 {
+			value = value[:i]
+		}
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %s", raw, err)
+		}
+		*ts = Timestamp(time.UnixMilli(ms).UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q: %s", raw, err)
+	}
+	*ts = Timestamp(t.UTC().Format(time.RFC3339))
+	return nil
+}
+
 // windns has no concept of primary key so we need to create one based on inputs
 func (r *Record) Id() string {
 	return strings.Join([]string{r.HostName, r.ZoneName, r.RecordType, strconv.FormatBool(r.CreatePtr)}, IDSeparator)
 }
 
-// NewDNSRecordFromResource returns a new Record struct populated from resource data
+// NewDNSRecordFromResource returns a new Record struct populated from resource data. The values are taken from the
+// structured record data blocks when the records list is empty, which it is until it is computed from the blocks.
 func NewDNSRecordFromResource(d *schema.ResourceData) (*Record, error) {
 	var records []string
-	recordsSet := d.Get("records").(*schema.Set)
 	recordType := d.Get("type").(string)
+	var values []string
+	for _, v := range d.Get("records").(*schema.Set).List() {
+		values = append(values, v.(string))
+	}
+	if block := RecordDataBlock(recordType); block != "" && len(values) == 0 {
+		values = RecordDataFromBlocks(recordType, d.Get(block).(*schema.Set).List())
+	}
 
-	for _, v := range recordsSet.List() {
-		sanitizedInput, err := SanitizeInputString(recordType, v.(string))
+	for _, v := range values {
+		sanitizedInput, err := SanitizeInputString(recordType, v)
 		if err != nil {
 			return nil, err
 		}
@@ -93,36 +198,24 @@ func NewDNSRecordFromResource(d *schema.ResourceData) (*Record, error) {
 		HostName:   sanitizedHostName,
 		RecordType: sanitizedRecordType,
 		CreatePtr:  d.Get("create_ptr").(bool),
-		//		TTL:        d.Get("ttl").(int64),
-		Records: records,
+		TTL:        int64(d.Get("ttl").(int)),
+		Records:    records,
 	}, nil
 }
 
 func GetDNSRecordFromId(ctx context.Context, conf *config.ProviderConf, id string) (*Record, error) {
-	idComponents := strings.Split(id, IDSeparator)
-	hostName := idComponents[0]
-	zoneName := idComponents[1]
-	recordType := idComponents[2]
-	createPtr, err := strconv.ParseBool("false")
-
-	if len(idComponents) > 3 {
-		createPtr, err = strconv.ParseBool(idComponents[3])
-	}
+	hostName, zoneName, recordType, createPtr, err := parseRecordId(id)
 	if err != nil {
-		return nil, fmt.Errorf("unknown state for createPtr: %s", err)
+		return nil, err
 	}
 
 	// TODO better error handling here. Test import.
 
-	cmd := fmt.Sprintf("Get-DnsServerResourceRecord -ZoneName %s -Name %s -RRType %s", zoneName, hostName, recordType)
-
-	conn, err := conf.AcquireSshClient()
-	if err != nil {
-		return nil, fmt.Errorf("while acquiring ssh client: %s", err)
-	}
-	defer conf.ReleaseSshClient(conn)
+	cmd := newCommand("Get-DnsServerResourceRecord").param("ZoneName", zoneName).param("Name", hostName)
+	recordTypeParam(cmd, recordType)
 
 	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
 		JSONOutput: true,
 		JSONDepth:  4,
 		ForceArray: true,
@@ -130,15 +223,11 @@ func GetDNSRecordFromId(ctx context.Context, conf *config.ProviderConf, id strin
 		Password:   conf.Settings.SshPassword,
 		Server:     conf.Settings.DnsServer,
 	}
-	psCmd := NewPSCommand([]string{cmd}, psOpts)
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
 
-	result, err := psCmd.Run(conf)
+	result, err := psCmd.Run(ctx, conf)
 	if err != nil {
-		return nil, fmt.Errorf("ssh execution failure in GetDNSRecordFromId: %s", err)
-	}
-
-	if result.ExitCode != 0 {
-		return nil, fmt.Errorf("Get-DnsServerResourceRecord exited with a non zero exit code (%d), stderr: %s", result.ExitCode, result.StdErr)
+		return nil, fmt.Errorf("Get-DnsServerResourceRecord failed: %w", err)
 	}
 
 	record, err := unmarshallRecord(ctx, []byte(result.Stdout))
@@ -151,8 +240,28 @@ func GetDNSRecordFromId(ctx context.Context, conf *config.ProviderConf, id strin
 	return record, nil
 }
 
+// parseRecordId splits a record id into its components. The id is parsed from the end, since host names
+// like SRV owner names (e.g. _ldap._tcp) may contain the separator. Older ids don't include createPtr.
+func parseRecordId(id string) (hostName, zoneName, recordType string, createPtr bool, err error) {
+	idComponents := strings.Split(id, IDSeparator)
+	if len(idComponents) < 3 {
+		return "", "", "", false, fmt.Errorf("invalid record id %q", id)
+	}
+
+	last := len(idComponents) - 1
+	if v, parseErr := strconv.ParseBool(idComponents[last]); parseErr == nil && len(idComponents) > 3 {
+		createPtr = v
+		last--
+	}
+
+	recordType = idComponents[last]
+	zoneName = idComponents[last-1]
+	hostName = strings.Join(idComponents[:last-1], IDSeparator)
+	return hostName, zoneName, recordType, createPtr, nil
+}
+
 // Create creates a new DNSRecord object in DNS server
-func (r *Record) Create(conf *config.ProviderConf) (string, error) {
+func (r *Record) Create(ctx context.Context, conf *config.ProviderConf) (string, error) {
 	if r.ZoneName == "" {
 		return "", fmt.Errorf("DNSRecord.Create: missing zone_name variable")
 	}
@@ -169,12 +278,17 @@ func (r *Record) Create(conf *config.ProviderConf) (string, error) {
 		return "", fmt.Errorf("DNSRecord.Create: missing record variable")
 	}
 
+	batch := r.newBatch()
 	for _, recordData := range r.Records {
-		err := r.addRecordData(conf, recordData)
+		err := batch.add(conf, recordData)
 		if err != nil {
 			return "", err
 		}
 	}
+	err := batch.apply(ctx, conf, r)
+	if err != nil {
+		return "", err
+	}
 
 	// We don't get any unique ID from the create command, so we assume id is a composite of input variables.
 	return r.Id(), nil
@@ -186,11 +300,24 @@ func (r *Record) Update(ctx context.Context, conf *config.ProviderConf, changes
 	if err != nil {
 		return err
 	}
-	if changes["records"] == nil {
-		return nil
+	if changes["records"] != nil {
+		err = r.updateRecordData(ctx, conf, existing, changes["records"].(*schema.Set))
+		if err != nil {
+			return err
+		}
+	}
+
+	if changes["ttl"] != nil {
+		err = r.setTTL(ctx, conf)
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+func (r *Record) updateRecordData(ctx context.Context, conf *config.ProviderConf, existing *Record, expectedRecords *schema.Set) error {
 	var records []string
-	expectedRecords := changes["records"].(*schema.Set)
 
 	for _, v := range expectedRecords.List() {
 		sanitizedInput, err := SanitizeInputString(existing.RecordType, v.(string))
@@ -200,107 +327,172 @@ func (r *Record) Update(ctx context.Context, conf *config.ProviderConf, changes
 		records = append(records, sanitizedInput)
 	}
 
-	toAdd, toRemove := diffRecordLists(records, existing.Records)
+	toAdd, toRemove := diffRecordLists(existing.RecordType, records, existing.Records)
+	batch := r.newBatch()
 	for _, recordData := range toAdd {
-		err = r.addRecordData(conf, recordData)
+		err := batch.add(conf, recordData)
 		if err != nil {
 			return err
 		}
 	}
 
 	for _, recordData := range toRemove {
-		err = r.removeRecordData(conf, recordData)
+		err := batch.remove(conf, recordData)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	return batch.apply(ctx, conf, existing)
 }
 
 // Delete deletes an existing DNSRecord object in DNS server
-func (r *Record) Delete(conf *config.ProviderConf) error {
+func (r *Record) Delete(ctx context.Context, conf *config.ProviderConf) error {
+	batch := r.newBatch()
 	for _, recordData := range r.Records {
-		err := r.removeRecordData(conf, recordData)
+		err := batch.remove(conf, recordData)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	_, err := batch.run(ctx, conf)
+	return err
 }
 
-func (r *Record) addRecordData(conf *config.ProviderConf, recordData string) error {
-	cmd := fmt.Sprintf("Add-DNSServerResourceRecord -ZoneName %s -name %s -%s", r.ZoneName, r.HostName, r.RecordType)
+// addRecordDataCommand returns the command adding a value to the record set.
+func (r *Record) addRecordDataCommand(conf *config.ProviderConf, recordData string) (string, error) {
+	cmd := newCommand("Add-DNSServerResourceRecord").param("ZoneName", r.ZoneName).param("Name", r.HostName)
+
+	if r.RecordType != RecordTypeCAA {
+		cmd.switchParam(r.RecordType)
+	}
 
 	if r.RecordType == RecordTypeA {
-		cmd = fmt.Sprintf("%s -IPv4Address %s", cmd, recordData)
+		cmd.param("IPv4Address", recordData)
 	} else if r.RecordType == RecordTypeAAAA {
-		cmd = fmt.Sprintf("%s -IPv6Address %s", cmd, strings.ToLower(recordData))
+		cmd.param("IPv6Address", strings.ToLower(recordData))
 	} else if r.RecordType == RecordTypeTXT {
-		cmd = fmt.Sprintf("%s -DescriptiveText \"%s\"", cmd, recordData)
+		cmd.param("DescriptiveText", strings.Join(splitTXTRecordData(recordData), "\n"))
 	} else if r.RecordType == RecordTypePTR {
-		cmd = fmt.Sprintf("%s -PtrDomainName %s", cmd, recordData)
+		cmd.param("PtrDomainName", recordData)
 	} else if r.RecordType == RecordTypeCNAME {
-		cmd = fmt.Sprintf("%s -HostNameAlias %s", cmd, recordData)
+		cmd.param("HostNameAlias", recordData)
+	} else if r.RecordType == RecordTypeNS {
+		cmd.param("NameServer", recordData)
+	} else if r.RecordType == RecordTypeMX {
+		mx, err := ParseMXRecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		cmd.param("MailExchange", mx.Exchange).intParam("Preference", int64(mx.Preference))
+	} else if r.RecordType == RecordTypeSRV {
+		srv, err := ParseSRVRecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		cmd.param("DomainName", srv.Target).intParam("Priority", int64(srv.Priority)).intParam("Weight", int64(srv.Weight)).intParam("Port", int64(srv.Port))
+	} else if r.RecordType == RecordTypeCAA {
+		caa, err := ParseCAARecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		cmd.intParam("Type", caaTypeNumber).param("RecordData", caa.Hex())
 	} else {
-		return fmt.Errorf("record type %s is not supported", r.RecordType)
+		return "", fmt.Errorf("record type %s is not supported", r.RecordType)
 	}
 
 	if (r.RecordType == RecordTypeA || r.RecordType == RecordTypeAAAA) && r.CreatePtr {
-		cmd = fmt.Sprintf("%s -CreatePtr", cmd)
+		cmd.switchParam("CreatePtr")
 	}
 
-	psOpts := CreatePSCommandOpts{
-		JSONOutput: false,
-		ForceArray: false,
-		Username:   conf.Settings.SshUsername,
-		Password:   conf.Settings.SshPassword,
-		Server:     conf.Settings.DnsServer,
+	if r.TTL > 0 {
+		cmd.exprParam("TimeToLive", fmt.Sprintf("([System.TimeSpan]::FromSeconds(%d))", r.TTL))
 	}
-	psCmd := NewPSCommand([]string{cmd}, psOpts)
+	return cmd.computerName(conf).String(), nil
+}
 
-	result, err := psCmd.Run(conf)
+// removeRecordDataCommand returns the command removing a value from the record set.
+func (r *Record) removeRecordDataCommand(conf *config.ProviderConf, recordData string) (string, error) {
+	// -RecordData can't be used to select a record made up of several properties, so we find the record object
+	// and pipe it to Remove-DnsServerResourceRecord instead.
+	filter, err := recordDataFilter(r.RecordType, recordData)
 	if err != nil {
-		return fmt.Errorf("ssh execution failure while creating a DNS object: %s", err)
+		return "", err
 	}
-
-	if result.ExitCode != 0 {
-		fmt.Printf("CMD: %s", psCmd.String())
-		return fmt.Errorf("Add-DnsServerResourceRecord exited with a non zero exit code (%d), stderr: %s", result.ExitCode, result.StdErr)
+	if filter != "" {
+		return r.removeRecordDataByFilter(conf, filter), nil
 	}
-	return nil
+	cmd := newCommand("Remove-DnsServerResourceRecord").switchParam("Force").param("ZoneName", r.ZoneName).
+		param("RRType", r.RecordType).param("Name", r.HostName).param("RecordData", recordData)
+	return cmd.computerName(conf).String(), nil
 }
 
-func (r *Record) removeRecordData(conf *config.ProviderConf, recordData string) error {
-	cmd := fmt.Sprintf("Remove-DnsServerResourceRecord -Force -ZoneName %s -RRType %s -Name %s -RecordData \"%s\"", r.ZoneName, r.RecordType, r.HostName, recordData)
+// removeRecordDataByFilter returns a command removing the records in the record set matching the PowerShell filter.
+// The cmdlets are piped, so -ComputerName is added to both of them instead of using CreatePSCommandOpts.Server.
+func (r *Record) removeRecordDataByFilter(conf *config.ProviderConf, filter string) string {
+	return fmt.Sprintf("%s | Where-Object { %s } | %s", r.getRecordSetCommand(conf), filter,
+		newCommand("Remove-DnsServerResourceRecord").switchParam("Force").param("ZoneName", r.ZoneName).computerName(conf))
+}
 
-	conn, err := conf.AcquireSshClient()
-	if err != nil {
-		return fmt.Errorf("while acquiring ssh client: %s", err)
+// getRecordSetCommand returns the command getting the records in the record set.
+func (r *Record) getRecordSetCommand(conf *config.ProviderConf) *psCommandBuilder {
+	cmd := newCommand("Get-DnsServerResourceRecord").param("ZoneName", r.ZoneName).param("Name", r.HostName)
+	return recordTypeParam(cmd, r.RecordType).computerName(conf)
+}
+
+// recordTypeParam adds the record type to a Get-DnsServerResourceRecord command. -RRType only takes the record types
+// the DNS server knows, so CAA records are looked up by the number of the type.
+func recordTypeParam(cmd *psCommandBuilder, recordType string) *psCommandBuilder {
+	if strings.EqualFold(recordType, RecordTypeCAA) {
+		return cmd.intParam("Type", caaTypeNumber)
+	}
+	return cmd.param("RRType", recordType)
+}
+
+// setTTL sets the TTL of every record in the record set. Set-DnsServerResourceRecord
+// needs the old and new record objects, so we clone each existing record and change its TTL.
+// Without a TTL the records keep the TTL they have, since ttl is computed when it isn't configured.
+func (r *Record) setTTL(ctx context.Context, conf *config.ProviderConf) error {
+	if r.TTL <= 0 {
+		return nil
 	}
-	defer conf.ReleaseSshClient(conn)
+
+	// The cmdlets are piped, so we add -ComputerName to both of them instead of using CreatePSCommandOpts.Server
+	set := newCommand("Set-DnsServerResourceRecord").param("ZoneName", r.ZoneName).
+		exprParam("OldInputObject", "$_").exprParam("NewInputObject", "$new").computerName(conf)
+	cmd := fmt.Sprintf("%s | ForEach-Object { $new = $_.Clone(); $new.TimeToLive = [System.TimeSpan]::FromSeconds(%d); %s }",
+		r.getRecordSetCommand(conf), r.TTL, set)
+
 	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
 		JSONOutput: false,
 		ForceArray: false,
 		Username:   conf.Settings.SshUsername,
 		Password:   conf.Settings.SshPassword,
-		Server:     conf.Settings.DnsServer,
 	}
-
 	psCmd := NewPSCommand([]string{cmd}, psOpts)
 
-	result, err := psCmd.Run(conf)
+	_, err := psCmd.Run(ctx, conf)
 	if err != nil {
-		return fmt.Errorf("ssh execution failure while removing record object: %s", err)
-	}
-	if result.ExitCode != 0 {
-		return fmt.Errorf("Remove-DnsServerResourceRecord exited with a non zero exit code (%d), stderr: %s", result.ExitCode, result.StdErr)
+		return fmt.Errorf("Set-DnsServerResourceRecord failed: %w", err)
 	}
 	return nil
 }
 
 // handle if powershell returns single object or list of objects.
 func unmarshallRecord(ctx context.Context, input []byte) (*Record, error) {
-	var err error
+	records, err := unmarshallDNSRecords(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("invalid data while unmarshalling DNSRecord data, json doc was: %s", string(input))
+	}
+
+	return newRecordFromDNSRecords(records)
+}
+
+func unmarshallDNSRecords(ctx context.Context, input []byte) ([]DNSRecord, error) {
 	var records []DNSRecord
 
 	t := bytes.TrimSpace(input)
@@ -308,52 +500,76 @@ func unmarshallRecord(ctx context.Context, input []byte) (*Record, error) {
 		return nil, fmt.Errorf("empty json document")
 	}
 
-	err = json.Unmarshal(input, &records)
+	err := json.Unmarshal(input, &records)
 	if err != nil {
 		tflog.Debug(ctx, fmt.Sprintf("Failed to unmarshall an DNSRecord json document with error %q, document was %s", err, string(input)))
 		return nil, fmt.Errorf("failed while unmarshalling DNSRecord json document: %s", err)
 	}
 
-	if len(records) == 0 {
-		return nil, fmt.Errorf("invalid data while unmarshalling DNSRecord data, json doc was: %s", string(input))
+	// DNS servers without support for CAA return the records with the UNKNOWN record type
+	for i := range records {
+		if records[i].Type == caaTypeNumber {
+			records[i].RecordType = RecordTypeCAA
+		}
 	}
+	return records, nil
+}
 
+// newRecordFromDNSRecords creates a record set from DNS records sharing the same name and type.
+func newRecordFromDNSRecords(records []DNSRecord) (*Record, error) {
 	var rs []string
 	for _, v := range records {
-		recordData := v.RecordData.CimInstanceProperties[0].Value
+		recordData, err := recordDataFromProperties(v.RecordType, v.RecordData.CimInstanceProperties)
+		if err != nil {
+			return nil, err
+		}
 		rs = append(rs, recordData)
 	}
 
 	record := Record{
 		HostName:   records[0].HostName,
 		RecordType: records[0].RecordType,
-		//		TTL:        records[0].TimeToLive.TotalSeconds,
-		Records: rs,
+		TTL:        records[0].TimeToLive.TotalSeconds,
+		Timestamp:  string(records[0].Timestamp),
+		Records:    rs,
 	}
 
 	return &record, nil
 }
 
-func recordExistsInList(r string, list []string) bool {
+func recordExistsInList(recordType, r string, list []string) bool {
 	for _, item := range list {
-		if r == item {
+		if recordDataEqual(recordType, r, item) {
 			return true
 		}
 	}
 	return false
 }
 
-func diffRecordLists(expectedRecords, existingRecords []string) ([]string, []string) {
+// recordDataEqual compares two record values, ignoring the trailing dot the DNS server adds to names, and the
+// quotes and case of the tag of CAA records.
+func recordDataEqual(recordType, a, b string) bool {
+	if recordType == RecordTypeCAA {
+		return NormalizeCAARecordData(a) == NormalizeCAARecordData(b)
+	}
+	if recordType == RecordTypePTR || recordType == RecordTypeCNAME || recordType == RecordTypeMX || recordType == RecordTypeSRV ||
+		recordType == RecordTypeNS {
+		return strings.TrimSuffix(a, ".") == strings.TrimSuffix(b, ".")
+	}
+	return a == b
+}
+
+func diffRecordLists(recordType string, expectedRecords, existingRecords []string) ([]string, []string) {
 	var toAdd, toRemove []string
 
 	for _, record := range expectedRecords {
-		if !recordExistsInList(record, existingRecords) {
+		if !recordExistsInList(recordType, record, existingRecords) {
 			toAdd = append(toAdd, record)
 		}
 	}
 
 	for _, record := range existingRecords {
-		if !recordExistsInList(record, expectedRecords) {
+		if !recordExistsInList(recordType, record, expectedRecords) {
 			toRemove = append(toRemove, record)
 		}
 	}
@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+)
+
+const testExistingARecords = `[{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.11"}]},"TimeToLive":{"TotalSeconds":3600}},
+{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.12"}]},"TimeToLive":{"TotalSeconds":3600}}]`
+
+func newTestProviderConf(executor config.Executor) *config.ProviderConf {
+	conf := config.NewProviderConf(&config.Settings{DnsServer: "dc1.example.com"})
+	conf.Executor = executor
+	return conf
+}
+
+// assertScripts checks that each script contains the expected substrings, in order
+func assertScripts(t *testing.T, scripts []string, want [][]string) {
+	t.Helper()
+	if len(scripts) != len(want) {
+		t.Fatalf("ran %d scripts, want %d: %q", len(scripts), len(want), scripts)
+	}
+	for i, substrings := range want {
+		for _, substring := range substrings {
+			if !strings.Contains(scripts[i], substring) {
+				t.Errorf("script %d %q does not contain %q", i, scripts[i], substring)
+			}
+		}
+	}
+}
+
+func TestRecord_Create(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":null}]`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.12"}, CreatePtr: true, TTL: 300}
+	id, err := r.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if id != "r1_example.com_A_true" {
+		t.Errorf("Create() id = %q", id)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{
+			"$ErrorActionPreference = 'Stop'",
+			"-ZoneName 'example.com' -Name 'r1' -A -IPv4Address '203.0.113.11' -CreatePtr", "FromSeconds(300)", "-ComputerName 'dc1.example.com' | Out-Null; @{ Index = 0;",
+			"-IPv4Address '203.0.113.12'", "@{ Index = 1;",
+			"ConvertTo-Json -Compress",
+		},
+	})
+}
+
+func TestRecord_CreateCAA(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null}]`)
+	executor.On("Remove-DnsServerResourceRecord ", `[{"Index":0,"Error":null}]`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "@", RecordType: RecordTypeCAA, Records: []string{`0 issue "letsencrypt.org"`}}
+	_, err := r.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	err = r.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"-ZoneName 'example.com' -Name '@' -Type 257 -RecordData '000569737375656c657473656e63727970742e6f7267' -ComputerName 'dc1.example.com' | Out-Null"},
+		{
+			"Get-DnsServerResourceRecord -ZoneName 'example.com' -Name '@' -Type 257 -ComputerName 'dc1.example.com' | " +
+				"Where-Object { $_.RecordData.Data -eq '000569737375656c657473656e63727970742e6f7267' } | Remove-DnsServerResourceRecord",
+		},
+	})
+}
+
+func TestRecord_CreateFailure(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", "").Fail(1, "Failed to create resource record")
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11"}}
+	_, err := r.Create(context.Background(), conf)
+	if err == nil || !strings.Contains(err.Error(), "Failed to create resource record") {
+		t.Fatalf("Create() error = %v, want stderr in error", err)
+	}
+}
+
+func TestRecord_CreatePartialFailure(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":{"Message":"Failed to create resource record r1 in example.com zone.","FullyQualifiedErrorId":"WIN32 9711,Add-DnsServerResourceRecord","Category":"ResourceExists"}}]`)
+	executor.On("Remove-DnsServerResourceRecord ", `{"Index":0,"Error":null}`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.12"}}
+	_, err := r.Create(context.Background(), conf)
+	var updateErr *RecordUpdateError
+	if !errors.As(err, &updateErr) {
+		t.Fatalf("Create() error = %v, want a RecordUpdateError", err)
+	}
+	if len(updateErr.Failed) != 1 || updateErr.Failed[0].Value != "203.0.113.12" || updateErr.Failed[0].Operation != RecordOperationAdd {
+		t.Errorf("Create() failed operations = %+v", updateErr.Failed)
+	}
+	if !strings.Contains(err.Error(), "WIN32 9711") {
+		t.Errorf("Create() error = %v, want the error id", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"-IPv4Address '203.0.113.11'", "-IPv4Address '203.0.113.12'"},
+		{"Remove-DnsServerResourceRecord", "-RecordData '203.0.113.11'"},
+	})
+}
+
+func TestRecord_Update(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("try \\{ Get-DnsServerResourceRecord -ZoneName 'example.com' -Name 'r1' -RRType 'A' -ComputerName 'dc1.example.com' \\| ConvertTo-Json", testExistingARecords)
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":null}]`)
+	executor.On("try \\{ Get-DnsServerResourceRecord .* Set-DnsServerResourceRecord ", "")
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.13"}, TTL: 600}
+	changes := map[string]interface{}{
+		"records": schema.NewSet(schema.HashString, []interface{}{"203.0.113.11", "203.0.113.13"}),
+		"ttl":     600,
+	}
+	err := r.Update(context.Background(), conf, changes)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"Get-DnsServerResourceRecord"},
+		{"Add-DNSServerResourceRecord", "-IPv4Address '203.0.113.13'", "Remove-DnsServerResourceRecord", "-RecordData '203.0.113.12'"},
+		{"Set-DnsServerResourceRecord", "FromSeconds(600)"},
+	})
+}
+
+func TestRecord_UpdateRollback(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("try \\{ Get-DnsServerResourceRecord -ZoneName 'example.com' -Name 'r1' ", testExistingARecords)
+	executor.On(`RecordData '203.0.113.13'`, `{"Index":0,"Error":null}`)
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":{"Message":"Failed to delete the resource record.","FullyQualifiedErrorId":"WIN32 9714,Remove-DnsServerResourceRecord"}}]`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.13"}}
+	changes := map[string]interface{}{
+		"records": schema.NewSet(schema.HashString, []interface{}{"203.0.113.11", "203.0.113.13"}),
+	}
+	err := r.Update(context.Background(), conf, changes)
+	var updateErr *RecordUpdateError
+	if !errors.As(err, &updateErr) {
+		t.Fatalf("Update() error = %v, want a RecordUpdateError", err)
+	}
+	if !updateErr.RolledBack() || len(updateErr.Applied) != 1 || updateErr.Applied[0].Value != "203.0.113.13" {
+		t.Errorf("Update() error = %+v", updateErr)
+	}
+	for _, want := range []string{`failed to remove record data "203.0.113.12"`, `added "203.0.113.13"`, "rolled back"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Update() error = %q, want %q", err, want)
+		}
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"Get-DnsServerResourceRecord"},
+		{"-IPv4Address '203.0.113.13'", "-RecordData '203.0.113.12'"},
+		{"Remove-DnsServerResourceRecord", "-RecordData '203.0.113.13'"},
+	})
+}
+
+func TestRecord_UpdateRollbackFailure(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("try \\{ Get-DnsServerResourceRecord -ZoneName 'example.com' -Name 'r1' ", testExistingARecords).Once()
+	executor.On(`RecordData '203.0.113.13'`, "").Fail(1, "connection lost")
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":{"Message":"Failed to delete the resource record."}}]`)
+	executor.On("try \\{ Get-DnsServerResourceRecord -ZoneName 'example.com' -Name 'r1' ", `[{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.11"}]},"TimeToLive":{"TotalSeconds":3600}},
+{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.12"}]},"TimeToLive":{"TotalSeconds":3600}},
+{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.13"}]},"TimeToLive":{"TotalSeconds":3600}}]`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.13"}}
+	changes := map[string]interface{}{
+		"records": schema.NewSet(schema.HashString, []interface{}{"203.0.113.11", "203.0.113.13"}),
+	}
+	err := r.Update(context.Background(), conf, changes)
+	var updateErr *RecordUpdateError
+	if !errors.As(err, &updateErr) {
+		t.Fatalf("Update() error = %v, want a RecordUpdateError", err)
+	}
+	if updateErr.RolledBack() || len(updateErr.Actual) != 3 {
+		t.Errorf("Update() error = %+v, want the actual record set after a failed rollback", updateErr)
+	}
+	if !strings.Contains(err.Error(), "now holds: 203.0.113.11, 203.0.113.12, 203.0.113.13") {
+		t.Errorf("Update() error = %q", err)
+	}
+}
+
+func TestRecord_Delete(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Remove-DnsServerResourceRecord ", `{"Index":0,"Error":null}`)
+	conf := newTestProviderConf(executor)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11"}}
+	err := r.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	mx := &Record{ZoneName: "example.com", HostName: "@", RecordType: RecordTypeMX, Records: []string{"10 mail.example.com"}}
+	err = mx.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	txt := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeTXT, Records: []string{"v=spf1 -all"}}
+	err = txt.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"Remove-DnsServerResourceRecord -Force -ZoneName 'example.com' -RRType 'A' -Name 'r1' -RecordData '203.0.113.11' -ComputerName 'dc1.example.com'"},
+		{"$_.RecordData.Preference -eq 10", "MailExchange.TrimEnd('.') -eq 'mail.example.com'"},
+		{"$_.RecordData.DescriptiveText.Replace(\"`r\", '').Replace(\"`n\", '') -ceq 'v=spf1 -all'"},
+	})
+}
+
+func TestRecord_CreateLongTXT(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `{"Index":0,"Error":null}`)
+	conf := newTestProviderConf(executor)
+
+	value := "v=DKIM1; k=rsa; p=" + strings.Repeat("A", 300)
+	r := &Record{ZoneName: "example.com", HostName: "selector1._domainkey", RecordType: RecordTypeTXT, Records: []string{value}}
+	_, err := r.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"-DescriptiveText '" + value[:255] + "\n" + value[255:] + "'"},
+	})
+}
+
+func TestRecord_CreateLargeRecordSet(t *testing.T) {
+	server := fakedns.NewServer("dc1")
+	if err := server.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.NewProviderConf(&config.Settings{})
+	conf.Executor = &serverExecutor{server: server}
+	ctx := context.Background()
+
+	// The values don't fit in one command line, so they are added by several scripts
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, TTL: 300}
+	for i := 1; i <= 100; i++ {
+		r.Records = append(r.Records, fmt.Sprintf("203.0.113.%d", i))
+	}
+	id, err := r.Create(ctx, conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatalf("GetDNSRecordFromId() error = %v", err)
+	}
+	if len(got.Records) != len(r.Records) {
+		t.Errorf("GetDNSRecordFromId() returned %d records, want %d", len(got.Records), len(r.Records))
+	}
+}
+
+func TestGetDNSRecordFromId(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("-Name 'r1' ", testExistingARecords)
+	executor.On("-Name 'missing' ", `{"WindnsError":{"Message":"Failed to get missing record in example.com zone.","FullyQualifiedErrorId":"WIN32 9714,Get-DnsServerResourceRecord","Category":"ObjectNotFound"}}`)
+	conf := newTestProviderConf(executor)
+
+	record, err := GetDNSRecordFromId(context.Background(), conf, "r1_example.com_A_true")
+	if err != nil {
+		t.Fatalf("GetDNSRecordFromId() error = %v", err)
+	}
+	if record.ZoneName != "example.com" || !record.CreatePtr || len(record.Records) != 2 || record.TTL != 3600 {
+		t.Errorf("GetDNSRecordFromId() = %+v", record)
+	}
+
+	_, err = GetDNSRecordFromId(context.Background(), conf, "missing_example.com_A_false")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDNSRecordFromId() error = %v, want %v", err, ErrNotFound)
+	}
+}
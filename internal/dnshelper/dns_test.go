@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func Test_unmarshallRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantRecords []string
+		wantTTL     int64
+		wantErr     bool
+	}{
+		{
+			"test-a-records",
+			`[{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"value":"203.0.113.11"}]},"TimeToLive":{"TotalSeconds":3600}},
+			  {"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"value":"203.0.113.12"}]},"TimeToLive":{"TotalSeconds":3600}}]`,
+			[]string{"203.0.113.11", "203.0.113.12"}, 3600, false,
+		},
+		{
+			"test-ttl",
+			`[{"HostName":"r1","RecordType":"CNAME","RecordData":{"CimInstanceProperties":[{"value":"cname.example.com."}]},"TimeToLive":{"TotalSeconds":300}}]`,
+			[]string{"cname.example.com."}, 300, false,
+		},
+		{
+			"test-mx",
+			`[{"HostName":"@","RecordType":"MX","RecordData":{"CimInstanceProperties":[{"Name":"MailExchange","Value":"mail.example.com.","CimType":14},{"Name":"Preference","Value":10,"CimType":5}]},"TimeToLive":{"TotalSeconds":3600}}]`,
+			[]string{"10 mail.example.com."}, 3600, false,
+		},
+		{
+			"test-srv",
+			`[{"HostName":"_ldap._tcp","RecordType":"SRV","RecordData":{"CimInstanceProperties":[{"Name":"DomainName","Value":"dc1.example.com."},{"Name":"Port","Value":389},{"Name":"Priority","Value":0},{"Name":"Weight","Value":100}]},"TimeToLive":{"TotalSeconds":600}}]`,
+			[]string{"0 100 389 dc1.example.com."}, 600, false,
+		},
+		{
+			"test-txt-strings",
+			`[{"HostName":"r1","RecordType":"TXT","RecordData":{"CimInstanceProperties":[{"Name":"DescriptiveText","Value":"v=DKIM1; k=rsa; \\\"p=\\\"\r\nMIIBIjANBg"}]},"TimeToLive":{"TotalSeconds":3600}}]`,
+			[]string{`v=DKIM1; k=rsa; \"p=\"MIIBIjANBg`}, 3600, false,
+		},
+		{
+			"test-caa-unknown",
+			`[{"HostName":"@","RecordType":"UNKNOWN","Type":257,"RecordData":{"CimInstanceProperties":[{"Name":"Data","Value":"000569737375656C657473656E63727970742E6F7267"}]},"TimeToLive":{"TotalSeconds":3600}}]`,
+			[]string{`0 issue "letsencrypt.org"`}, 3600, false,
+		},
+		{
+			"test-caa-invalid",
+			`[{"HostName":"@","RecordType":"UNKNOWN","Type":257,"RecordData":{"CimInstanceProperties":[{"Name":"Data","Value":"0005"}]},"TimeToLive":{"TotalSeconds":3600}}]`,
+			nil, 0, true,
+		},
+		{
+			"test-empty", ``, nil, 0, true,
+		},
+		{
+			"test-empty-list", `[]`, nil, 0, true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshallRecord(context.Background(), []byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshallRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !slices.Equal(got.Records, tt.wantRecords) {
+				t.Errorf("unmarshallRecord() records = %q, want %q", got.Records, tt.wantRecords)
+			}
+			if got.TTL != tt.wantTTL {
+				t.Errorf("unmarshallRecord() ttl = %d, want %d", got.TTL, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func Test_diffRecordLists(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordType string
+		expected   []string
+		existing   []string
+		wantAdd    []string
+		wantRemove []string
+	}{
+		{
+			"test-a", RecordTypeA, []string{"203.0.113.11", "203.0.113.13"}, []string{"203.0.113.11", "203.0.113.12"},
+			[]string{"203.0.113.13"}, []string{"203.0.113.12"},
+		},
+		{
+			"test-mx-trailing-dot", RecordTypeMX, []string{"10 mail1.example.com", "30 mail2.example.com"}, []string{"10 mail1.example.com.", "20 mail2.example.com."},
+			[]string{"30 mail2.example.com"}, []string{"20 mail2.example.com."},
+		},
+		{
+			"test-ns-trailing-dot", RecordTypeNS, []string{"ns1.example.com", "ns3.example.com"}, []string{"ns1.example.com.", "ns2.example.com."},
+			[]string{"ns3.example.com"}, []string{"ns2.example.com."},
+		},
+		{
+			"test-txt-trailing-dot", RecordTypeTXT, []string{"data."}, []string{"data"},
+			[]string{"data."}, []string{"data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toAdd, toRemove := diffRecordLists(tt.recordType, tt.expected, tt.existing)
+			if !slices.Equal(toAdd, tt.wantAdd) {
+				t.Errorf("diffRecordLists() toAdd = %q, want %q", toAdd, tt.wantAdd)
+			}
+			if !slices.Equal(toRemove, tt.wantRemove) {
+				t.Errorf("diffRecordLists() toRemove = %q, want %q", toRemove, tt.wantRemove)
+			}
+		})
+	}
+}
+
+func Test_parseRecordId(t *testing.T) {
+	tests := []struct {
+		name          string
+		id            string
+		wantHostName  string
+		wantZoneName  string
+		wantType      string
+		wantCreatePtr bool
+		wantErr       bool
+	}{
+		{"test-with-ptr", "r1_example.com_A_true", "r1", "example.com", "A", true, false},
+		{"test-without-ptr", "r1_example.com_A", "r1", "example.com", "A", false, false},
+		{"test-srv-name", "_ldap._tcp_example.com_SRV_false", "_ldap._tcp", "example.com", "SRV", false, false},
+		{"test-invalid", "r1_example.com", "", "", "", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostName, zoneName, recordType, createPtr, err := parseRecordId(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRecordId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if hostName != tt.wantHostName || zoneName != tt.wantZoneName || recordType != tt.wantType || createPtr != tt.wantCreatePtr {
+				t.Errorf("parseRecordId() = %q, %q, %q, %v", hostName, zoneName, recordType, createPtr)
+			}
+		})
+	}
+}
+
+func TestTimestamp_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Timestamp
+		wantErr bool
+	}{
+		{"test-null", `null`, "", false},
+		{"test-windows-powershell", `"\/Date(1700000000000)\/"`, "2023-11-14T22:13:20Z", false},
+		{"test-windows-powershell-offset", `"\/Date(1700000000000+0100)\/"`, "2023-11-14T22:13:20Z", false},
+		{"test-windows-powershell-wrapped", `{"value":"\/Date(1700000000000)\/","DisplayHint":2}`, "2023-11-14T22:13:20Z", false},
+		{"test-powershell-7", `"2023-11-14T23:13:20+01:00"`, "2023-11-14T22:13:20Z", false},
+		{"test-invalid", `"yesterday"`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Timestamp
+			err := got.UnmarshalJSON([]byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Timestamp.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Timestamp.UnmarshalJSON() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
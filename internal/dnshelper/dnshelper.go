@@ -13,11 +13,38 @@ import (
 var recordInputPattern = regexp.MustCompile(`^[a-zA-Z0-9:.\-_]+$`)
 
 func SanitizeInputString(recordType string, input string) (string, error) {
-	if recordType == "TXT" {
-		if len(input) > 255 {
-			return "", fmt.Errorf("TXT record can only be 255 characters long")
+	if recordType == RecordTypeTXT {
+		// The DNS server separates the character-strings of a TXT record with line breaks, so a value with line
+		// breaks would be read back as a different value
+		if strings.ContainsAny(input, "\r\n") {
+			return "", fmt.Errorf("TXT record can't contain line breaks")
 		}
-		return escapePowerShellInput(input), nil
+		// Record data is passed to PowerShell as string literals, so TXT records can hold any other character
+		return input, nil
+	}
+
+	if recordType == RecordTypeMX {
+		mx, err := ParseMXRecordData(input)
+		if err != nil {
+			return "", err
+		}
+		return mx.String(), nil
+	}
+
+	if recordType == RecordTypeCAA {
+		caa, err := ParseCAARecordData(input)
+		if err != nil {
+			return "", err
+		}
+		return caa.String(), nil
+	}
+
+	if recordType == RecordTypeSRV {
+		srv, err := ParseSRVRecordData(input)
+		if err != nil {
+			return "", err
+		}
+		return srv.String(), nil
 	}
 
 	if recordInputPattern.MatchString(input) {
@@ -26,17 +53,11 @@ func SanitizeInputString(recordType string, input string) (string, error) {
 	return "", fmt.Errorf("invalid characters detected in input: %s", input)
 }
 
+// SanitiseTFInput sanitises a plain resource argument like zone_name or name, allowing "@" for the zone apex.
 func SanitiseTFInput(d *schema.ResourceData, key string) (string, error) {
-	return SanitizeInputString(d.Get("type").(string), d.Get(key).(string))
-}
-
-func escapePowerShellInput(input string) string {
-	replacer := strings.NewReplacer(
-		"`", "``",
-		";", "`;",
-		"&", "`&",
-		"(", "`(",
-		")", "`)",
-	)
-	return replacer.Replace(input)
+	input := d.Get(key).(string)
+	if input == "@" {
+		return input, nil
+	}
+	return SanitizeInputString("", input)
 }
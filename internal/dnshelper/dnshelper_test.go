@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestSanitiseTFInput(t *testing.T) {
+	recordSchema := map[string]*schema.Schema{
+		"zone_name": {Type: schema.TypeString, Required: true},
+		"name":      {Type: schema.TypeString, Required: true},
+		"type":      {Type: schema.TypeString, Required: true},
+	}
+
+	tests := []struct {
+		name       string
+		recordType string
+		hostName   string
+		wantErr    bool
+	}{
+		{"test-a", RecordTypeA, "www", false},
+		{"test-mx", RecordTypeMX, "@", false},
+		{"test-srv", RecordTypeSRV, "_sip._tcp", false},
+		{"test-caa", RecordTypeCAA, "www", false},
+		{"test-txt", RecordTypeTXT, "_dmarc", false},
+		{"test-txt-apex", RecordTypeTXT, "@", false},
+		{"test-caa-apex", RecordTypeCAA, "@", false},
+		// The name of a TXT record isn't record data, so it can't hold the characters TXT record data can
+		{"test-txt-invalid-name", RecordTypeTXT, "www; Remove-DnsServerZone", true},
+		{"test-a-invalid-name", RecordTypeA, "www example", true},
+		{"test-a-invalid-apex", RecordTypeA, "@www", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, recordSchema, map[string]interface{}{
+				"zone_name": "example.com",
+				"name":      tt.hostName,
+				"type":      tt.recordType,
+			})
+
+			zoneName, err := SanitiseTFInput(d, "zone_name")
+			if err != nil || zoneName != "example.com" {
+				t.Errorf("SanitiseTFInput(zone_name) = %q, %v", zoneName, err)
+			}
+			name, err := SanitiseTFInput(d, "name")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SanitiseTFInput(name) error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && name != tt.hostName {
+				t.Errorf("SanitiseTFInput(name) = %q, want %q", name, tt.hostName)
+			}
+		})
+	}
+}
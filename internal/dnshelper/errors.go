@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Errors a PowerShellError can be matched against with errors.Is. A missing zone is also a NotFound error, since
+// the records in it are missing too.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrZoneNotFound  = errors.New("zone not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrAccessDenied  = errors.New("access denied")
+)
+
+// Win32 error codes of the DnsServer cmdlets, found in the FullyQualifiedErrorId like `WIN32 9601,Get-DnsServerZone`.
+const (
+	win32AccessDenied         = 5
+	win32ZoneDoesNotExist     = 9601
+	win32ZoneAlreadyExists    = 9609
+	win32RecordDoesNotExist   = 9701
+	win32RecordAlreadyExists  = 9711
+	win32NameDoesNotExist     = 9714
+	win32DsObjectDoesNotExist = 8240
+)
+
+// psErrorObject is a hashtable describing the error record in $_, for a catch block to write as JSON.
+const psErrorObject = "@{ Message = $_.Exception.Message; FullyQualifiedErrorId = $_.FullyQualifiedErrorId; " +
+	"Category = $_.CategoryInfo.Category.ToString(); Reason = $_.CategoryInfo.Reason; TargetName = $_.CategoryInfo.TargetName; " +
+	"Win32ErrorCode = $_.Exception.ErrorData.error_Code }"
+
+// psErrorKey is the key of the JSON object a script writes to stdout when it caught an error
+const psErrorKey = "WindnsError"
+
+// PowerShellError is an error record written by a script. Use errors.Is with ErrNotFound, ErrZoneNotFound,
+// ErrAlreadyExists or ErrAccessDenied to check for the common failures.
+type PowerShellError struct {
+	Message               string `json:"Message"`
+	FullyQualifiedErrorId string `json:"FullyQualifiedErrorId"`
+	Category              string `json:"Category"`
+	Reason                string `json:"Reason"`
+	TargetName            string `json:"TargetName"`
+	Win32ErrorCode        int    `json:"Win32ErrorCode"`
+}
+
+func (e *PowerShellError) Error() string {
+	if e.FullyQualifiedErrorId == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.FullyQualifiedErrorId)
+}
+
+func (e *PowerShellError) Is(target error) bool {
+	code := e.win32ErrorCode()
+	switch target {
+	case ErrZoneNotFound:
+		return code == win32ZoneDoesNotExist
+	case ErrNotFound:
+		switch code {
+		case win32ZoneDoesNotExist, win32RecordDoesNotExist, win32NameDoesNotExist, win32DsObjectDoesNotExist:
+			return true
+		}
+		return e.Category == "ObjectNotFound"
+	case ErrAlreadyExists:
+		return code == win32ZoneAlreadyExists || code == win32RecordAlreadyExists || e.Category == "ResourceExists"
+	case ErrAccessDenied:
+		return code == win32AccessDenied || e.Category == "PermissionDenied"
+	}
+	return false
+}
+
+var win32ErrorIdPattern = regexp.MustCompile(`^WIN32 (\d+)`)
+
+// win32ErrorCode returns the Win32 error code of the error, from the error data of the CIM exception, or else from
+// the error id.
+func (e *PowerShellError) win32ErrorCode() int {
+	if e.Win32ErrorCode != 0 {
+		return e.Win32ErrorCode
+	}
+	if m := win32ErrorIdPattern.FindStringSubmatch(e.FullyQualifiedErrorId); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return 0
+}
+
+// tryCatchScript wraps a script in try/catch, so an error record stops the script and is written to stdout as JSON.
+func tryCatchScript(script string) string {
+	return fmt.Sprintf("$ErrorActionPreference = 'Stop'\ntry { %s } catch { @{ %s = %s } | ConvertTo-Json -Compress }",
+		script, psErrorKey, psErrorObject)
+}
+
+// parseCaughtError returns the error written by tryCatchScript, if any. It is the last line of stdout.
+func parseCaughtError(stdout string) *PowerShellError {
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if !strings.HasPrefix(last, `{"`+psErrorKey+`":`) {
+		return nil
+	}
+
+	var output map[string]*PowerShellError
+	if err := json.Unmarshal([]byte(last), &output); err != nil || output[psErrorKey] == nil {
+		return nil
+	}
+	return output[psErrorKey]
+}
+
+// parseErrorText parses an error record as Windows PowerShell writes it to stderr, for errors that weren't caught.
+// The message is the text before the CategoryInfo line, without the position of the error in the script.
+func parseErrorText(stderr string) *PowerShellError {
+	e := &PowerShellError{}
+	var message []string
+	scanner := bufio.NewScanner(strings.NewReader(stderr))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "+ CategoryInfo"):
+			// + CategoryInfo          : ObjectNotFound: (example.com:root/Microsoft/...erverZone) [Get-DnsServerZone], CimException
+			value := strings.TrimSpace(line[strings.Index(line, ":")+1:])
+			if i := strings.Index(value, ":"); i > 0 {
+				e.Category = value[:i]
+			}
+			if i := strings.LastIndex(value, ", "); i > 0 {
+				e.Reason = value[i+2:]
+			}
+		case strings.HasPrefix(line, "+ FullyQualifiedErrorId"):
+			e.FullyQualifiedErrorId = strings.TrimSpace(line[strings.Index(line, ":")+1:])
+		case strings.HasPrefix(line, "+ "), strings.HasPrefix(line, "At line:"), strings.HasPrefix(line, "~"):
+		case line != "" && e.Category == "":
+			message = append(message, line)
+		}
+	}
+
+	e.Message = strings.Join(message, " ")
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(stderr)
+	}
+	return e
+}
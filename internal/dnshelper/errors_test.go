@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCaughtError(t *testing.T) {
+	stdout := "[]\n" + `{"WindnsError":{"Message":"The zone example.com was not found on server dc1.","FullyQualifiedErrorId":"WIN32 9601,Get-DnsServerZone","Category":"ObjectNotFound","Reason":"CimException","TargetName":"example.com","Win32ErrorCode":9601}}` + "\r\n"
+	err := parseCaughtError(stdout)
+	if err == nil {
+		t.Fatal("parseCaughtError() = nil")
+	}
+	if err.Win32ErrorCode != 9601 || err.Category != "ObjectNotFound" || err.TargetName != "example.com" {
+		t.Errorf("parseCaughtError() = %+v", err)
+	}
+
+	if err := parseCaughtError(`{"ZoneName":"example.com"}`); err != nil {
+		t.Errorf("parseCaughtError() = %+v for output without an error", err)
+	}
+	if err := parseCaughtError(""); err != nil {
+		t.Errorf("parseCaughtError() = %+v for empty output", err)
+	}
+}
+
+func TestParseErrorText(t *testing.T) {
+	stderr := `Get-DnsServerZone : The zone example.com was not found on server dc1.
+At line:1 char:1
++ Get-DnsServerZone -Name example.com
++ ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+    + CategoryInfo          : ObjectNotFound: (example.com:root/Microsoft/...erverZone) [Get-DnsServerZone], CimException
+    + FullyQualifiedErrorId : WIN32 9601,Get-DnsServerZone
+`
+	err := parseErrorText(stderr)
+	want := PowerShellError{
+		Message:               "Get-DnsServerZone : The zone example.com was not found on server dc1.",
+		FullyQualifiedErrorId: "WIN32 9601,Get-DnsServerZone",
+		Category:              "ObjectNotFound",
+		Reason:                "CimException",
+	}
+	if *err != want {
+		t.Errorf("parseErrorText() = %+v, want %+v", *err, want)
+	}
+
+	if err := parseErrorText("connection lost\n"); err.Message != "connection lost" {
+		t.Errorf("parseErrorText() = %+v, want the text as the message", err)
+	}
+}
+
+func TestPowerShellError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *PowerShellError
+		target error
+		want   bool
+	}{
+		{"zone-not-found", &PowerShellError{Win32ErrorCode: 9601}, ErrZoneNotFound, true},
+		{"zone-not-found-is-not-found", &PowerShellError{FullyQualifiedErrorId: "WIN32 9601,Get-DnsServerZone"}, ErrNotFound, true},
+		{"record-not-found", &PowerShellError{FullyQualifiedErrorId: "WIN32 9714,Get-DnsServerResourceRecord"}, ErrNotFound, true},
+		{"record-not-found-is-not-zone", &PowerShellError{Win32ErrorCode: 9714}, ErrZoneNotFound, false},
+		{"object-not-found", &PowerShellError{Category: "ObjectNotFound"}, ErrNotFound, true},
+		{"record-exists", &PowerShellError{Win32ErrorCode: 9711}, ErrAlreadyExists, true},
+		{"resource-exists", &PowerShellError{Category: "ResourceExists"}, ErrAlreadyExists, true},
+		{"access-denied", &PowerShellError{FullyQualifiedErrorId: "WIN32 5,Add-DnsServerPrimaryZone"}, ErrAccessDenied, true},
+		{"permission-denied", &PowerShellError{Category: "PermissionDenied"}, ErrAccessDenied, true},
+		{"other", &PowerShellError{Message: "The RPC server is unavailable.", Win32ErrorCode: 1722}, ErrNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is(%+v, %v) = %v, want %v", tt.err, tt.target, got, tt.want)
+			}
+		})
+	}
+}
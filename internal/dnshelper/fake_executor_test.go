@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// FakeExecutor is an in-memory config.Executor for tests. It answers scripts with scripted responses and records
+// every script it runs, so the commands sent to the DNS server can be tested without a Windows server.
+type FakeExecutor struct {
+	mx        sync.Mutex
+	responses []*FakeResponse
+	scripts   []string
+}
+
+// FakeResponse is the response for scripts matching Pattern. A response with Times > 0 is only used that many times.
+type FakeResponse struct {
+	Pattern  *regexp.Regexp
+	Stdout   string
+	StdErr   string
+	ExitCode int
+	Err      error
+	Times    int
+}
+
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{}
+}
+
+// On adds a response for scripts matching the regular expression. Responses are matched in the order they were added.
+func (f *FakeExecutor) On(pattern string, stdout string) *FakeResponse {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	response := &FakeResponse{
+		Pattern: regexp.MustCompile(pattern),
+		Stdout:  stdout,
+	}
+	f.responses = append(f.responses, response)
+	return response
+}
+
+// Fail makes the response exit with a non zero exit code and the given stderr
+func (r *FakeResponse) Fail(exitCode int, stderr string) *FakeResponse {
+	r.ExitCode = exitCode
+	r.StdErr = stderr
+	return r
+}
+
+// Once makes the response only match the next script
+func (r *FakeResponse) Once() *FakeResponse {
+	r.Times = 1
+	return r
+}
+
+func (f *FakeExecutor) Execute(ctx context.Context, script string) (*config.ExecuteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.scripts = append(f.scripts, script)
+
+	for i, response := range f.responses {
+		if !response.Pattern.MatchString(script) {
+			continue
+		}
+		if response.Times > 0 {
+			response.Times--
+			if response.Times == 0 {
+				f.responses = append(f.responses[:i:i], f.responses[i+1:]...)
+			}
+		}
+		if response.Err != nil {
+			return nil, response.Err
+		}
+		return &config.ExecuteResult{
+			Stdout:   response.Stdout,
+			StdErr:   response.StdErr,
+			ExitCode: response.ExitCode,
+		}, nil
+	}
+	return nil, fmt.Errorf("FakeExecutor: no response for script: %s", script)
+}
+
+// Scripts returns the scripts run so far
+func (f *FakeExecutor) Scripts() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return append([]string{}, f.scripts...)
+}
@@ -3,23 +3,24 @@
 package dnshelper
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/nrkno/terraform-provider-windns/internal/config"
-	"golang.org/x/crypto/ssh"
-
-	"github.com/masterzen/winrm"
 )
 
 type CreatePSCommandOpts struct {
 	ForceArray bool
+	// Idempotent commands, like reads, are retried after transport errors even if they may have been run
+	Idempotent bool
 	JSONOutput bool
 	JSONDepth  int
-	Password   string
-	Server     string
-	Username   string
+	// NoRetry runs the command once, for callers retrying it themselves
+	NoRetry  bool
+	Password string
+	Server   string
+	Username string
 }
 
 type PSCommand struct {
@@ -31,7 +32,7 @@ func NewPSCommand(cmds []string, opts CreatePSCommandOpts) *PSCommand {
 	cmd := strings.Join(cmds, " ")
 
 	if opts.Server != "" {
-		cmd = fmt.Sprintf("%s -ComputerName %s", cmd, opts.Server)
+		cmd = fmt.Sprintf("%s -ComputerName %s", cmd, quoteArg(opts.Server))
 	}
 
 	if opts.JSONOutput {
@@ -43,59 +44,57 @@ func NewPSCommand(cmds []string, opts CreatePSCommandOpts) *PSCommand {
 
 	res := PSCommand{
 		CreatePSCommandOpts: opts,
-		cmd:                 cmd,
+		cmd:                 tryCatchScript(cmd),
 	}
 
 	return &res
 }
 
-// Run will run a powershell command and return the stdout and stderr
+// Run will run a powershell command with the executor of the provider and return the stdout and stderr
 // The output is converted to JSON if the json parameter is set to true.
-func (p *PSCommand) Run(conf *config.ProviderConf) (*PSCommandResult, error) {
-	var (
-		err      error
-		exitCode int
-		stderr   bytes.Buffer
-		stdout   bytes.Buffer
-	)
-	conn, err := conf.AcquireSshClient()
-	if err != nil {
-		return nil, fmt.Errorf("while acquiring ssh client: %s", err)
-	}
-	defer conf.ReleaseSshClient(conn)
-
-	encodedCmd := winrm.Powershell(p.cmd)
-
-	cmd, err := conn.Command(encodedCmd)
+// An error record written by the command is returned as a *PowerShellError.
+// Transient failures are retried with backoff, as configured by max_retries and retry_max_wait, unless NoRetry is set.
+func (p *PSCommand) Run(ctx context.Context, conf *config.ProviderConf) (*PSCommandResult, error) {
+	var res *config.ExecuteResult
+	err := withRetry(ctx, conf.Settings, func() (bool, error) {
+		var err error
+		res, err = conf.Executor.Execute(ctx, p.cmd)
+		if err == nil {
+			err = commandError(res)
+		}
+		if err != nil {
+			return !p.NoRetry && isTransientError(err, p.Idempotent), err
+		}
+		return false, nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	cmd.Session.Stderr = &stderr
-	cmd.Session.Stdout = &stdout
-
-	err = cmd.Run()
-	if err != nil {
-		if v, ok := err.(*ssh.ExitError); ok {
-			exitCode = v.ExitStatus()
-		} else {
-			return nil, fmt.Errorf("run error: %s", err)
-		}
-	}
-
-	out := stdout.String()
-	if p.ForceArray && stdout.String() != "" && stdout.String()[0] != '[' {
-		out = fmt.Sprintf("[%s]", stdout.String())
+	out := res.Stdout
+	if p.ForceArray && res.Stdout != "" && res.Stdout[0] != '[' {
+		out = fmt.Sprintf("[%s]", res.Stdout)
 	}
 
 	result := &PSCommandResult{
 		Stdout:   out,
-		StdErr:   stderr.String(),
-		ExitCode: exitCode,
+		StdErr:   res.StdErr,
+		ExitCode: res.ExitCode,
 	}
 	return result, nil
 }
 
+// commandError returns the error caught by the script, or the error written to stderr if the script failed anyway.
+func commandError(res *config.ExecuteResult) error {
+	if caught := parseCaughtError(res.Stdout); caught != nil {
+		return caught
+	}
+	if res.ExitCode != 0 {
+		return parseErrorText(res.StdErr)
+	}
+	return nil
+}
+
 func (p *PSCommand) String() string {
 	return p.cmd
 }
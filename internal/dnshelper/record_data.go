@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MXRecordData is the parsed form of an MX record value.
+// In the records list it is represented as "<preference> <exchange>", e.g. "10 mail.example.com".
+type MXRecordData struct {
+	Preference uint16
+	Exchange   string
+}
+
+// ParseMXRecordData parses an MX record value on the form "<preference> <exchange>"
+func ParseMXRecordData(input string) (*MXRecordData, error) {
+	fields := strings.Fields(input)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("MX record must be on the form \"<preference> <exchange>\", got: %s", input)
+	}
+
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MX preference %q: %s", fields[0], err)
+	}
+
+	if !recordInputPattern.MatchString(fields[1]) {
+		return nil, fmt.Errorf("invalid characters detected in input: %s", fields[1])
+	}
+
+	return &MXRecordData{
+		Preference: uint16(preference),
+		Exchange:   fields[1],
+	}, nil
+}
+
+func (m *MXRecordData) String() string {
+	return fmt.Sprintf("%d %s", m.Preference, m.Exchange)
+}
+
+// RecordDataBlock returns the windns_record block holding the values of the record type as structured fields, or
+// an empty string if the values can only be given in the records list.
+func RecordDataBlock(recordType string) string {
+	switch recordType {
+	case RecordTypeMX:
+		return "mx"
+	case RecordTypeSRV:
+		return "srv"
+	case RecordTypeCAA:
+		return "caa"
+	default:
+		return ""
+	}
+}
+
+// RecordDataFromBlocks returns the values of the structured record data blocks in the format of the records list.
+// Domain names are returned with a trailing dot and CAA tags in lower case, like the DNS server returns them.
+func RecordDataFromBlocks(recordType string, blocks []interface{}) []string {
+	var records []string
+	for _, b := range blocks {
+		m := b.(map[string]interface{})
+		switch recordType {
+		case RecordTypeMX:
+			mx := MXRecordData{Preference: uint16(m["preference"].(int)), Exchange: fqdn(m["exchange"].(string))}
+			records = append(records, mx.String())
+		case RecordTypeSRV:
+			srv := SRVRecordData{
+				Priority: uint16(m["priority"].(int)),
+				Weight:   uint16(m["weight"].(int)),
+				Port:     uint16(m["port"].(int)),
+				Target:   fqdn(m["target"].(string)),
+			}
+			records = append(records, srv.String())
+		case RecordTypeCAA:
+			caa := CAARecordData{
+				Flags: uint8(m["flags"].(int)),
+				Tag:   strings.ToLower(m["tag"].(string)),
+				Value: m["value"].(string),
+			}
+			records = append(records, caa.String())
+		}
+	}
+	return records
+}
+
+// RecordDataToBlocks returns the values of the records list as structured record data blocks.
+func RecordDataToBlocks(recordType string, records []string) ([]interface{}, error) {
+	var blocks []interface{}
+	for _, v := range records {
+		switch recordType {
+		case RecordTypeMX:
+			mx, err := ParseMXRecordData(v)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, map[string]interface{}{"preference": int(mx.Preference), "exchange": fqdn(mx.Exchange)})
+		case RecordTypeSRV:
+			srv, err := ParseSRVRecordData(v)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"priority": int(srv.Priority),
+				"weight":   int(srv.Weight),
+				"port":     int(srv.Port),
+				"target":   fqdn(srv.Target),
+			})
+		case RecordTypeCAA:
+			caa, err := ParseCAARecordData(v)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, map[string]interface{}{"flags": int(caa.Flags), "tag": caa.Tag, "value": caa.Value})
+		}
+	}
+	return blocks, nil
+}
+
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// SRVRecordData is the parsed form of an SRV record value.
+// In the records list it is represented as "<priority> <weight> <port> <target>", e.g. "0 5 88 dc1.example.com".
+type SRVRecordData struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// ParseSRVRecordData parses an SRV record value on the form "<priority> <weight> <port> <target>"
+func ParseSRVRecordData(input string) (*SRVRecordData, error) {
+	fields := strings.Fields(input)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("SRV record must be on the form \"<priority> <weight> <port> <target>\", got: %s", input)
+	}
+
+	var numbers [3]uint16
+	for i, name := range []string{"priority", "weight", "port"} {
+		n, err := strconv.ParseUint(fields[i], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SRV %s %q: %s", name, fields[i], err)
+		}
+		numbers[i] = uint16(n)
+	}
+
+	if !recordInputPattern.MatchString(fields[3]) {
+		return nil, fmt.Errorf("invalid characters detected in input: %s", fields[3])
+	}
+
+	return &SRVRecordData{
+		Priority: numbers[0],
+		Weight:   numbers[1],
+		Port:     numbers[2],
+		Target:   fields[3],
+	}, nil
+}
+
+func (s *SRVRecordData) String() string {
+	return fmt.Sprintf("%d %d %d %s", s.Priority, s.Weight, s.Port, s.Target)
+}
+
+// CAARecordData is the parsed form of a CAA record value.
+// In the records list it is represented as `<flags> <tag> "<value>"`, e.g. `0 issue "letsencrypt.org"`. The quotes
+// around the value may be left out.
+type CAARecordData struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// caaTagPattern matches a CAA property tag, which is 1 to 15 letters and digits
+var caaTagPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,15}$`)
+
+// ParseCAARecordData parses a CAA record value on the form `<flags> <tag> "<value>"`. Tags are case insensitive and
+// returned in lower case.
+func ParseCAARecordData(input string) (*CAARecordData, error) {
+	flags, rest, _ := strings.Cut(strings.TrimSpace(input), " ")
+	tag, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("CAA record must be on the form \"<flags> <tag> <value>\", got: %s", input)
+	}
+
+	n, err := strconv.ParseUint(flags, 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAA flags %q: %s", flags, err)
+	}
+	if !caaTagPattern.MatchString(tag) {
+		return nil, fmt.Errorf("invalid CAA tag %q, expected 1 to 15 letters and digits", tag)
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		value = value[1 : len(value)-1]
+	}
+	for _, c := range value {
+		if c < 0x20 || c > 0x7e || c == '"' {
+			return nil, fmt.Errorf("invalid CAA value %q, expected printable ASCII characters other than quotes", value)
+		}
+	}
+
+	return &CAARecordData{
+		Flags: uint8(n),
+		Tag:   strings.ToLower(tag),
+		Value: value,
+	}, nil
+}
+
+func (c *CAARecordData) String() string {
+	return fmt.Sprintf("%d %s \"%s\"", c.Flags, c.Tag, c.Value)
+}
+
+// NormalizeCAARecordData returns a CAA record value in the form the DNS server returns it, with the tag in lower case
+// and the value in quotes. Invalid values are returned as they are.
+func NormalizeCAARecordData(input string) string {
+	caa, err := ParseCAARecordData(input)
+	if err != nil {
+		return input
+	}
+	return caa.String()
+}
+
+// Hex returns the record data in the wire format as hexadecimal, which is how the DNS server takes and returns
+// record types it has no parameters for: the flags, the length of the tag, the tag and the value.
+func (c *CAARecordData) Hex() string {
+	data := append([]byte{c.Flags, byte(len(c.Tag))}, c.Tag...)
+	return hex.EncodeToString(append(data, c.Value...))
+}
+
+// parseCAARecordDataHex parses CAA record data in the wire format as hexadecimal.
+func parseCAARecordDataHex(input string) (*CAARecordData, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(input), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAA record data %q: %s", input, err)
+	}
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return nil, fmt.Errorf("invalid CAA record data %q: too short", input)
+	}
+	tagEnd := 2 + int(data[1])
+	return &CAARecordData{
+		Flags: data[0],
+		Tag:   strings.ToLower(string(data[2:tagEnd])),
+		Value: string(data[tagEnd:]),
+	}, nil
+}
+
+// txtStringMaxLength is the maximum length of a character-string in a TXT record, in bytes
+const txtStringMaxLength = 255
+
+// splitTXTRecordData splits a TXT value into character-strings of at most 255 bytes, without splitting a character.
+// Longer values, like DKIM keys, are stored by the DNS server as several character-strings.
+func splitTXTRecordData(value string) []string {
+	var parts []string
+	for len(value) > txtStringMaxLength {
+		n := txtStringMaxLength
+		for n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		parts = append(parts, value[:n])
+		value = value[n:]
+	}
+	return append(parts, value)
+}
+
+// joinTXTRecordData joins the character-strings of a TXT record, which the DNS server returns on separate lines
+// in DescriptiveText. Resolvers concatenate them too, so the value is the same as the one that was split.
+func joinTXTRecordData(descriptiveText string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(descriptiveText)
+}
+
+// recordDataFromProperties converts the CimInstanceProperties of a record to the string representation used in the
+// records list. Most record types only have one property, while e.g. MX records consist of several.
+func recordDataFromProperties(recordType string, properties []CimInstanceProperties) (string, error) {
+	if len(properties) == 0 {
+		return "", fmt.Errorf("no record data found for %s record", recordType)
+	}
+
+	switch recordType {
+	case RecordTypeMX:
+		preference, err := strconv.ParseUint(cimPropertyValue(properties, "Preference"), 10, 16)
+		if err != nil {
+			return "", fmt.Errorf("invalid MX preference in record data: %s", err)
+		}
+		mx := MXRecordData{
+			Preference: uint16(preference),
+			Exchange:   cimPropertyValue(properties, "MailExchange"),
+		}
+		return mx.String(), nil
+	case RecordTypeSRV:
+		var numbers [3]uint16
+		for i, name := range []string{"Priority", "Weight", "Port"} {
+			n, err := strconv.ParseUint(cimPropertyValue(properties, name), 10, 16)
+			if err != nil {
+				return "", fmt.Errorf("invalid SRV %s in record data: %s", strings.ToLower(name), err)
+			}
+			numbers[i] = uint16(n)
+		}
+		srv := SRVRecordData{
+			Priority: numbers[0],
+			Weight:   numbers[1],
+			Port:     numbers[2],
+			Target:   cimPropertyValue(properties, "DomainName"),
+		}
+		return srv.String(), nil
+	case RecordTypeTXT:
+		return joinTXTRecordData(properties[0].Value), nil
+	case RecordTypeCAA:
+		caa, err := parseCAARecordDataHex(cimPropertyValue(properties, "Data"))
+		if err != nil {
+			return "", err
+		}
+		return caa.String(), nil
+	case RecordTypeSOA:
+		soa, err := newZoneSOAFromProperties(properties)
+		if err != nil {
+			return "", err
+		}
+		return soa.String(), nil
+	case RecordTypeA, RecordTypeAAAA, RecordTypeCNAME, RecordTypePTR, RecordTypeNS:
+		return properties[0].Value, nil
+	default:
+		return "", fmt.Errorf("unsupported record type %s", recordType)
+	}
+}
+
+// recordDataFilter returns a PowerShell Where-Object filter matching the record value for record types made up of
+// several properties, for TXT records which may be made up of several character-strings, and for CAA records which
+// are matched on their hexadecimal record data. For other record types an empty filter is returned.
+func recordDataFilter(recordType, recordData string) (string, error) {
+	switch recordType {
+	case RecordTypeMX:
+		mx, err := ParseMXRecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$_.RecordData.Preference -eq %d -and $_.RecordData.MailExchange.TrimEnd('.') -eq %s",
+			mx.Preference, quoteArg(strings.TrimSuffix(mx.Exchange, "."))), nil
+	case RecordTypeSRV:
+		srv, err := ParseSRVRecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$_.RecordData.Priority -eq %d -and $_.RecordData.Weight -eq %d -and $_.RecordData.Port -eq %d -and $_.RecordData.DomainName.TrimEnd('.') -eq %s",
+			srv.Priority, srv.Weight, srv.Port, quoteArg(strings.TrimSuffix(srv.Target, "."))), nil
+	case RecordTypeCAA:
+		caa, err := ParseCAARecordData(recordData)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$_.RecordData.Data -eq %s", quoteArg(caa.Hex())), nil
+	case RecordTypeTXT:
+		// TXT records are case sensitive, unlike -eq
+		return fmt.Sprintf("$_.RecordData.DescriptiveText.Replace(\"`r\", '').Replace(\"`n\", '') -ceq %s", quoteArg(recordData)), nil
+	default:
+		return "", nil
+	}
+}
+
+func cimPropertyValue(properties []CimInstanceProperties, name string) string {
+	for _, p := range properties {
+		if strings.EqualFold(p.Name, name) {
+			return p.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestParseMXRecordData(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"test-valid", "10 mail.example.com", "10 mail.example.com", false},
+		{"test-extra-whitespace", " 10   mail.example.com. ", "10 mail.example.com.", false},
+		{"test-missing-preference", "mail.example.com", "", true},
+		{"test-invalid-preference", "x mail.example.com", "", true},
+		{"test-preference-out-of-range", "65536 mail.example.com", "", true},
+		{"test-illegal-character", "10 mail.example.com;", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMXRecordData(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMXRecordData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseMXRecordData() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordDataBlocks(t *testing.T) {
+	tests := []struct {
+		recordType string
+		records    []string
+		blocks     []interface{}
+	}{
+		{
+			RecordTypeMX,
+			[]string{"10 mail1.example.com.", "20 mail2.example.com."},
+			[]interface{}{
+				map[string]interface{}{"preference": 10, "exchange": "mail1.example.com."},
+				map[string]interface{}{"preference": 20, "exchange": "mail2.example.com."},
+			},
+		},
+		{
+			RecordTypeSRV,
+			[]string{"0 100 88 dc1.example.com."},
+			[]interface{}{
+				map[string]interface{}{"priority": 0, "weight": 100, "port": 88, "target": "dc1.example.com."},
+			},
+		},
+		{
+			RecordTypeCAA,
+			[]string{`0 issue "letsencrypt.org"`, `128 iodef "mailto:security@example.com"`},
+			[]interface{}{
+				map[string]interface{}{"flags": 0, "tag": "issue", "value": "letsencrypt.org"},
+				map[string]interface{}{"flags": 128, "tag": "iodef", "value": "mailto:security@example.com"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recordType, func(t *testing.T) {
+			if RecordDataBlock(tt.recordType) == "" {
+				t.Fatalf("RecordDataBlock(%q) is empty", tt.recordType)
+			}
+			blocks, err := RecordDataToBlocks(tt.recordType, tt.records)
+			if err != nil {
+				t.Fatalf("RecordDataToBlocks() error = %v", err)
+			}
+			if !reflect.DeepEqual(blocks, tt.blocks) {
+				t.Errorf("RecordDataToBlocks() = %v, want %v", blocks, tt.blocks)
+			}
+			if got := RecordDataFromBlocks(tt.recordType, tt.blocks); !slices.Equal(got, tt.records) {
+				t.Errorf("RecordDataFromBlocks() = %q, want %q", got, tt.records)
+			}
+		})
+	}
+
+	// Domain names are returned with a trailing dot
+	got := RecordDataFromBlocks(RecordTypeMX, []interface{}{map[string]interface{}{"preference": 10, "exchange": "mail.example.com"}})
+	if !slices.Equal(got, []string{"10 mail.example.com."}) {
+		t.Errorf("RecordDataFromBlocks() = %q", got)
+	}
+	if RecordDataBlock(RecordTypeA) != "" {
+		t.Errorf("RecordDataBlock(%q) = %q, want no block", RecordTypeA, RecordDataBlock(RecordTypeA))
+	}
+}
+
+func TestParseSRVRecordData(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"test-valid", "0 100 88 dc1.example.com", "0 100 88 dc1.example.com", false},
+		{"test-extra-whitespace", "0  100 88  dc1.example.com. ", "0 100 88 dc1.example.com.", false},
+		{"test-missing-field", "0 100 dc1.example.com", "", true},
+		{"test-invalid-port", "0 100 http dc1.example.com", "", true},
+		{"test-port-out-of-range", "0 100 70000 dc1.example.com", "", true},
+		{"test-illegal-character", "0 100 88 dc1.example.com&", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSRVRecordData(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSRVRecordData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("ParseSRVRecordData() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCAARecordData(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantHex string
+		wantErr bool
+	}{
+		{"test-quoted", `0 issue "letsencrypt.org"`, `0 issue "letsencrypt.org"`, "000569737375656c657473656e63727970742e6f7267", false},
+		{"test-unquoted", "128  ISSUEWILD  ca.example.net; account=123", `128 issuewild "ca.example.net; account=123"`, "", false},
+		{"test-empty-value", `0 issue ""`, `0 issue ""`, "00056973737565", false},
+		{"test-iodef", `0 iodef "mailto:security@example.com"`, `0 iodef "mailto:security@example.com"`, "", false},
+		{"test-missing-value", "0 issue", "", "", true},
+		{"test-flags-out-of-range", `256 issue "ca.example.net"`, "", "", true},
+		{"test-invalid-tag", `0 is-sue "ca.example.net"`, "", "", true},
+		{"test-quote-in-value", `0 issue "ca"example.net"`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCAARecordData(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCAARecordData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseCAARecordData() = %q, want %q", got.String(), tt.want)
+			}
+			if tt.wantHex != "" && got.Hex() != tt.wantHex {
+				t.Errorf("Hex() = %q, want %q", got.Hex(), tt.wantHex)
+			}
+			parsed, err := parseCAARecordDataHex(strings.ToUpper(got.Hex()))
+			if err != nil || parsed.String() != tt.want {
+				t.Errorf("parseCAARecordDataHex() = %v, %v, want %q", parsed, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTXTRecordData(t *testing.T) {
+	dkim := "v=DKIM1; k=rsa; p=" + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA", 10)
+	tests := []struct {
+		name    string
+		input   string
+		wantLen []int
+	}{
+		{"test-short", "v=spf1 -all", []int{11}},
+		{"test-empty", "", []int{0}},
+		{"test-255", strings.Repeat("a", 255), []int{255}},
+		{"test-dkim", dkim, []int{255, 203}},
+		// "æ" is two bytes, and isn't split between two strings
+		{"test-multibyte", strings.Repeat("a", 254) + "æbc", []int{254, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitTXTRecordData(tt.input)
+			var gotLen []int
+			for _, part := range got {
+				gotLen = append(gotLen, len(part))
+			}
+			if !slices.Equal(gotLen, tt.wantLen) {
+				t.Errorf("splitTXTRecordData() lengths = %v, want %v", gotLen, tt.wantLen)
+			}
+			if joined := joinTXTRecordData(strings.Join(got, "\r\n")); joined != tt.input {
+				t.Errorf("joinTXTRecordData() = %q, want %q", joined, tt.input)
+			}
+		})
+	}
+}
+
+func TestSanitizeInputString_TXT(t *testing.T) {
+	for _, value := range []string{strings.Repeat("a", 1000), `say "hi" \o/`, "it's $HOME; `whoami`"} {
+		got, err := SanitizeInputString(RecordTypeTXT, value)
+		if err != nil || got != value {
+			t.Errorf("SanitizeInputString(%q) = %q, %v", value, got, err)
+		}
+	}
+	if _, err := SanitizeInputString(RecordTypeTXT, "line1\nline2"); err == nil {
+		t.Error("SanitizeInputString() expected an error for a line break")
+	}
+}
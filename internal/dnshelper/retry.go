@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// ErrorClass is the kind of failure of a command, which decides whether it is retried.
+type ErrorClass string
+
+const (
+	// ErrorClassTransport is a failure to connect to the server or to run the script on it
+	ErrorClassTransport ErrorClass = "transport"
+	// ErrorClassPowerShell is an error record written by the script
+	ErrorClassPowerShell ErrorClass = "powershell"
+	// ErrorClassNotFound is an error record for a zone or record that doesn't exist. They are never retried, since
+	// a read of a deleted zone or record is expected to fail with them.
+	ErrorClassNotFound ErrorClass = "not-found"
+)
+
+// retryBaseWait is the wait before the first retry. It is doubled for every retry, up to retry_max_wait.
+var retryBaseWait = time.Second
+
+const defaultRetryMaxWait = 30 * time.Second
+
+// unreachableErrorPatterns match the error records of failures to reach the DNS server from the server running
+// PowerShell, or of the DNS server being too busy to take the change. The command wasn't run, so it is retried.
+var unreachableErrorPatterns = []string{
+	"WinRM cannot complete the operation",
+	"The RPC server is unavailable",
+	"WIN32 1722", // RPC_S_SERVER_UNAVAILABLE
+	"The directory service is busy",
+	"WIN32 8206", // ERROR_DS_BUSY
+}
+
+// failedErrorPatterns match the error records of failures that are likely to go away when the command is run again,
+// but where the command may have been applied before failing. They are only retried for idempotent commands, since
+// running e.g. an add again would fail because the first attempt succeeded.
+var failedErrorPatterns = []string{
+	"The remote procedure call failed",
+	"WIN32 1726", // RPC_S_CALL_FAILED
+	"WIN32 9002", // DNS_ERROR_RCODE_SERVER_FAILURE
+}
+
+// ClassifyError returns the class of an error returned by PSCommand.Run.
+func ClassifyError(err error) ErrorClass {
+	var psErr *PowerShellError
+	if !errors.As(err, &psErr) {
+		return ErrorClassTransport
+	}
+	if errors.Is(psErr, ErrNotFound) {
+		return ErrorClassNotFound
+	}
+	return ErrorClassPowerShell
+}
+
+// isTransientError returns true if a failed command should be retried. Connection errors are always retried, since
+// the script wasn't run. Network errors while running the script are only retried for idempotent commands, since the
+// script may have been run. Other transport errors, like failed authentication or a host key mismatch, won't go
+// away by retrying. Error records are retried if they match unreachableErrorPatterns, or failedErrorPatterns for
+// idempotent commands.
+func isTransientError(err error, idempotent bool) bool {
+	switch ClassifyError(err) {
+	case ErrorClassTransport:
+		var connErr *config.ConnectionError
+		return errors.As(err, &connErr) || idempotent && config.IsNetworkError(err)
+	case ErrorClassPowerShell:
+		return matchesAny(err, unreachableErrorPatterns) || idempotent && matchesAny(err, failedErrorPatterns)
+	}
+	return false
+}
+
+func matchesAny(err error, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.Contains(err.Error(), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry runs op until it succeeds, it returns a permanent failure or max_retries retries have been made.
+// op returns true if it failed with a transient error. The wait between the attempts grows exponentially, with
+// jitter so parallel operations don't retry in lockstep. Waiting stops when the context is done.
+func withRetry(ctx context.Context, settings *config.Settings, op func() (bool, error)) error {
+	maxWait := time.Duration(settings.RetryMaxWait) * time.Second
+	if maxWait <= 0 {
+		maxWait = defaultRetryMaxWait
+	}
+
+	wait := retryBaseWait
+	for attempt := 0; ; attempt++ {
+		retry, err := op()
+		if !retry || attempt >= settings.MaxRetries {
+			return err
+		}
+
+		if wait > maxWait {
+			wait = maxWait
+		}
+		delay := wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		tflog.Debug(ctx, fmt.Sprintf("Retrying after a transient failure in %s (retry %d of %d)", delay, attempt+1, settings.MaxRetries))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err == nil {
+				err = ctx.Err()
+			}
+			return err
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// newTestRetryConf returns a provider configuration retrying failed commands without waiting.
+func newTestRetryConf(t *testing.T, executor config.Executor, maxRetries int) *config.ProviderConf {
+	t.Helper()
+	baseWait := retryBaseWait
+	retryBaseWait = time.Millisecond
+	t.Cleanup(func() { retryBaseWait = baseWait })
+
+	conf := newTestProviderConf(executor)
+	conf.Settings.MaxRetries = maxRetries
+	return conf
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"transport", errors.New("run error: EOF"), ErrorClassTransport},
+		{"not-found", &PowerShellError{Message: "The zone example.com was not found on server dc1.", FullyQualifiedErrorId: "WIN32 9601,Get-DnsServerZone", Category: "ObjectNotFound"}, ErrorClassNotFound},
+		{"wrapped-not-found", fmt.Errorf("Get-DnsServerZone failed: %w", &PowerShellError{Category: "ObjectNotFound"}), ErrorClassNotFound},
+		{"powershell", &PowerShellError{Message: "Failed to create resource record r1 in zone example.com on server dc1.", FullyQualifiedErrorId: "WIN32 9711,Add-DnsServerResourceRecord", Category: "ResourceExists"}, ErrorClassPowerShell},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	connErr := &config.ConnectionError{Err: errors.New("dial tcp 192.0.2.10:22: i/o timeout")}
+	runErr := fmt.Errorf("run error: %w", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET})
+	hostKeyErr := errors.New("while acquiring ssh client: ssh: handshake failed: host key verification failed for dc1.example.com:22")
+	authErr := errors.New("while acquiring ssh client: ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")
+
+	tests := []struct {
+		name       string
+		err        error
+		idempotent bool
+		want       bool
+	}{
+		{"connection-error", connErr, false, true},
+		{"run-error", runErr, false, false},
+		{"run-error-idempotent", runErr, true, true},
+		{"run-error-eof-idempotent", fmt.Errorf("while reading from powershell session: %w", io.EOF), true, true},
+		{"host-key-mismatch-idempotent", hostKeyErr, true, false},
+		{"auth-error-idempotent", authErr, true, false},
+		{"second-hop", &PowerShellError{Message: "WinRM cannot complete the operation. Verify that the specified computer name is valid"}, false, true},
+		{"rpc-unavailable", &PowerShellError{Message: "Failed to get the zone information for example.com on server dc1.", FullyQualifiedErrorId: "WIN32 1722,Get-DnsServerZone"}, false, true},
+		{"server-failure", &PowerShellError{Message: "Failed to create resource record r1 in zone example.com on server dc1.", FullyQualifiedErrorId: "WIN32 9002,Add-DnsServerResourceRecord"}, false, false},
+		{"server-failure-idempotent", &PowerShellError{Message: "Failed to get the zone information for example.com on server dc1.", FullyQualifiedErrorId: "WIN32 9002,Get-DnsServerZone"}, true, true},
+		{"not-found", &PowerShellError{Message: "The zone example.com was not found on server dc1.", Category: "ObjectNotFound"}, true, false},
+		{"powershell", &PowerShellError{Message: "Failed to create resource record r1 in zone example.com on server dc1.", FullyQualifiedErrorId: "WIN32 9711,Add-DnsServerResourceRecord"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err, tt.idempotent); got != tt.want {
+				t.Errorf("isTransientError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPSCommand_RunRetries(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Get-DnsServerZone", "").Fail(1, "WinRM cannot complete the operation.").Once()
+	executor.On("Get-DnsServerZone", "").Once().Err = &config.ConnectionError{Err: errors.New("i/o timeout")}
+	executor.On("Get-DnsServerZone", `{"ZoneName":"example.com"}`)
+	conf := newTestRetryConf(t, executor, 3)
+
+	zone, err := GetDNSZoneFromId(context.Background(), conf, "example.com")
+	if err != nil {
+		t.Fatalf("GetDNSZoneFromId() error = %v", err)
+	}
+	if zone.ZoneName != "example.com" {
+		t.Errorf("ZoneName = %q", zone.ZoneName)
+	}
+	if n := len(executor.Scripts()); n != 3 {
+		t.Errorf("ran %d scripts, want 3", n)
+	}
+}
+
+func TestPSCommand_RunRetriesExhausted(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Get-DnsServerZone", "").Fail(1, "The RPC server is unavailable.")
+	conf := newTestRetryConf(t, executor, 2)
+
+	_, err := GetDNSZoneFromId(context.Background(), conf, "example.com")
+	if err == nil || !strings.Contains(err.Error(), "The RPC server is unavailable") {
+		t.Fatalf("GetDNSZoneFromId() error = %v, want the last error", err)
+	}
+	if n := len(executor.Scripts()); n != 3 {
+		t.Errorf("ran %d scripts, want 3", n)
+	}
+}
+
+func TestPSCommand_RunDoesNotRetryAuthErrors(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Get-DnsServerZone", "").Err = errors.New("while acquiring ssh client: ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")
+	conf := newTestRetryConf(t, executor, 3)
+
+	// Reading a zone is idempotent, but a wrong password won't get right by retrying
+	_, err := GetDNSZoneFromId(context.Background(), conf, "example.com")
+	if err == nil {
+		t.Fatal("GetDNSZoneFromId() expected an error")
+	}
+	if n := len(executor.Scripts()); n != 1 {
+		t.Errorf("ran %d scripts, want 1", n)
+	}
+}
+
+func TestPSCommand_RunDoesNotRetryPermanentErrors(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Get-DnsServerZone", "").Fail(1, "The zone example.com was not found on server dc1.\n    + CategoryInfo          : ObjectNotFound: (example.com:root/Microsoft/...erverZone) [Get-DnsServerZone], CimException")
+	executor.On("Add-DnsServerPrimaryZone", "").Err = errors.New("run error: connection reset by peer")
+	conf := newTestRetryConf(t, executor, 3)
+
+	_, err := GetDNSZoneFromId(context.Background(), conf, "example.com")
+	if err == nil {
+		t.Fatal("GetDNSZoneFromId() expected an error")
+	}
+
+	// The zone may have been created before the connection was lost, so it isn't created again
+	z := &Zone{ZoneName: "example.com", ReplicationScope: "Domain"}
+	_, err = z.Create(context.Background(), conf)
+	if err == nil {
+		t.Fatal("Create() expected an error")
+	}
+	if n := len(executor.Scripts()); n != 2 {
+		t.Errorf("ran %d scripts, want 2", n)
+	}
+}
+
+func TestRecord_CreateRetriesTransientFailures(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `[{"Index":0,"Error":null},{"Index":1,"Error":{"Message":"The RPC server is unavailable.","FullyQualifiedErrorId":"WIN32 1722,Add-DnsServerResourceRecord"}}]`).Once()
+	executor.On("Add-DNSServerResourceRecord ", `{"Index":1,"Error":null}`)
+	conf := newTestRetryConf(t, executor, 3)
+
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11", "203.0.113.12"}}
+	_, err := r.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	scripts := executor.Scripts()
+	assertScripts(t, scripts, [][]string{
+		{"-IPv4Address '203.0.113.11'", "-IPv4Address '203.0.113.12'"},
+		{"-IPv4Address '203.0.113.12'", "@{ Index = 1;"},
+	})
+	if strings.Contains(scripts[1], "203.0.113.11") {
+		t.Errorf("the retry ran the operation that succeeded: %q", scripts[1])
+	}
+}
+
+func TestRecord_CreateRetryLimit(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `{"Index":0,"Error":{"Message":"The RPC server is unavailable.","FullyQualifiedErrorId":"WIN32 1722,Add-DnsServerResourceRecord"}}`).Once()
+	executor.On("Add-DNSServerResourceRecord ", "").Err = &config.ConnectionError{Err: errors.New("i/o timeout")}
+	conf := newTestRetryConf(t, executor, 2)
+
+	// The retries of the batch are counted together, and the scripts of a retry aren't retried again
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11"}}
+	_, err := r.Create(context.Background(), conf)
+	if err == nil || !strings.Contains(err.Error(), "i/o timeout") {
+		t.Fatalf("Create() error = %v, want the connection error", err)
+	}
+	if n := len(executor.Scripts()); n != 3 {
+		t.Errorf("ran %d scripts, want 3", n)
+	}
+}
+
+func TestRecord_CreateDoesNotRetryServerFailures(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Add-DNSServerResourceRecord ", `{"Index":0,"Error":{"Message":"Failed to create resource record r1 in zone example.com on server dc1.","FullyQualifiedErrorId":"WIN32 9002,Add-DnsServerResourceRecord"}}`)
+	conf := newTestRetryConf(t, executor, 3)
+
+	// The value may have been added before the server failed, so adding it again could fail with ResourceExists
+	r := &Record{ZoneName: "example.com", HostName: "r1", RecordType: RecordTypeA, Records: []string{"203.0.113.11"}}
+	_, err := r.Create(context.Background(), conf)
+	if err == nil || !strings.Contains(err.Error(), "WIN32 9002") {
+		t.Fatalf("Create() error = %v, want the server failure", err)
+	}
+	if n := len(executor.Scripts()); n != 1 {
+		t.Errorf("ran %d scripts, want 1", n)
+	}
+}
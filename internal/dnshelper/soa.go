@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// ZoneSOA is the start of authority record of a zone. The intervals are in seconds.
+type ZoneSOA struct {
+	ZoneName          string
+	PrimaryServer     string
+	ResponsiblePerson string
+	RefreshInterval   int64
+	RetryDelay        int64
+	ExpireLimit       int64
+	MinimumTTL        int64
+	SerialNumber      int64
+}
+
+// soaProperties maps the attributes of the resource to the record data properties of the SOA record
+var soaProperties = []struct {
+	key      string
+	property string
+}{
+	{"primary_server", "PrimaryServer"},
+	{"responsible_person", "ResponsiblePerson"},
+	{"refresh_interval", "RefreshInterval"},
+	{"retry_delay", "RetryDelay"},
+	{"expire_limit", "ExpireLimit"},
+	{"minimum_ttl", "MinimumTimeToLive"},
+}
+
+// Id returns the zone name, since a zone has exactly one SOA record
+func (z *ZoneSOA) Id() string {
+	return z.ZoneName
+}
+
+// NewZoneSOAFromResource returns a new ZoneSOA struct populated from resource data
+func NewZoneSOAFromResource(d *schema.ResourceData) (*ZoneSOA, error) {
+	zoneName, err := SanitiseTFInput(d, "zone_name")
+	if err != nil {
+		return nil, err
+	}
+
+	soa := &ZoneSOA{
+		ZoneName:        zoneName,
+		RefreshInterval: int64(d.Get("refresh_interval").(int)),
+		RetryDelay:      int64(d.Get("retry_delay").(int)),
+		ExpireLimit:     int64(d.Get("expire_limit").(int)),
+		MinimumTTL:      int64(d.Get("minimum_ttl").(int)),
+	}
+	for key, value := range map[string]*string{
+		"primary_server":     &soa.PrimaryServer,
+		"responsible_person": &soa.ResponsiblePerson,
+	} {
+		if d.Get(key).(string) == "" {
+			continue
+		}
+		sanitized, err := SanitiseTFInput(d, key)
+		if err != nil {
+			return nil, err
+		}
+		*value = sanitized
+	}
+	return soa, nil
+}
+
+func GetZoneSOAFromId(ctx context.Context, conf *config.ProviderConf, id string) (*ZoneSOA, error) {
+	zoneName, err := SanitizeInputString("", id)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := newCommand("Get-DnsServerResourceRecord").param("ZoneName", zoneName).param("RRType", "Soa")
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: true,
+		JSONDepth:  4,
+		ForceArray: true,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	result, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("Get-DnsServerResourceRecord failed: %w", err)
+	}
+
+	soa, err := unmarshallZoneSOA(ctx, []byte(result.Stdout))
+	if err != nil {
+		return nil, fmt.Errorf("GetZoneSOAFromId: %w", err)
+	}
+	soa.ZoneName = zoneName
+	return soa, nil
+}
+
+// Update sets the changed fields of the SOA record. Set-DnsServerResourceRecord needs the old and new record
+// objects, so the record is cloned and changed in the same script. The serial number is left as the server has it,
+// and the server increments it for the change.
+func (z *ZoneSOA) Update(ctx context.Context, conf *config.ProviderConf, changes map[string]interface{}) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// The statements are joined in one script, so we add -ComputerName to each cmdlet instead of using
+	// CreatePSCommandOpts.Server
+	get := newCommand("Get-DnsServerResourceRecord").param("ZoneName", z.ZoneName).param("RRType", "Soa").computerName(conf)
+	cmds := []string{"$old = " + get.String(), "$new = $old.Clone()"}
+	for _, p := range soaProperties {
+		if changes[p.key] == nil {
+			continue
+		}
+		var value string
+		switch p.key {
+		case "primary_server":
+			value = quoteArg(z.PrimaryServer)
+		case "responsible_person":
+			value = quoteArg(z.ResponsiblePerson)
+		default:
+			value = fmt.Sprintf("[System.TimeSpan]::FromSeconds(%d)", z.interval(p.key))
+		}
+		cmds = append(cmds, fmt.Sprintf("$new.RecordData.%s = %s", p.property, value))
+	}
+	set := newCommand("Set-DnsServerResourceRecord").param("ZoneName", z.ZoneName).
+		exprParam("OldInputObject", "$old").exprParam("NewInputObject", "$new").computerName(conf)
+	cmds = append(cmds, set.String())
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: false,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+	}
+	psCmd := NewPSCommand([]string{strings.Join(cmds, "; ")}, psOpts)
+
+	_, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("Set-DnsServerResourceRecord failed: %w", err)
+	}
+	return nil
+}
+
+func (z *ZoneSOA) interval(key string) int64 {
+	switch key {
+	case "refresh_interval":
+		return z.RefreshInterval
+	case "retry_delay":
+		return z.RetryDelay
+	case "expire_limit":
+		return z.ExpireLimit
+	default:
+		return z.MinimumTTL
+	}
+}
+
+func unmarshallZoneSOA(ctx context.Context, input []byte) (*ZoneSOA, error) {
+	records, err := unmarshallDNSRecords(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("SOA record %w", ErrNotFound)
+	}
+	return newZoneSOAFromProperties(records[0].RecordData.CimInstanceProperties)
+}
+
+func newZoneSOAFromProperties(properties []CimInstanceProperties) (*ZoneSOA, error) {
+	soa := &ZoneSOA{
+		PrimaryServer:     cimPropertyValue(properties, "PrimaryServer"),
+		ResponsiblePerson: cimPropertyValue(properties, "ResponsiblePerson"),
+	}
+	serial, err := strconv.ParseInt(cimPropertyValue(properties, "SerialNumber"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOA serial number in record data: %s", err)
+	}
+	soa.SerialNumber = serial
+
+	for _, p := range []struct {
+		name  string
+		value *int64
+	}{
+		{"RefreshInterval", &soa.RefreshInterval},
+		{"RetryDelay", &soa.RetryDelay},
+		{"ExpireLimit", &soa.ExpireLimit},
+		{"MinimumTimeToLive", &soa.MinimumTTL},
+	} {
+		seconds, err := parseTimeSpan(cimPropertyValue(properties, p.name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOA %s in record data: %s", p.name, err)
+		}
+		*p.value = seconds
+	}
+	return soa, nil
+}
+
+// String returns the SOA record in the records list, in the order of a zone file:
+// "<primary server> <responsible person> <serial> <refresh> <retry> <expire> <minimum TTL>"
+func (z *ZoneSOA) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", z.PrimaryServer, z.ResponsiblePerson, z.SerialNumber,
+		z.RefreshInterval, z.RetryDelay, z.ExpireLimit, z.MinimumTTL)
+}
+
+// parseTimeSpan returns the seconds of a TimeSpan in the record data. Below the depth of ConvertTo-Json it is the
+// string of the TimeSpan, like "1.00:00:00", and otherwise an object with TotalSeconds.
+func parseTimeSpan(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		var ts struct {
+			TotalSeconds *float64 `json:"TotalSeconds"`
+		}
+		if err := json.Unmarshal([]byte(value), &ts); err != nil || ts.TotalSeconds == nil {
+			return 0, fmt.Errorf("invalid TimeSpan %s", value)
+		}
+		return int64(*ts.TotalSeconds), nil
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	parts := strings.Split(strings.TrimPrefix(value, "-"), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid TimeSpan %q", value)
+	}
+	var days int64
+	if i := strings.Index(parts[0], "."); i >= 0 {
+		d, err := strconv.ParseInt(parts[0][:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TimeSpan %q", value)
+		}
+		days, parts[0] = d, parts[0][i+1:]
+	}
+	// The fraction of a second is ignored
+	parts[2], _, _ = strings.Cut(parts[2], ".")
+
+	var hms [3]int64
+	for i, part := range parts {
+		n, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid TimeSpan %q", value)
+		}
+		hms[i] = n
+	}
+	seconds := days*86400 + hms[0]*3600 + hms[1]*60 + hms[2]
+	if negative {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
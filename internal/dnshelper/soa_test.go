@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+)
+
+func Test_parseTimeSpan(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"00:15:00", 900, false},
+		{"01:00:00", 3600, false},
+		{"1.00:00:00", 86400, false},
+		{"14.02:03:04", 14*86400 + 2*3600 + 3*60 + 4, false},
+		{"00:00:01.5000000", 1, false},
+		{"-00:01:00", -60, false},
+		{`{"Ticks":9000000000,"Days":0,"TotalSeconds":900}`, 900, false},
+		{`{"Days":0}`, 0, true},
+		{"15:00", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseTimeSpan(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeSpan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeSpan() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_unmarshallZoneSOA(t *testing.T) {
+	input := `[{"HostName":"@","RecordType":"SOA","RecordData":{"CimInstanceProperties":[
+{"Name":"ExpireLimit","Value":"1.00:00:00"},{"Name":"MinimumTimeToLive","Value":"01:00:00"},
+{"Name":"PrimaryServer","Value":"dc1.example.com."},{"Name":"RefreshInterval","Value":"00:15:00"},
+{"Name":"ResponsiblePerson","Value":"hostmaster.example.com."},{"Name":"RetryDelay","Value":"00:10:00"},
+{"Name":"SerialNumber","Value":2024010101}]},"TimeToLive":{"TotalSeconds":3600}}]`
+
+	got, err := unmarshallZoneSOA(context.Background(), []byte(input))
+	if err != nil {
+		t.Fatalf("unmarshallZoneSOA() error = %v", err)
+	}
+	want := ZoneSOA{
+		PrimaryServer:     "dc1.example.com.",
+		ResponsiblePerson: "hostmaster.example.com.",
+		RefreshInterval:   900,
+		RetryDelay:        600,
+		ExpireLimit:       86400,
+		MinimumTTL:        3600,
+		SerialNumber:      2024010101,
+	}
+	if *got != want {
+		t.Errorf("unmarshallZoneSOA() = %+v, want %+v", *got, want)
+	}
+	if s := got.String(); s != "dc1.example.com. hostmaster.example.com. 2024010101 900 600 86400 3600" {
+		t.Errorf("String() = %q", s)
+	}
+
+	_, err = unmarshallZoneSOA(context.Background(), []byte("[]"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("unmarshallZoneSOA() error = %v for no records, want %v", err, ErrNotFound)
+	}
+}
+
+func TestZoneSOA_Update(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("Set-DnsServerResourceRecord ", "")
+	conf := newTestProviderConf(executor)
+
+	soa := &ZoneSOA{ZoneName: "example.com", ResponsiblePerson: "dns.example.com", RefreshInterval: 3600, MinimumTTL: 300}
+	err := soa.Update(context.Background(), conf, map[string]interface{}{"responsible_person": "dns.example.com", "refresh_interval": 3600})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	// Nothing is run without changes
+	err = soa.Update(context.Background(), conf, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{{
+		"try { $old = Get-DnsServerResourceRecord -ZoneName 'example.com' -RRType 'Soa' -ComputerName 'dc1.example.com'; " +
+			"$new = $old.Clone(); $new.RecordData.ResponsiblePerson = 'dns.example.com'; " +
+			"$new.RecordData.RefreshInterval = [System.TimeSpan]::FromSeconds(3600); " +
+			"Set-DnsServerResourceRecord -ZoneName 'example.com' -OldInputObject $old -NewInputObject $new -ComputerName 'dc1.example.com' }",
+	}})
+}
+
+func TestZoneSOA_Lifecycle(t *testing.T) {
+	server := fakedns.NewServer("dc1")
+	if err := server.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.NewProviderConf(&config.Settings{})
+	conf.Executor = &serverExecutor{server: server}
+	ctx := context.Background()
+
+	existing, err := GetZoneSOAFromId(ctx, conf, "example.com")
+	if err != nil {
+		t.Fatalf("GetZoneSOAFromId() error = %v", err)
+	}
+
+	soa := &ZoneSOA{ZoneName: "example.com", PrimaryServer: "ns1.example.com", ExpireLimit: 1209600}
+	err = soa.Update(ctx, conf, map[string]interface{}{"primary_server": "ns1.example.com", "expire_limit": 1209600})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	got, err := GetZoneSOAFromId(ctx, conf, "example.com")
+	if err != nil {
+		t.Fatalf("GetZoneSOAFromId() error = %v", err)
+	}
+	want := *existing
+	want.PrimaryServer = "ns1.example.com."
+	want.ExpireLimit = 1209600
+	want.SerialNumber = existing.SerialNumber + 1
+	if *got != want {
+		t.Errorf("GetZoneSOAFromId() = %+v after the update, want %+v", *got, want)
+	}
+
+	_, err = GetZoneSOAFromId(ctx, conf, "missing.example.com")
+	if !errors.Is(err, ErrZoneNotFound) {
+		t.Errorf("GetZoneSOAFromId() error = %v for a missing zone, want %v", err, ErrZoneNotFound)
+	}
+}
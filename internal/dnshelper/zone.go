@@ -0,0 +1,302 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+type Zone struct {
+	ZoneName         string `json:"ZoneName"`
+	NetworkId        string `json:"NetworkId"`
+	ReplicationScope string `json:"ReplicationScope"`
+	ZoneFile         string `json:"ZoneFile"`
+	DynamicUpdate    string `json:"DynamicUpdate"`
+	IsReverseZone    bool   `json:"IsReverseLookupZone"`
+	IsDsIntegrated   bool   `json:"IsDsIntegrated"`
+	ZoneType         string `json:"ZoneType"`
+}
+
+// Id returns the zone name, which is unique within a DNS server
+func (z *Zone) Id() string {
+	return z.ZoneName
+}
+
+// NewDNSZoneFromResource returns a new Zone struct populated from resource data
+func NewDNSZoneFromResource(d *schema.ResourceData) (*Zone, error) {
+	zone := &Zone{}
+
+	networkId := d.Get("network_id").(string)
+	if networkId != "" {
+		zoneName, err := ReverseZoneNameFromNetworkId(networkId)
+		if err != nil {
+			return nil, err
+		}
+		zone.NetworkId = networkId
+		zone.ZoneName = zoneName
+	} else {
+		zoneName, err := SanitizeInputString("", d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		zone.ZoneName = zoneName
+	}
+
+	for key, value := range map[string]*string{
+		"replication_scope": &zone.ReplicationScope,
+		"zone_file":         &zone.ZoneFile,
+		"dynamic_update":    &zone.DynamicUpdate,
+	} {
+		if d.Get(key).(string) == "" {
+			continue
+		}
+		sanitized, err := SanitizeInputString("", d.Get(key).(string))
+		if err != nil {
+			return nil, err
+		}
+		*value = sanitized
+	}
+
+	return zone, nil
+}
+
+// ReverseZoneNameFromNetworkId returns the name Windows DNS gives a reverse lookup zone created from a network ID.
+// IPv4 prefixes must be a multiple of 8 and IPv6 prefixes a multiple of 4, so the zone name maps to whole labels.
+func ReverseZoneNameFromNetworkId(networkId string) (string, error) {
+	ip, ipNet, err := net.ParseCIDR(networkId)
+	if err != nil {
+		return "", fmt.Errorf("invalid network_id %q: %s", networkId, err)
+	}
+	ones, _ := ipNet.Mask.Size()
+
+	var labels []string
+	if ip4 := ipNet.IP.To4(); ip4 != nil && ip.To4() != nil {
+		if ones == 0 || ones%8 != 0 || ones > 24 {
+			return "", fmt.Errorf("network_id %q must have a prefix length of 8, 16 or 24", networkId)
+		}
+		for i := ones/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%d", ip4[i]))
+		}
+		labels = append(labels, "in-addr", "arpa")
+		return strings.Join(labels, "."), nil
+	}
+
+	if ones == 0 || ones%4 != 0 || ones > 124 {
+		return "", fmt.Errorf("network_id %q must have a prefix length that is a multiple of 4", networkId)
+	}
+	nibbles := fmt.Sprintf("%x", []byte(ipNet.IP.To16()))
+	for i := ones/4 - 1; i >= 0; i-- {
+		labels = append(labels, string(nibbles[i]))
+	}
+	labels = append(labels, "ip6", "arpa")
+	return strings.Join(labels, "."), nil
+}
+
+// NetworkIdFromReverseZoneName returns the network ID of a reverse lookup zone, the inverse of ReverseZoneNameFromNetworkId.
+func NetworkIdFromReverseZoneName(zoneName string) (string, error) {
+	name := strings.TrimSuffix(strings.ToLower(zoneName), ".")
+	invalid := fmt.Errorf("zone %q is not a reverse lookup zone for a whole network", zoneName)
+
+	if labels, ok := strings.CutSuffix(name, ".in-addr.arpa"); ok {
+		octets := strings.Split(labels, ".")
+		if len(octets) > 3 {
+			return "", invalid
+		}
+		ip := make(net.IP, net.IPv4len)
+		for i, label := range octets {
+			octet, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || strconv.FormatUint(octet, 10) != label {
+				return "", invalid
+			}
+			ip[len(octets)-1-i] = byte(octet)
+		}
+		return fmt.Sprintf("%s/%d", ip, len(octets)*8), nil
+	}
+
+	if labels, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		nibbles := strings.Split(labels, ".")
+		if len(nibbles) > 31 {
+			return "", invalid
+		}
+		digits := make([]byte, 0, 2*net.IPv6len)
+		for i := len(nibbles) - 1; i >= 0; i-- {
+			if len(nibbles[i]) != 1 {
+				return "", invalid
+			}
+			digits = append(digits, nibbles[i][0])
+		}
+		digits = append(digits, bytes.Repeat([]byte("0"), cap(digits)-len(digits))...)
+		ip, err := hex.DecodeString(string(digits))
+		if err != nil {
+			return "", invalid
+		}
+		return fmt.Sprintf("%s/%d", net.IP(ip), len(nibbles)*4), nil
+	}
+
+	return "", fmt.Errorf("zone %q is not a reverse lookup zone", zoneName)
+}
+
+func GetDNSZoneFromId(ctx context.Context, conf *config.ProviderConf, id string) (*Zone, error) {
+	zoneName, err := SanitizeInputString("", id)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := newCommand("Get-DnsServerZone").param("Name", zoneName)
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: true,
+		JSONDepth:  2,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	result, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("Get-DnsServerZone failed: %w", err)
+	}
+
+	zone, err := unmarshallZone(ctx, []byte(result.Stdout))
+	if err != nil {
+		return nil, fmt.Errorf("GetDNSZoneFromId: %s", err)
+	}
+	return zone, nil
+}
+
+// Create creates a new primary zone in DNS server
+func (z *Zone) Create(ctx context.Context, conf *config.ProviderConf) (string, error) {
+	if z.ZoneName == "" {
+		return "", fmt.Errorf("Zone.Create: missing name or network_id variable")
+	}
+
+	if z.ReplicationScope == "" && z.ZoneFile == "" {
+		return "", fmt.Errorf("Zone.Create: missing replication_scope or zone_file variable")
+	}
+
+	cmd := newCommand("Add-DnsServerPrimaryZone")
+	if z.NetworkId != "" {
+		cmd.param("NetworkId", z.NetworkId)
+	} else {
+		cmd.param("Name", z.ZoneName)
+	}
+
+	if z.ReplicationScope != "" {
+		cmd.param("ReplicationScope", z.ReplicationScope)
+	} else {
+		cmd.param("ZoneFile", z.ZoneFile)
+	}
+
+	if z.DynamicUpdate != "" {
+		cmd.param("DynamicUpdate", z.DynamicUpdate)
+	}
+
+	psOpts := CreatePSCommandOpts{
+		JSONOutput: false,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	_, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return "", fmt.Errorf("Add-DnsServerPrimaryZone failed: %w", err)
+	}
+
+	return z.Id(), nil
+}
+
+// Update updates the settings of an existing primary zone in DNS server
+func (z *Zone) Update(ctx context.Context, conf *config.ProviderConf, changes map[string]interface{}) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	cmd := newCommand("Set-DnsServerPrimaryZone").param("Name", z.ZoneName)
+
+	if changes["replication_scope"] != nil && z.ReplicationScope != "" {
+		cmd.param("ReplicationScope", z.ReplicationScope)
+	}
+
+	if changes["dynamic_update"] != nil && z.DynamicUpdate != "" {
+		cmd.param("DynamicUpdate", z.DynamicUpdate)
+	}
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: false,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	_, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("Set-DnsServerPrimaryZone failed: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes an existing zone in DNS server
+func (z *Zone) Delete(ctx context.Context, conf *config.ProviderConf) error {
+	cmd := newCommand("Remove-DnsServerZone").switchParam("Force").param("Name", z.ZoneName)
+
+	psOpts := CreatePSCommandOpts{
+		JSONOutput: false,
+		ForceArray: false,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	_, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("Remove-DnsServerZone failed: %w", err)
+	}
+	return nil
+}
+
+func unmarshallZone(ctx context.Context, input []byte) (*Zone, error) {
+	var zone Zone
+
+	t := bytes.TrimSpace(input)
+	if len(t) == 0 {
+		return nil, fmt.Errorf("empty json document")
+	}
+
+	err := json.Unmarshal(t, &zone)
+	if err != nil {
+		tflog.Debug(ctx, fmt.Sprintf("Failed to unmarshall a Zone json document with error %q, document was %s", err, string(input)))
+		return nil, fmt.Errorf("failed while unmarshalling Zone json document: %s", err)
+	}
+
+	if zone.ZoneName == "" {
+		return nil, fmt.Errorf("invalid data while unmarshalling Zone data, json doc was: %s", string(input))
+	}
+
+	// ReplicationScope is "None" for zones that are not stored in Active Directory
+	if !zone.IsDsIntegrated {
+		zone.ReplicationScope = ""
+	}
+
+	return &zone, nil
+}
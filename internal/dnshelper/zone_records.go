@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+)
+
+// GetDNSRecordsInZone returns every record set in a zone, optionally limited to one record type.
+// All records are fetched with one Get-DnsServerResourceRecord call and grouped by name and type.
+func GetDNSRecordsInZone(ctx context.Context, conf *config.ProviderConf, zoneName string, recordType string) ([]*Record, error) {
+	zoneName, err := SanitizeInputString("", zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := newCommand("Get-DnsServerResourceRecord").param("ZoneName", zoneName)
+	if recordType != "" {
+		recordType, err = SanitizeInputString("", recordType)
+		if err != nil {
+			return nil, err
+		}
+		recordTypeParam(cmd, recordType)
+	}
+
+	psOpts := CreatePSCommandOpts{
+		Idempotent: true,
+		JSONOutput: true,
+		JSONDepth:  4,
+		ForceArray: true,
+		Username:   conf.Settings.SshUsername,
+		Password:   conf.Settings.SshPassword,
+		Server:     conf.Settings.DnsServer,
+	}
+	psCmd := NewPSCommand([]string{cmd.String()}, psOpts)
+
+	result, err := psCmd.Run(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("Get-DnsServerResourceRecord failed: %w", err)
+	}
+
+	// A zone or a type filter without records gives no output at all
+	if len(bytes.TrimSpace([]byte(result.Stdout))) == 0 {
+		return []*Record{}, nil
+	}
+
+	records, err := unmarshallZoneRecords(ctx, []byte(result.Stdout))
+	if err != nil {
+		return nil, fmt.Errorf("GetDNSRecordsInZone: %s", err)
+	}
+
+	for _, record := range records {
+		record.ZoneName = zoneName
+	}
+	return records, nil
+}
+
+// unmarshallZoneRecords groups records of mixed names and types into record sets, sorted by name and type.
+// Records of other types than recordTypes, and records whose record data can't be read, are left out, so they
+// don't stop the rest of the zone from being listed.
+func unmarshallZoneRecords(ctx context.Context, input []byte) ([]*Record, error) {
+	dnsRecords, err := unmarshallDNSRecords(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	groups := make(map[string][]DNSRecord)
+	for _, r := range dnsRecords {
+		if !slices.Contains(recordTypes, r.RecordType) {
+			tflog.Debug(ctx, fmt.Sprintf("Skipping the %s record %s, since the record type isn't supported", r.RecordType, r.HostName))
+			continue
+		}
+		key := strings.Join([]string{strings.ToLower(r.HostName), r.RecordType}, IDSeparator)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := groups[keys[i]][0], groups[keys[j]][0]
+		if !strings.EqualFold(a.HostName, b.HostName) {
+			return strings.ToLower(a.HostName) < strings.ToLower(b.HostName)
+		}
+		return a.RecordType < b.RecordType
+	})
+
+	records := make([]*Record, 0, len(keys))
+	for _, key := range keys {
+		record, err := newRecordFromDNSRecords(groups[key])
+		if err != nil {
+			r := groups[key][0]
+			tflog.Warn(ctx, fmt.Sprintf("Skipping the %s records %s: %s", r.RecordType, r.HostName, err))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
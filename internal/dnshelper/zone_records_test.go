@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func Test_unmarshallZoneRecords(t *testing.T) {
+	input := `[
+	{"HostName":"www","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.11"}]},"TimeToLive":{"TotalSeconds":300}},
+	{"HostName":"@","RecordType":"MX","RecordData":{"CimInstanceProperties":[{"Name":"MailExchange","Value":"mail.example.com."},{"Name":"Preference","Value":10}]},"TimeToLive":{"TotalSeconds":3600}},
+	{"HostName":"WWW","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.12"}]},"TimeToLive":{"TotalSeconds":300}},
+	{"HostName":"www","RecordType":"AAAA","RecordData":{"CimInstanceProperties":[{"Name":"IPv6Address","Value":"2001:db8::1"}]},"TimeToLive":{"TotalSeconds":300}},
+	{"HostName":"@","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.1"}]},"TimeToLive":{"TotalSeconds":3600}},
+	{"HostName":"@","RecordType":"DNSKEY","RecordData":{"CimInstanceProperties":[{"Name":"Base64Data","Value":"AwEAAag="}]},"TimeToLive":{"TotalSeconds":3600}},
+	{"HostName":"@","RecordType":"SOA","RecordData":{"CimInstanceProperties":[]},"TimeToLive":{"TotalSeconds":3600}}
+	]`
+
+	// The DNSKEY record isn't supported, and the SOA record without record data can't be read, so they are left out
+
+	want := []struct {
+		hostName   string
+		recordType string
+		records    []string
+	}{
+		{"@", "A", []string{"203.0.113.1"}},
+		{"@", "MX", []string{"10 mail.example.com."}},
+		{"www", "A", []string{"203.0.113.11", "203.0.113.12"}},
+		{"www", "AAAA", []string{"2001:db8::1"}},
+	}
+
+	got, err := unmarshallZoneRecords(context.Background(), []byte(input))
+	if err != nil {
+		t.Fatalf("unmarshallZoneRecords() error = %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("unmarshallZoneRecords() returned %d record sets, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].HostName != w.hostName || got[i].RecordType != w.recordType || !slices.Equal(got[i].Records, w.records) {
+			t.Errorf("unmarshallZoneRecords()[%d] = %s %s %q, want %s %s %q", i, got[i].HostName, got[i].RecordType, got[i].Records, w.hostName, w.recordType, w.records)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+
+package dnshelper
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReverseZoneNameFromNetworkId(t *testing.T) {
+	tests := []struct {
+		name      string
+		networkId string
+		want      string
+		wantErr   bool
+	}{
+		{"test-ipv4-8", "10.0.0.0/8", "10.in-addr.arpa", false},
+		{"test-ipv4-16", "10.10.0.0/16", "10.10.in-addr.arpa", false},
+		{"test-ipv4-24", "192.0.2.0/24", "2.0.192.in-addr.arpa", false},
+		{"test-ipv4-unaligned", "192.0.2.0/25", "", true},
+		{"test-ipv6-32", "2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa", false},
+		{"test-ipv6-unaligned", "2001:db8::/33", "", true},
+		{"test-invalid", "10.10.0.0", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReverseZoneNameFromNetworkId(tt.networkId)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReverseZoneNameFromNetworkId() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReverseZoneNameFromNetworkId() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkIdFromReverseZoneName(t *testing.T) {
+	tests := []struct {
+		name     string
+		zoneName string
+		want     string
+		wantErr  bool
+	}{
+		{"test-ipv4-8", "10.in-addr.arpa", "10.0.0.0/8", false},
+		{"test-ipv4-16", "10.10.in-addr.arpa", "10.10.0.0/16", false},
+		{"test-ipv4-24", "2.0.192.in-addr.arpa", "192.0.2.0/24", false},
+		{"test-ipv4-classless", "0-25.2.0.192.in-addr.arpa", "", true},
+		{"test-ipv6-32", "8.b.d.0.1.0.0.2.ip6.arpa", "2001:db8::/32", false},
+		{"test-ipv6-uppercase", "8.B.D.0.1.0.0.2.IP6.ARPA.", "2001:db8::/32", false},
+		{"test-ipv6-invalid", "8.bd.0.1.0.0.2.ip6.arpa", "", true},
+		{"test-forward", "example.com", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NetworkIdFromReverseZoneName(tt.zoneName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NetworkIdFromReverseZoneName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NetworkIdFromReverseZoneName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_unmarshallZone(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		wantReplicationScope string
+		wantZoneFile         string
+		wantErr              bool
+	}{
+		{
+			"test-ds-integrated",
+			`{"ZoneName":"example.com","ZoneType":"Primary","IsDsIntegrated":true,"ReplicationScope":"Domain","DynamicUpdate":"Secure","ZoneFile":null}`,
+			"Domain", "", false,
+		},
+		{
+			"test-zone-file",
+			`{"ZoneName":"example.com","ZoneType":"Primary","IsDsIntegrated":false,"ReplicationScope":"None","DynamicUpdate":"None","ZoneFile":"example.com.dns"}`,
+			"", "example.com.dns", false,
+		},
+		{
+			"test-empty", ``, "", "", true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshallZone(context.Background(), []byte(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshallZone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.ReplicationScope != tt.wantReplicationScope {
+				t.Errorf("unmarshallZone() replication scope = %q, want %q", got.ReplicationScope, tt.wantReplicationScope)
+			}
+			if got.ZoneFile != tt.wantZoneFile {
+				t.Errorf("unmarshallZone() zone file = %q, want %q", got.ZoneFile, tt.wantZoneFile)
+			}
+		})
+	}
+}
+
+func TestZone_CreateUpdateDelete(t *testing.T) {
+	executor := NewFakeExecutor()
+	executor.On("try \\{ Add-DnsServerPrimaryZone ", "")
+	executor.On("try \\{ Set-DnsServerPrimaryZone ", "")
+	executor.On("try \\{ Remove-DnsServerZone ", "")
+	conf := newTestProviderConf(executor)
+
+	zone := &Zone{ZoneName: "10.10.in-addr.arpa", NetworkId: "10.10.0.0/16", ReplicationScope: "Domain", DynamicUpdate: "Secure"}
+	id, err := zone.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if id != "10.10.in-addr.arpa" {
+		t.Errorf("Create() id = %q", id)
+	}
+
+	zone.ReplicationScope = "Forest"
+	err = zone.Update(context.Background(), conf, map[string]interface{}{"replication_scope": "Forest"})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	err = zone.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	assertScripts(t, executor.Scripts(), [][]string{
+		{"Add-DnsServerPrimaryZone -NetworkId '10.10.0.0/16' -ReplicationScope 'Domain' -DynamicUpdate 'Secure' -ComputerName 'dc1.example.com'"},
+		{"Set-DnsServerPrimaryZone -Name '10.10.in-addr.arpa' -ReplicationScope 'Forest' -ComputerName 'dc1.example.com'"},
+		{"Remove-DnsServerZone -Force -Name '10.10.in-addr.arpa'"},
+	})
+}
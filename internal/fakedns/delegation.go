@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// delegation is a name server of a delegated child zone, like the DnsServerZoneDelegation objects returned by
+// Get-DnsServerZoneDelegation. The server returns one object per name server.
+type delegation struct {
+	zone       *Zone
+	nameServer *Record
+}
+
+// glueRecords returns the IP addresses of the name server as A and AAAA records.
+func (d *delegation) glueRecords() []*Record {
+	name := strings.TrimSuffix(d.nameServer.Data["NameServer"].(string), ".")
+	var records []*Record
+	for _, address := range d.nameServer.glue {
+		record := &Record{HostName: name, RecordType: "A", Data: map[string]any{"IPv4Address": address}, TTL: d.nameServer.TTL, zone: d.zone.Name}
+		if net.ParseIP(address).To4() == nil {
+			record.RecordType = "AAAA"
+			record.Data = map[string]any{"IPv6Address": address}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// findDelegations returns the NS records below the zone apex, which delegate a child zone. All delegations are
+// returned if childZoneName is empty.
+func (z *Zone) findDelegations(childZoneName string) []*Record {
+	var found []*Record
+	for _, r := range z.findRecords(childZoneName, "NS") {
+		if r.HostName != "@" {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func delegationNotFound(s *Server, r *runner, cmd *command, zone *Zone, childZoneName string) *psError {
+	return &psError{
+		message:  fmt.Sprintf("Failed to get the zone delegation %s in zone %s on server %s.", childZoneName, zone.Name, r.serverName(s, cmd)),
+		category: "ObjectNotFound",
+		target:   childZoneName,
+		errorId:  "WIN32 9714",
+	}
+}
+
+func getZoneDelegation(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	childZoneName, err := r.param(cmd, "ChildZoneName")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	hostName := ""
+	if childZoneName != "" {
+		hostName = relativeName(zone, childZoneName)
+	}
+
+	records := zone.findDelegations(hostName)
+	if len(records) == 0 && childZoneName != "" {
+		return nil, delegationNotFound(s, r, cmd, zone, childZoneName)
+	}
+
+	var output []any
+	for _, record := range records {
+		output = append(output, &delegation{zone: zone, nameServer: record})
+	}
+	return output, nil
+}
+
+func addZoneDelegation(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	childZoneName, err := r.requiredParam(cmd, "ChildZoneName")
+	if err != nil {
+		return nil, err
+	}
+	nameServer, err := r.requiredParam(cmd, "NameServer")
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := r.listParam(cmd, "IPAddress")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	hostName := relativeName(zone, childZoneName)
+	if hostName == "@" {
+		return nil, invalidArgument("The child zone name %s must be below the zone %s.", childZoneName, zone.Name)
+	}
+	record := &Record{
+		HostName:   hostName,
+		RecordType: "NS",
+		Data:       map[string]any{"NameServer": strings.TrimSuffix(nameServer, ".") + "."},
+		TTL:        defaultTTL,
+	}
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, invalidArgument("Cannot process argument transformation on parameter 'IPAddress'. Cannot convert value \"%s\" to type \"System.Net.IPAddress\".", address)
+		}
+		record.glue = append(record.glue, ip.String())
+	}
+	return nil, zone.addRecord(r.serverName(s, cmd), record)
+}
+
+// setZoneDelegation replaces the glue addresses of a name server of the delegation. Like the real cmdlet, it
+// needs at least one address.
+func setZoneDelegation(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	childZoneName, err := r.requiredParam(cmd, "ChildZoneName")
+	if err != nil {
+		return nil, err
+	}
+	nameServer, err := r.requiredParam(cmd, "NameServer")
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := r.listParam(cmd, "IPAddress")
+	if err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, invalidArgument("Cannot bind argument to parameter 'IPAddress' because it is an empty array.")
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	var glue []string
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, invalidArgument("Cannot process argument transformation on parameter 'IPAddress'. Cannot convert value \"%s\" to type \"System.Net.IPAddress\".", address)
+		}
+		glue = append(glue, ip.String())
+	}
+	for _, record := range zone.findDelegations(relativeName(zone, childZoneName)) {
+		if recordDataMatches(record, nameServer) {
+			record.glue = glue
+			zone.incrementSerial()
+			return nil, nil
+		}
+	}
+	return nil, delegationNotFound(s, r, cmd, zone, childZoneName)
+}
+
+func removeZoneDelegation(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	childZoneName, err := r.requiredParam(cmd, "ChildZoneName")
+	if err != nil {
+		return nil, err
+	}
+	nameServer, err := r.param(cmd, "NameServer")
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.has("Force") {
+		return nil, invalidArgument("Remove-DnsServerZoneDelegation needs -Force when run non-interactively")
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	var toRemove []*Record
+	for _, record := range zone.findDelegations(relativeName(zone, childZoneName)) {
+		if nameServer == "" || recordDataMatches(record, nameServer) {
+			toRemove = append(toRemove, record)
+		}
+	}
+	if len(toRemove) == 0 {
+		return nil, delegationNotFound(s, r, cmd, zone, childZoneName)
+	}
+	for _, record := range toRemove {
+		zone.removeRecord(record)
+	}
+	return nil, nil
+}
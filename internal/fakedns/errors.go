@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"fmt"
+	"strings"
+)
+
+// psError is an error record formatted like Windows PowerShell writes errors to stderr. The provider looks for the
+// category (e.g. ObjectNotFound) and the error id in the output.
+type psError struct {
+	cmdlet    string
+	message   string
+	category  string
+	target    string
+	errorId   string
+	exception string
+}
+
+func (e *psError) Error() string {
+	return e.message
+}
+
+func (e *psError) format() string {
+	exception := e.exception
+	if exception == "" {
+		exception = "CimException"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s : %s\n", e.cmdlet, e.message)
+	fmt.Fprintf(&b, "    + CategoryInfo          : %s: (%s:) [%s], %s\n", e.category, e.target, e.cmdlet, exception)
+	fmt.Fprintf(&b, "    + FullyQualifiedErrorId : %s,%s\n", e.errorId, e.cmdlet)
+	return b.String()
+}
+
+// categoryInfo returns the CategoryInfo property of the error record.
+func (e *psError) categoryInfo() *hashtable {
+	exception := e.exception
+	if exception == "" {
+		exception = "CimException"
+	}
+	h := &hashtable{values: make(map[string]any)}
+	h.set("Category", e.category)
+	h.set("Reason", exception)
+	h.set("TargetName", e.target)
+	return h
+}
+
+// invalidArgument returns an error for parameters the fake server can't handle, or PowerShell would reject.
+func invalidArgument(format string, a ...any) *psError {
+	return &psError{
+		message:   fmt.Sprintf(format, a...),
+		category:  "InvalidArgument",
+		errorId:   "InvalidArgument",
+		exception: "ParameterBindingException",
+	}
+}
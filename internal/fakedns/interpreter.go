@@ -0,0 +1,1311 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result is the output of a script run by the fake DNS server.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// timeSpan is a [System.TimeSpan], in seconds
+type timeSpan int64
+
+// String formats the time span like TimeSpan.ToString(), e.g. 1.00:00:00 for a day.
+func (t timeSpan) String() string {
+	s := fmt.Sprintf("%02d:%02d:%02d", t/3600%24, t/60%60, t%60)
+	if t >= 86400 {
+		s = fmt.Sprintf("%d.%s", t/86400, s)
+	}
+	return s
+}
+
+type cmdlet func(s *Server, r *runner, cmd *command, input []any) ([]any, error)
+
+var cmdlets map[string]cmdlet
+
+func init() {
+	cmdlets = map[string]cmdlet{
+		"get-dnsserverresourcerecord":    getResourceRecord,
+		"add-dnsserverresourcerecord":    addResourceRecord,
+		"remove-dnsserverresourcerecord": removeResourceRecord,
+		"set-dnsserverresourcerecord":    setResourceRecord,
+		"get-dnsserverzone":              getZone,
+		"add-dnsserverprimaryzone":       addPrimaryZone,
+		"set-dnsserverprimaryzone":       setPrimaryZone,
+		"remove-dnsserverzone":           removeZone,
+		"get-dnsserverzonedelegation":    getZoneDelegation,
+		"add-dnsserverzonedelegation":    addZoneDelegation,
+		"set-dnsserverzonedelegation":    setZoneDelegation,
+		"remove-dnsserverzonedelegation": removeZoneDelegation,
+		"where-object":                   whereObject,
+		"foreach-object":                 forEachObject,
+		"convertto-json":                 convertToJson,
+		"out-null":                       outNull,
+	}
+}
+
+// runner holds the variables and output of one script run.
+type runner struct {
+	vars   map[string]any
+	stdout strings.Builder
+}
+
+// Run runs a PowerShell script against the DNS server. The script is run like Windows PowerShell would run
+// `powershell.exe -Command`, except that the first error stops the script, with exit code 1.
+//
+// Only the cmdlets and parameters the provider uses are supported: the DnsServer cmdlets for records, primary
+// zones and zone delegations, Where-Object and ForEach-Object with simple script blocks, ConvertTo-Json and
+// Out-Null. -ComputerName is accepted and ignored. Errors are always terminating, like with
+// $ErrorActionPreference = 'Stop', so they can be caught with try/catch.
+func (s *Server) Run(script string) *Result {
+	s.mx.Lock()
+	latency := s.latency
+	s.mx.Unlock()
+	time.Sleep(latency)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	r := &runner{vars: make(map[string]any)}
+	err := r.run(s, script)
+	result := &Result{Stdout: r.stdout.String()}
+	if err != nil {
+		var pe *psError
+		if !errors.As(err, &pe) {
+			pe = &psError{message: err.Error(), category: "ParserError", errorId: "ParseException", exception: "ParseException"}
+		}
+		result.Stderr = pe.format()
+		result.ExitCode = 1
+	}
+	return result
+}
+
+func (r *runner) run(s *Server, script string) error {
+	tokens, err := tokenize(script, r.vars)
+	if err != nil {
+		return err
+	}
+	statements, err := parse(tokens)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range statements {
+		output, err := r.runStatement(s, stmt)
+		if err != nil {
+			return err
+		}
+		for _, o := range output {
+			r.stdout.WriteString(formatObject(o))
+			r.stdout.WriteString("\n")
+		}
+	}
+	return nil
+}
+
+// runBlock runs the statements of a script block or array subexpression, and returns their output.
+func (r *runner) runBlock(s *Server, block string) ([]any, error) {
+	tokens, err := tokenize(block, r.vars)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	var output []any
+	for _, stmt := range statements {
+		out, err := r.runStatement(s, stmt)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, out...)
+	}
+	return output, nil
+}
+
+// runTry runs a try/catch statement. Errors from cmdlets are caught, with the error record in $_, while other
+// errors like parse errors fail the script.
+func (r *runner) runTry(s *Server, stmt *statement) ([]any, error) {
+	output, err := r.runBlock(s, stmt.tryBlock)
+	var pe *psError
+	if err == nil || !errors.As(err, &pe) {
+		return output, err
+	}
+
+	previous, hadPrevious := r.vars["_"]
+	r.vars["_"] = &errorRecord{err: pe}
+	caught, err := r.runBlock(s, stmt.catchBlock)
+	if hadPrevious {
+		r.vars["_"] = previous
+	} else {
+		delete(r.vars, "_")
+	}
+	return append(output, caught...), err
+}
+
+func (r *runner) runStatement(s *Server, stmt *statement) ([]any, error) {
+	if stmt.tryBlock != "" {
+		return r.runTry(s, stmt)
+	}
+	if stmt.target != "" {
+		var value any
+		var err error
+		if len(stmt.tokens) == 1 {
+			value, err = r.evalExpr(s, stmt.tokens[0])
+		} else {
+			var pipeline *statement
+			pipeline, err = parseStatement(stmt.tokens)
+			if err == nil {
+				var output []any
+				output, err = r.runPipeline(s, pipeline.pipeline)
+				// Like PowerShell, a single object is assigned as it is rather than as an array
+				value = output
+				if len(output) == 1 {
+					value = output[0]
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, r.assign(stmt.target, value)
+	}
+	return r.runPipeline(s, stmt.pipeline)
+}
+
+func (r *runner) runPipeline(s *Server, pipeline []*command) ([]any, error) {
+	var objects []any
+	for i, cmd := range pipeline {
+		if cmd.expr != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("expressions are only allowed as the first element of a pipeline")
+			}
+			value, err := r.evalExpr(s, *cmd.expr)
+			if err != nil {
+				return nil, err
+			}
+			objects = flatten(value)
+			continue
+		}
+		f, ok := cmdlets[cmd.name]
+		if !ok {
+			return nil, &psError{
+				cmdlet:    cmd.name,
+				message:   fmt.Sprintf("The term '%s' is not recognized as the name of a cmdlet, function, script file, or operable program.", cmd.name),
+				category:  "ObjectNotFound",
+				target:    cmd.name,
+				errorId:   "CommandNotFoundException",
+				exception: "CommandNotFoundException",
+			}
+		}
+		cmd.piped = i > 0
+		output, err := f(s, r, cmd, objects)
+		if err != nil {
+			var pe *psError
+			if errors.As(err, &pe) && pe.cmdlet == "" {
+				pe.cmdlet = canonicalCmdletName(cmd.name)
+			}
+			return nil, err
+		}
+		objects = output
+	}
+	return objects, nil
+}
+
+func flatten(value any) []any {
+	if list, ok := value.([]any); ok {
+		return list
+	}
+	if value == nil {
+		return nil
+	}
+	return []any{value}
+}
+
+func canonicalCmdletName(name string) string {
+	for _, n := range []string{
+		"Get-DnsServerResourceRecord", "Add-DnsServerResourceRecord", "Remove-DnsServerResourceRecord",
+		"Set-DnsServerResourceRecord", "Get-DnsServerZone", "Add-DnsServerPrimaryZone", "Set-DnsServerPrimaryZone",
+		"Remove-DnsServerZone", "Get-DnsServerZoneDelegation", "Add-DnsServerZoneDelegation",
+		"Set-DnsServerZoneDelegation", "Remove-DnsServerZoneDelegation", "Where-Object", "ForEach-Object", "ConvertTo-Json", "Out-Null",
+	} {
+		if strings.EqualFold(n, name) {
+			return n
+		}
+	}
+	return name
+}
+
+// assign sets a variable, or a property of the record in a variable, e.g. `$new.TimeToLive = ...` or
+// `$new.RecordData.RefreshInterval = ...`
+func (r *runner) assign(target string, value any) error {
+	path := splitPath(strings.TrimPrefix(target, "$"))
+	name := strings.ToLower(path[0])
+	if len(path) == 1 {
+		r.vars[name] = value
+		return nil
+	}
+
+	obj, ok := r.vars[name]
+	if !ok {
+		return fmt.Errorf("the variable '$%s' cannot be retrieved because it has not been set", path[0])
+	}
+	record, ok := obj.(*Record)
+	switch {
+	case ok && len(path) == 2 && strings.EqualFold(path[1], "TimeToLive"):
+		ttl, ok := value.(timeSpan)
+		if !ok {
+			return fmt.Errorf("cannot convert %v to System.TimeSpan", value)
+		}
+		record.TTL = int64(ttl)
+		return nil
+	case ok && len(path) == 3 && strings.EqualFold(path[1], "RecordData"):
+		for _, p := range recordTypes[record.RecordType].properties {
+			if strings.EqualFold(p.name, path[2]) {
+				converted, err := convertProperty(p, value)
+				if err != nil {
+					return err
+				}
+				record.Data[p.name] = converted
+				return nil
+			}
+		}
+		return fmt.Errorf("the property '%s' cannot be found on this object", path[2])
+	}
+	return fmt.Errorf("assigning %s is not supported", target)
+}
+
+// convertProperty converts a value assigned to a record data property to the type of the property.
+func convertProperty(p propertyDef, value any) (any, error) {
+	switch p.cimType {
+	case cimTypeDateTime:
+		if t, ok := value.(timeSpan); ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("cannot convert %v to System.TimeSpan", value)
+	case cimTypeUInt16, cimTypeUInt32:
+		n, err := strconv.ParseUint(fmt.Sprint(value), 10, 32)
+		if err != nil || (p.cimType == cimTypeUInt16 && n > 0xffff) {
+			return nil, fmt.Errorf("cannot convert %v to the type of %s", value, p.name)
+		}
+		return int64(n), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot convert %v to System.String", value)
+	}
+	if p.fqdn {
+		s = strings.TrimSuffix(s, ".") + "."
+	}
+	return s, nil
+}
+
+// evalExpr evaluates a value like eval, and also array subexpressions, which run statements.
+func (r *runner) evalExpr(s *Server, t token) (any, error) {
+	if t.kind == tokenArray {
+		output, err := r.runBlock(s, t.value)
+		if err != nil {
+			return nil, err
+		}
+		if output == nil {
+			output = []any{}
+		}
+		return output, nil
+	}
+	return r.eval(t)
+}
+
+// eval evaluates a value: a string, a number, a variable with properties and method calls, a parenthesized
+// expression, a hashtable literal or [System.TimeSpan]::FromSeconds(n).
+func (r *runner) eval(t token) (any, error) {
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+	case tokenHashtable:
+		return r.evalHashtable(t.value)
+	case tokenParen:
+		tokens, err := tokenize(t.value, r.vars)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) != 1 {
+			return nil, fmt.Errorf("unsupported expression (%s)", t.value)
+		}
+		return r.eval(tokens[0])
+	case tokenBlock:
+		return t, nil
+	case tokenList:
+		var values []any
+		for _, item := range t.items {
+			value, err := r.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	value := t.value
+	lower := strings.ToLower(value)
+	switch {
+	case lower == "$true":
+		return true, nil
+	case lower == "$false":
+		return false, nil
+	case lower == "$null":
+		return nil, nil
+	case strings.HasPrefix(value, "$"):
+		return r.evalPath(value)
+	case strings.HasPrefix(lower, "[system.timespan]::fromseconds(") && strings.HasSuffix(value, ")"):
+		arg := value[len("[system.timespan]::fromseconds(") : len(value)-1]
+		n, err := strconv.ParseInt(strings.TrimSpace(arg), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument to FromSeconds: %s", arg)
+		}
+		return timeSpan(n), nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	return value, nil
+}
+
+// splitPath splits a property path like `_.RecordData.MailExchange.TrimEnd('.')` on dots outside of parentheses.
+func splitPath(path string) []string {
+	var parts []string
+	depth, start := 0, 0
+	inQuote := false
+	for i, c := range path {
+		switch {
+		case c == '\'':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '.' && depth == 0:
+			parts = append(parts, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, path[start:])
+}
+
+func (r *runner) evalPath(expr string) (any, error) {
+	path := splitPath(strings.TrimPrefix(expr, "$"))
+	value, ok := r.vars[strings.ToLower(path[0])]
+	if !ok {
+		value = nil
+	}
+
+	for _, member := range path[1:] {
+		if i := strings.Index(member, "("); i >= 0 && strings.HasSuffix(member, ")") {
+			var err error
+			value, err = callMethod(value, member[:i], member[i+1:len(member)-1])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		value = property(value, member)
+	}
+	return value, nil
+}
+
+// evalHashtable evaluates the entries of a hashtable literal, on the form `Key = value` separated by `;` or newlines.
+func (r *runner) evalHashtable(inner string) (*hashtable, error) {
+	tokens, err := tokenize(inner, r.vars)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &hashtable{values: make(map[string]any)}
+	var entry []token
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].kind != tokenSemicolon {
+			entry = append(entry, tokens[i])
+			continue
+		}
+		if len(entry) == 0 {
+			continue
+		}
+		if len(entry) != 3 || entry[1].kind != tokenWord || entry[1].value != "=" {
+			return nil, fmt.Errorf("unsupported hashtable entry in @{%s}", inner)
+		}
+		value, err := r.eval(entry[2])
+		if err != nil {
+			return nil, err
+		}
+		h.set(entry[0].value, value)
+		entry = nil
+	}
+	return h, nil
+}
+
+// recordData is the RecordData property of a record, which exposes the properties of the record data
+type recordData map[string]any
+
+// hashtable is a hashtable literal like @{ Name = 'value' }. The keys keep their order, so the JSON output is stable.
+type hashtable struct {
+	keys   []string
+	values map[string]any
+}
+
+func (h *hashtable) set(key string, value any) {
+	if _, ok := h.values[key]; !ok {
+		h.keys = append(h.keys, key)
+	}
+	h.values[key] = value
+}
+
+// errorRecord is the error caught by a catch block, available as $_.
+type errorRecord struct {
+	err *psError
+}
+
+func (e *errorRecord) String() string {
+	return e.err.message
+}
+
+// exception is the Exception property of an error record.
+type exception struct {
+	message string
+	errorId string
+}
+
+// errorData returns the ErrorData of a CimException, which holds the Win32 error code of the DnsServer cmdlets.
+func (e *exception) errorData() *hashtable {
+	code, err := strconv.ParseInt(strings.TrimPrefix(e.errorId, "WIN32 "), 10, 64)
+	if !strings.HasPrefix(e.errorId, "WIN32 ") || err != nil {
+		return nil
+	}
+	h := &hashtable{values: make(map[string]any)}
+	h.set("error_Code", code)
+	return h
+}
+
+func property(obj any, name string) any {
+	switch o := obj.(type) {
+	case *Record:
+		switch strings.ToLower(name) {
+		case "hostname":
+			return o.HostName
+		case "recordtype":
+			if recordTypes[o.RecordType].unknown {
+				return "UNKNOWN"
+			}
+			return o.RecordType
+		case "type":
+			return recordTypes[o.RecordType].number
+		case "timetolive":
+			return timeSpan(o.TTL)
+		case "recorddata":
+			return recordData(o.Data)
+		}
+	case recordData:
+		for k, v := range o {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+	case timeSpan:
+		if strings.EqualFold(name, "TotalSeconds") {
+			return int64(o)
+		}
+	case *hashtable:
+		for _, k := range o.keys {
+			if strings.EqualFold(k, name) {
+				return o.values[k]
+			}
+		}
+	case *errorRecord:
+		switch strings.ToLower(name) {
+		case "exception":
+			return &exception{message: o.err.message, errorId: o.err.errorId}
+		case "fullyqualifiederrorid":
+			return o.err.errorId + "," + o.err.cmdlet
+		case "categoryinfo":
+			return o.err.categoryInfo()
+		}
+	case *exception:
+		switch strings.ToLower(name) {
+		case "message":
+			return o.message
+		case "errordata":
+			return o.errorData()
+		}
+	case *Zone:
+		switch strings.ToLower(name) {
+		case "zonename":
+			return o.Name
+		case "dynamicupdate":
+			return o.DynamicUpdate
+		case "replicationscope":
+			return o.ReplicationScope
+		}
+	}
+	return nil
+}
+
+func callMethod(obj any, name, arg string) (any, error) {
+	switch strings.ToLower(name) {
+	case "clone":
+		if record, ok := obj.(*Record); ok {
+			return record.Clone(), nil
+		}
+	case "trimend":
+		if s, ok := obj.(string); ok {
+			chars := strings.Trim(strings.TrimSpace(arg), "'\"")
+			return strings.TrimRight(s, chars), nil
+		}
+	case "tolower":
+		if s, ok := obj.(string); ok {
+			return strings.ToLower(s), nil
+		}
+	case "tostring":
+		return fmt.Sprint(obj), nil
+	case "replace":
+		args, err := methodArgs(arg)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := obj.(string); ok && len(args) == 2 {
+			return strings.ReplaceAll(s, args[0], args[1]), nil
+		}
+	}
+	return nil, fmt.Errorf("method invocation failed because [%T] does not contain a method named '%s'", obj, name)
+}
+
+// methodArgs returns the string arguments of a method call, like the strings to replace in Replace().
+func methodArgs(arg string) ([]string, error) {
+	tokens, err := tokenize(arg, nil)
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	for _, t := range tokens {
+		switch {
+		case t.kind == tokenString:
+			args = append(args, t.value)
+		case t.kind != tokenWord || t.value != ",":
+			return nil, fmt.Errorf("unsupported method argument %s", arg)
+		}
+	}
+	return args, nil
+}
+
+// evalCondition evaluates a Where-Object filter made up of comparisons joined by -and and -or, e.g.
+// `$_.RecordData.Preference -eq 10 -and $_.RecordData.MailExchange.TrimEnd('.') -eq 'mail.example.com'`
+func (r *runner) evalCondition(tokens []token) (bool, error) {
+	var result bool
+	join := "-or"
+	for len(tokens) > 0 {
+		if len(tokens) < 3 {
+			return false, fmt.Errorf("unsupported filter")
+		}
+		left, err := r.eval(tokens[0])
+		if err != nil {
+			return false, err
+		}
+		right, err := r.eval(tokens[2])
+		if err != nil {
+			return false, err
+		}
+		value, err := compare(strings.ToLower(tokens[1].value), left, right)
+		if err != nil {
+			return false, err
+		}
+
+		if join == "-and" {
+			result = result && value
+		} else {
+			result = result || value
+		}
+
+		tokens = tokens[3:]
+		if len(tokens) > 0 {
+			join = strings.ToLower(tokens[0].value)
+			if join != "-and" && join != "-or" {
+				return false, fmt.Errorf("unsupported operator %s", tokens[0].value)
+			}
+			tokens = tokens[1:]
+		}
+	}
+	return result, nil
+}
+
+// compare compares two values like PowerShell: the right value is converted to the type of the left value, and
+// strings are compared case insensitively, except with -ceq and -cne.
+func compare(op string, left, right any) (bool, error) {
+	var equal bool
+	switch l := left.(type) {
+	case int64:
+		n, err := strconv.ParseInt(fmt.Sprint(right), 10, 64)
+		equal = err == nil && n == l
+	case nil:
+		equal = right == nil
+	default:
+		if op == "-ceq" || op == "-cne" {
+			equal = fmt.Sprint(left) == fmt.Sprint(right)
+		} else {
+			equal = strings.EqualFold(fmt.Sprint(left), fmt.Sprint(right))
+		}
+	}
+
+	switch op {
+	case "-eq", "-ceq":
+		return equal, nil
+	case "-ne", "-cne":
+		return !equal, nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", op)
+}
+
+func whereObject(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	if len(cmd.args) != 1 || cmd.args[0].kind != tokenBlock {
+		return nil, invalidArgument("Where-Object needs a script block")
+	}
+
+	var output []any
+	for _, obj := range input {
+		r.vars["_"] = obj
+		tokens, err := tokenize(cmd.args[0].value, r.vars)
+		if err != nil {
+			return nil, err
+		}
+		match, err := r.evalCondition(tokens)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			output = append(output, obj)
+		}
+	}
+	delete(r.vars, "_")
+	return output, nil
+}
+
+func forEachObject(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	if len(cmd.args) != 1 || cmd.args[0].kind != tokenBlock {
+		return nil, invalidArgument("ForEach-Object needs a script block")
+	}
+
+	var output []any
+	for _, obj := range input {
+		r.vars["_"] = obj
+		tokens, err := tokenize(cmd.args[0].value, r.vars)
+		if err != nil {
+			return nil, err
+		}
+		statements, err := parse(tokens)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range statements {
+			out, err := r.runStatement(s, stmt)
+			if err != nil {
+				return nil, err
+			}
+			output = append(output, out...)
+		}
+	}
+	delete(r.vars, "_")
+	return output, nil
+}
+
+// param returns the evaluated value of a parameter as a string, or "" if the parameter is not set.
+func (r *runner) param(cmd *command, name string) (string, error) {
+	t := cmd.param(name)
+	if t == nil {
+		return "", nil
+	}
+	value, err := r.eval(*t)
+	if err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func (r *runner) requiredParam(cmd *command, name string) (string, error) {
+	value, err := r.param(cmd, name)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", invalidArgument("Cannot validate argument on parameter '%s'. The argument is null or empty.", name)
+	}
+	return value, nil
+}
+
+// listParam returns the evaluated values of a parameter taking an array, like -IPAddress.
+func (r *runner) listParam(cmd *command, name string) ([]string, error) {
+	value, err := r.objectParam(cmd, name)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, v := range flatten(value) {
+		values = append(values, fmt.Sprint(v))
+	}
+	return values, nil
+}
+
+func (r *runner) objectParam(cmd *command, name string) (any, error) {
+	t := cmd.param(name)
+	if t == nil {
+		return nil, nil
+	}
+	return r.eval(*t)
+}
+
+// serverName returns the value of -ComputerName, or the name of the server.
+func (r *runner) serverName(s *Server, cmd *command) string {
+	if name, err := r.param(cmd, "ComputerName"); err == nil && name != "" {
+		return name
+	}
+	return s.name
+}
+
+func getResourceRecord(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "ZoneName")
+	if err != nil {
+		return nil, err
+	}
+	name, err := r.param(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	recordType, err := r.param(cmd, "RRType")
+	if err != nil {
+		return nil, err
+	}
+	recordType = strings.ToUpper(recordType)
+	if cmd.has("Type") {
+		recordType, err = r.recordTypeParam(cmd)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	hostName := ""
+	if name != "" {
+		hostName = relativeName(zone, name)
+	}
+
+	records := zone.findRecords(hostName, recordType)
+	if len(records) == 0 && name != "" {
+		return nil, &psError{
+			message:  fmt.Sprintf("Failed to get %s record in %s zone on %s server.", name, zone.Name, r.serverName(s, cmd)),
+			category: "ObjectNotFound",
+			target:   name,
+			errorId:  "WIN32 9714",
+		}
+	}
+
+	var output []any
+	for _, record := range records {
+		output = append(output, record)
+	}
+	return output, nil
+}
+
+// recordTypeParam returns the record type selected by its number with -Type.
+func (r *runner) recordTypeParam(cmd *command) (string, error) {
+	value, err := r.param(cmd, "Type")
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return "", invalidArgument("Cannot process argument transformation on parameter 'Type'. Cannot convert value \"%s\" to type \"System.UInt16\".", value)
+	}
+	for t, def := range recordTypes {
+		if def.number == int64(n) {
+			return t, nil
+		}
+	}
+	return "", invalidArgument("Record type %d is not supported.", n)
+}
+
+func addResourceRecord(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "ZoneName")
+	if err != nil {
+		return nil, err
+	}
+	name, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+
+	recordType := ""
+	for t, def := range recordTypes {
+		if def.switchParam != "" && cmd.has(def.switchParam) {
+			if recordType != "" {
+				return nil, invalidArgument("Parameter set cannot be resolved using the specified named parameters.")
+			}
+			recordType = t
+		}
+	}
+	if recordType == "" && cmd.has("Type") {
+		recordType, err = r.recordTypeParam(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if !recordTypes[recordType].unknown {
+			return nil, invalidArgument("Record type %s must be added with its own parameters rather than -RecordData.", recordType)
+		}
+	}
+	if recordType == "" {
+		return nil, invalidArgument("Parameter set cannot be resolved using the specified named parameters.")
+	}
+
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &Record{
+		HostName:   relativeName(zone, name),
+		RecordType: recordType,
+		Data:       make(map[string]any),
+		TTL:        defaultTTL,
+	}
+	for _, p := range recordTypes[recordType].properties {
+		value, err := r.requiredParam(cmd, p.param)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.cimType == cimTypeUInt16:
+			n, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return nil, invalidArgument("Cannot process argument transformation on parameter '%s'. Cannot convert value \"%s\" to type \"System.UInt16\".", p.name, value)
+			}
+			record.Data[p.name] = int64(n)
+		case p.fqdn:
+			record.Data[p.name] = strings.TrimSuffix(value, ".") + "."
+		case recordTypes[recordType].unknown:
+			if _, err := hex.DecodeString(value); err != nil || value == "" {
+				return nil, invalidArgument("Cannot process argument transformation on parameter 'RecordData'. The record data \"%s\" is not hexadecimal.", value)
+			}
+			record.Data[p.name] = strings.ToLower(value)
+		case recordType == "A" || recordType == "AAAA":
+			ip := net.ParseIP(value)
+			if ip == nil || (recordType == "A") != (ip.To4() != nil) {
+				return nil, invalidArgument("Cannot process argument transformation on parameter '%s'. Cannot convert value \"%s\" to type \"System.Net.IPAddress\".", p.name, value)
+			}
+			record.Data[p.name] = ip.String()
+		case recordType == "TXT":
+			text, err := descriptiveText(value)
+			if err != nil {
+				return nil, err
+			}
+			record.Data[p.name] = text
+		default:
+			record.Data[p.name] = value
+		}
+	}
+
+	if ttl, err := r.objectParam(cmd, "TimeToLive"); err != nil {
+		return nil, err
+	} else if ttl != nil {
+		seconds, ok := ttl.(timeSpan)
+		if !ok {
+			return nil, invalidArgument("Cannot convert value \"%v\" to type \"System.TimeSpan\".", ttl)
+		}
+		record.TTL = int64(seconds)
+	}
+
+	err = zone.addRecord(r.serverName(s, cmd), record)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.has("CreatePtr") && (recordType == "A" || recordType == "AAAA") {
+		ip := net.ParseIP(record.Data[recordTypes[recordType].properties[0].name].(string))
+		reverseZone, ptrName := s.reverseZoneFor(ip)
+		if reverseZone == nil {
+			// Like the DNS server, a missing reverse lookup zone is a warning, not an error
+			return []any{fmt.Sprintf("WARNING: Failed to create PTR record for %s. No reverse lookup zone found.", ip)}, nil
+		}
+		ptr := &Record{
+			HostName:   ptrName,
+			RecordType: "PTR",
+			Data:       map[string]any{"PtrDomainName": fqdn(zone, record.HostName)},
+			TTL:        record.TTL,
+		}
+		if err := reverseZone.addRecord(r.serverName(s, cmd), ptr); err != nil {
+			var pe *psError
+			if !errors.As(err, &pe) || pe.category != "ResourceExists" {
+				return nil, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+func removeResourceRecord(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "ZoneName")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.has("Force") {
+		return nil, invalidArgument("Remove-DnsServerResourceRecord needs -Force when run non-interactively")
+	}
+
+	// Records piped from Get-DnsServerResourceRecord
+	if cmd.piped {
+		for _, obj := range input {
+			record, ok := obj.(*Record)
+			if !ok || !zone.removeRecord(record) {
+				return nil, &psError{
+					message:  fmt.Sprintf("Failed to remove the resource record in zone %s on server %s.", zone.Name, r.serverName(s, cmd)),
+					category: "ObjectNotFound",
+					errorId:  "WIN32 9714",
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	name, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	recordType, err := r.requiredParam(cmd, "RRType")
+	if err != nil {
+		return nil, err
+	}
+	recordType = strings.ToUpper(recordType)
+	recordData, err := r.param(cmd, "RecordData")
+	if err != nil {
+		return nil, err
+	}
+
+	if recordType == "SOA" {
+		return nil, invalidArgument("The SOA record of a zone can't be removed")
+	}
+	var toRemove []*Record
+	for _, record := range zone.findRecords(relativeName(zone, name), recordType) {
+		if recordData == "" || recordDataMatches(record, recordData) {
+			toRemove = append(toRemove, record)
+		}
+	}
+	if len(toRemove) == 0 {
+		return nil, &psError{
+			message:  fmt.Sprintf("Failed to remove the resource record %s in zone %s on server %s.", name, zone.Name, r.serverName(s, cmd)),
+			category: "ObjectNotFound",
+			target:   name,
+			errorId:  "WIN32 9714",
+		}
+	}
+	for _, record := range toRemove {
+		zone.removeRecord(record)
+	}
+	return nil, nil
+}
+
+// descriptiveText returns the DescriptiveText of a TXT record as the DNS server stores it. Every line is a
+// character-string of at most 255 bytes, and the strings are returned on separate lines.
+func descriptiveText(value string) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n")
+	for _, line := range lines {
+		if len(line) > 255 {
+			return "", &psError{
+				message:  "Failed to create resource record. The parameter is incorrect.",
+				category: "InvalidArgument",
+				errorId:  "WIN32 87",
+			}
+		}
+	}
+	return strings.Join(lines, "\r\n"), nil
+}
+
+// recordDataMatches matches a record against the -RecordData parameter of Remove-DnsServerResourceRecord,
+// which is only supported for record types with a single property.
+func recordDataMatches(record *Record, recordData string) bool {
+	def := recordTypes[record.RecordType]
+	if len(def.properties) != 1 {
+		return false
+	}
+	p := def.properties[0]
+	value := fmt.Sprint(record.Data[p.name])
+	switch {
+	case p.fqdn:
+		return strings.EqualFold(strings.TrimSuffix(value, "."), strings.TrimSuffix(recordData, "."))
+	case record.RecordType == "A" || record.RecordType == "AAAA":
+		ip := net.ParseIP(recordData)
+		return ip != nil && ip.String() == value
+	default:
+		return value == recordData
+	}
+}
+
+func setResourceRecord(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	zoneName, err := r.requiredParam(cmd, "ZoneName")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.getZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+	oldObj, err := r.objectParam(cmd, "OldInputObject")
+	if err != nil {
+		return nil, err
+	}
+	newObj, err := r.objectParam(cmd, "NewInputObject")
+	if err != nil {
+		return nil, err
+	}
+	oldRecord, ok1 := oldObj.(*Record)
+	newRecord, ok2 := newObj.(*Record)
+	if !ok1 || !ok2 {
+		return nil, invalidArgument("Set-DnsServerResourceRecord needs -OldInputObject and -NewInputObject")
+	}
+
+	for _, record := range zone.findRecords("", "") {
+		if record == oldRecord || (strings.EqualFold(record.HostName, oldRecord.HostName) && record.sameData(oldRecord)) {
+			if !strings.EqualFold(newRecord.HostName, record.HostName) || newRecord.RecordType != record.RecordType {
+				return nil, invalidArgument("The name and type of a record can't be changed")
+			}
+			serial := zone.SOA.Data["SerialNumber"].(int64)
+			record.Data = newRecord.Clone().Data
+			record.TTL = newRecord.TTL
+			if record == zone.SOA {
+				// The DNS server never lets the serial number go backwards, and increments it for the change
+				if newSerial := record.Data["SerialNumber"].(int64); newSerial > serial {
+					serial = newSerial - 1
+				}
+				record.Data["SerialNumber"] = serial
+			}
+			zone.incrementSerial()
+			return nil, nil
+		}
+	}
+	return nil, &psError{
+		message:  fmt.Sprintf("Resource record %s in zone %s on server %s not found.", oldRecord.HostName, zone.Name, r.serverName(s, cmd)),
+		category: "ObjectNotFound",
+		target:   oldRecord.HostName,
+		errorId:  "WIN32 9714",
+	}
+}
+
+func getZone(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	name, err := r.param(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		zone, err := s.getZone(name)
+		if err != nil {
+			return nil, err
+		}
+		return []any{zone}, nil
+	}
+
+	var names []string
+	for name := range s.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var output []any
+	for _, name := range names {
+		output = append(output, s.zones[name])
+	}
+	return output, nil
+}
+
+func addPrimaryZone(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	name, err := r.param(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	networkId, err := r.param(cmd, "NetworkId")
+	if err != nil {
+		return nil, err
+	}
+	if (name == "") == (networkId == "") {
+		return nil, invalidArgument("Parameter set cannot be resolved using the specified named parameters.")
+	}
+	if networkId != "" {
+		name, err = reverseZoneFromNetworkId(networkId)
+		if err != nil {
+			return nil, invalidArgument("The network ID %s is not valid.", networkId)
+		}
+	}
+
+	zone := &Zone{Name: strings.TrimSuffix(name, ".")}
+	zone.ReplicationScope, err = r.param(cmd, "ReplicationScope")
+	if err != nil {
+		return nil, err
+	}
+	zone.ZoneFile, err = r.param(cmd, "ZoneFile")
+	if err != nil {
+		return nil, err
+	}
+	if (zone.ReplicationScope == "") == (zone.ZoneFile == "") {
+		return nil, invalidArgument("Parameter set cannot be resolved using the specified named parameters.")
+	}
+	if zone.ReplicationScope != "" {
+		if zone.ReplicationScope, err = oneOf("ReplicationScope", zone.ReplicationScope, "Forest", "Domain", "Legacy", "Custom"); err != nil {
+			return nil, err
+		}
+	}
+
+	zone.DynamicUpdate, err = r.param(cmd, "DynamicUpdate")
+	if err != nil {
+		return nil, err
+	}
+	if zone.DynamicUpdate == "" {
+		zone.DynamicUpdate = "None"
+		if zone.ReplicationScope != "" {
+			zone.DynamicUpdate = "Secure"
+		}
+	} else if zone.DynamicUpdate, err = oneOf("DynamicUpdate", zone.DynamicUpdate, "None", "Secure", "NonsecureAndSecure"); err != nil {
+		return nil, err
+	}
+	if zone.ZoneFile != "" && zone.DynamicUpdate == "Secure" {
+		return nil, invalidArgument("Secure dynamic updates are only supported for Active Directory integrated zones.")
+	}
+
+	_, err = s.addZone(zone)
+	return nil, err
+}
+
+func setPrimaryZone(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	name, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	zone, err := s.getZone(name)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := r.param(cmd, "ReplicationScope")
+	if err != nil {
+		return nil, err
+	}
+	dynamicUpdate, err := r.param(cmd, "DynamicUpdate")
+	if err != nil {
+		return nil, err
+	}
+	if scope != "" {
+		if scope, err = oneOf("ReplicationScope", scope, "Forest", "Domain", "Legacy", "Custom"); err != nil {
+			return nil, err
+		}
+		zone.ReplicationScope = scope
+		zone.ZoneFile = ""
+	}
+	if dynamicUpdate != "" {
+		if dynamicUpdate, err = oneOf("DynamicUpdate", dynamicUpdate, "None", "Secure", "NonsecureAndSecure"); err != nil {
+			return nil, err
+		}
+		zone.DynamicUpdate = dynamicUpdate
+	}
+	return nil, nil
+}
+
+func removeZone(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	name, err := r.requiredParam(cmd, "Name")
+	if err != nil {
+		return nil, err
+	}
+	if !cmd.has("Force") {
+		return nil, invalidArgument("Remove-DnsServerZone needs -Force when run non-interactively")
+	}
+	zone, err := s.getZone(name)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.zones, strings.ToLower(zone.Name))
+	return nil, nil
+}
+
+// oneOf validates an enum parameter, and returns the value with the canonical casing.
+func oneOf(param, value string, valid ...string) (string, error) {
+	for _, v := range valid {
+		if strings.EqualFold(v, value) {
+			return v, nil
+		}
+	}
+	return "", invalidArgument("Cannot validate argument on parameter '%s'. The argument \"%s\" does not belong to the set \"%s\".",
+		param, value, strings.Join(valid, ","))
+}
+
+func convertToJson(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+
+	var values []any
+	for _, obj := range input {
+		values = append(values, toJSONObject(obj))
+	}
+
+	var doc any = values
+	if len(values) == 1 {
+		doc = values[0]
+	}
+
+	var out []byte
+	var err error
+	if cmd.has("Compress") {
+		out, err = json.Marshal(doc)
+	} else {
+		out, err = json.MarshalIndent(doc, "", "    ")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []any{string(out)}, nil
+}
+
+func outNull(s *Server, r *runner, cmd *command, input []any) ([]any, error) {
+	return nil, nil
+}
+
+// formatObject formats an object written to the output without ConvertTo-Json.
+func formatObject(obj any) string {
+	switch o := obj.(type) {
+	case *Record:
+		return fmt.Sprintf("%-20s %-6s %-8d %s", o.HostName, o.RecordType, o.TTL, o.Value())
+	case *Zone:
+		return fmt.Sprintf("%-30s Primary", o.Name)
+	case *delegation:
+		return fmt.Sprintf("%-30s %s %s", fqdn(o.zone, o.nameServer.HostName), o.nameServer.Data["NameServer"], strings.Join(o.nameServer.glue, ","))
+	default:
+		return fmt.Sprint(obj)
+	}
+}
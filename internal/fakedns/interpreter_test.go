@@ -0,0 +1,503 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer("dc1")
+	for _, zone := range []string{"example.com", "10.10.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"} {
+		if err := s.AddZone(zone); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func mustRun(t *testing.T, s *Server, script string) string {
+	t.Helper()
+	result := s.Run(script)
+	if result.ExitCode != 0 {
+		t.Fatalf("script %q failed with exit code %d: %s", script, result.ExitCode, result.Stderr)
+	}
+	return result.Stdout
+}
+
+func recordValues(t *testing.T, s *Server, zone, name, recordType string) []string {
+	t.Helper()
+	var values []string
+	z := s.Zone(zone)
+	if z == nil {
+		t.Fatalf("zone %s not found", zone)
+	}
+	for _, r := range z.findRecords(name, recordType) {
+		values = append(values, r.Value())
+	}
+	return values
+}
+
+func TestServer_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		scripts    []string
+		zone       string
+		host       string
+		recordType string
+		want       []string
+	}{
+		{
+			name: "add-a",
+			scripts: []string{
+				"$ProgressPreference = 'SilentlyContinue';Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11 -ComputerName dc1",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.12",
+			},
+			zone: "example.com", host: "r1", recordType: "A",
+			want: []string{"203.0.113.11", "203.0.113.12"},
+		},
+		{
+			name: "add-aaaa-create-ptr",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -AAAA -IPv6Address 2001:DB8::1 -CreatePtr",
+			},
+			zone: "8.b.d.0.1.0.0.2.ip6.arpa", host: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0", recordType: "PTR",
+			want: []string{"r1.example.com."},
+		},
+		{
+			name: "add-txt-escaped",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText \"a `&b`;c `(d`) $%'e\"",
+			},
+			zone: "example.com", host: "r1", recordType: "TXT",
+			want: []string{"a &b;c (d) $%'e"},
+		},
+		{
+			name: "add-txt-strings",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText 'v=DKIM1; p=\"a\"\nb\\c'",
+			},
+			zone: "example.com", host: "r1", recordType: "TXT",
+			want: []string{"v=DKIM1; p=\"a\"\r\nb\\c"},
+		},
+		{
+			name: "add-cname-adds-dot",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -CNAME -HostNameAlias cname.example.com",
+			},
+			zone: "example.com", host: "r1", recordType: "CNAME",
+			want: []string{"cname.example.com."},
+		},
+		{
+			name: "add-ns-delegation",
+			scripts: []string{
+				"Add-DnsServerZoneDelegation -Name example.com -ChildZoneName team -NameServer ns1.team.example.com -IPAddress '203.0.113.53', '2001:DB8::53'",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name team -NS -NameServer ns.example.net",
+			},
+			zone: "example.com", host: "team", recordType: "NS",
+			want: []string{"ns1.team.example.com.", "ns.example.net."},
+		},
+		{
+			name: "remove-record-data",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -PTR -PtrDomainName host.example.com",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -PTR -PtrDomainName host2.example.com",
+				"Remove-DnsServerResourceRecord -Force -ZoneName example.com -RRType PTR -Name r1 -RecordData \"host.example.com\"",
+			},
+			zone: "example.com", host: "r1", recordType: "PTR",
+			want: []string{"host2.example.com."},
+		},
+		{
+			name: "remove-mx-by-filter",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name @ -MX -MailExchange mail.example.com -Preference 10",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name @ -MX -MailExchange mail.example.com -Preference 20",
+				"Get-DnsServerResourceRecord -ZoneName example.com -Name @ -RRType MX -ComputerName dc1 | Where-Object { $_.RecordData.Preference -eq 10 -and $_.RecordData.MailExchange.TrimEnd('.') -eq 'mail.example.com' } | Remove-DnsServerResourceRecord -Force -ZoneName example.com -ComputerName dc1",
+			},
+			zone: "example.com", host: "@", recordType: "MX",
+			want: []string{"20 mail.example.com."},
+		},
+		{
+			name: "remove-txt-by-filter",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText 'ab\ncd'",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText 'ABCD'",
+				"Get-DnsServerResourceRecord -ZoneName example.com -Name r1 -RRType TXT | Where-Object { $_.RecordData.DescriptiveText.Replace(\"`r\", '').Replace(\"`n\", '') -ceq 'abcd' } | Remove-DnsServerResourceRecord -Force -ZoneName example.com",
+			},
+			zone: "example.com", host: "r1", recordType: "TXT",
+			want: []string{"ABCD"},
+		},
+		{
+			name: "remove-srv-by-filter",
+			scripts: []string{
+				"Add-DNSServerResourceRecord -ZoneName example.com -name _ldap._tcp -SRV -DomainName dc1.example.com -Priority 0 -Weight 100 -Port 389",
+				"Add-DNSServerResourceRecord -ZoneName example.com -name _ldap._tcp -SRV -DomainName dc2.example.com -Priority 0 -Weight 100 -Port 389",
+				"Get-DnsServerResourceRecord -ZoneName example.com -Name _ldap._tcp -RRType SRV | Where-Object { $_.RecordData.Priority -eq 0 -and $_.RecordData.Weight -eq 100 -and $_.RecordData.Port -eq 389 -and $_.RecordData.DomainName.TrimEnd('.') -eq 'dc1.example.com' } | Remove-DnsServerResourceRecord -Force -ZoneName example.com",
+			},
+			zone: "example.com", host: "_ldap._tcp", recordType: "SRV",
+			want: []string{"0 100 389 dc2.example.com."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			for _, script := range tt.scripts {
+				mustRun(t, s, script)
+			}
+			got := recordValues(t, s, tt.zone, tt.host, tt.recordType)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_RunSetTTL(t *testing.T) {
+	s := newTestServer(t)
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11")
+	mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -Name r1 -RRType A | ForEach-Object { $new = $_.Clone(); $new.TimeToLive = [System.TimeSpan]::FromSeconds(600); Set-DnsServerResourceRecord -ZoneName example.com -OldInputObject $_ -NewInputObject $new }")
+
+	records := s.Zone("example.com").findRecords("r1", "A")
+	if len(records) != 1 || records[0].TTL != 600 {
+		t.Errorf("TTL was not updated: %+v", records)
+	}
+}
+
+func TestServer_RunConvertToJson(t *testing.T) {
+	s := newTestServer(t)
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -MX -MailExchange mail.example.com -Preference 10")
+
+	out := mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -Name r1 -RRType MX | ConvertTo-Json -Depth 4")
+	var record struct {
+		HostName   string
+		RecordType string
+		RecordData struct {
+			CimInstanceProperties []struct {
+				Name  string
+				Value any
+			}
+		}
+		TimeToLive struct{ TotalSeconds int64 }
+	}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatalf("a single record should be a JSON object: %s\n%s", err, out)
+	}
+	if record.HostName != "r1" || record.RecordType != "MX" || record.TimeToLive.TotalSeconds != defaultTTL {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	props := record.RecordData.CimInstanceProperties
+	if len(props) != 2 || props[0].Name != "MailExchange" || props[0].Value != "mail.example.com." || props[1].Value != float64(10) {
+		t.Errorf("unexpected record data: %+v", props)
+	}
+
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -MX -MailExchange mail2.example.com -Preference 20")
+	out = mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -Name r1 -RRType MX | ConvertTo-Json")
+	if !strings.HasPrefix(out, "[") {
+		t.Errorf("several records should be a JSON array: %s", out)
+	}
+
+	out = mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -RRType TXT | ConvertTo-Json")
+	if out != "" {
+		t.Errorf("no records should give no output, got %q", out)
+	}
+}
+
+func TestServer_RunErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     string
+		script    string
+		wantInErr []string
+	}{
+		{
+			name:      "record-not-found",
+			script:    "Get-DnsServerResourceRecord -ZoneName example.com -Name missing -RRType A | ConvertTo-Json",
+			wantInErr: []string{"ObjectNotFound", "WIN32 9714,Get-DnsServerResourceRecord"},
+		},
+		{
+			name:      "zone-not-found",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.org -name r1 -A -IPv4Address 203.0.113.11",
+			wantInErr: []string{"ObjectNotFound", "WIN32 9601,Add-DnsServerResourceRecord"},
+		},
+		{
+			name:      "record-exists",
+			setup:     "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11",
+			wantInErr: []string{"ResourceExists", "WIN32 9711"},
+		},
+		{
+			name:      "cname-collision",
+			setup:     "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -CNAME -HostNameAlias cname.example.com",
+			wantInErr: []string{"WIN32 9709"},
+		},
+		{
+			name:      "invalid-ip",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 2001:db8::1",
+			wantInErr: []string{"InvalidArgument"},
+		},
+		{
+			name:      "remove-missing",
+			script:    "Remove-DnsServerResourceRecord -Force -ZoneName example.com -RRType A -Name r1 -RecordData \"203.0.113.11\"",
+			wantInErr: []string{"ObjectNotFound"},
+		},
+		{
+			name:      "unknown-cmdlet",
+			script:    "Get-Something",
+			wantInErr: []string{"CommandNotFoundException"},
+		},
+		{
+			name:      "parse-error",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText \"unterminated",
+			wantInErr: []string{"ParseException"},
+		},
+		{
+			name:      "txt-string-too-long",
+			script:    "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -TXT -DescriptiveText '" + strings.Repeat("a", 256) + "'",
+			wantInErr: []string{"InvalidArgument", "WIN32 87"},
+		},
+		{
+			name:      "delegation-not-found",
+			script:    "Get-DnsServerZoneDelegation -Name example.com -ChildZoneName missing",
+			wantInErr: []string{"ObjectNotFound", "WIN32 9714,Get-DnsServerZoneDelegation"},
+		},
+		{
+			name:      "delegation-invalid-ip",
+			script:    "Add-DnsServerZoneDelegation -Name example.com -ChildZoneName team -NameServer ns1.team.example.com -IPAddress '203.0.113.53', 'ns1'",
+			wantInErr: []string{"InvalidArgument"},
+		},
+		{
+			name:      "zone-exists",
+			script:    "Add-DnsServerPrimaryZone -Name example.com -ReplicationScope Domain",
+			wantInErr: []string{"ResourceExists", "WIN32 9609"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if tt.setup != "" {
+				mustRun(t, s, tt.setup)
+			}
+			result := s.Run(tt.script)
+			if result.ExitCode != 1 {
+				t.Fatalf("exit code = %d, want 1", result.ExitCode)
+			}
+			for _, want := range tt.wantInErr {
+				if !strings.Contains(result.Stderr, want) {
+					t.Errorf("stderr %q does not contain %q", result.Stderr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_RunZones(t *testing.T) {
+	s := NewServer("dc1")
+	mustRun(t, s, "Add-DnsServerPrimaryZone -NetworkId 10.123.0.0/16 -ReplicationScope Domain -ComputerName dc1")
+	mustRun(t, s, "Add-DnsServerPrimaryZone -Name file.example.com -ZoneFile file.example.com.dns")
+	mustRun(t, s, "Set-DnsServerPrimaryZone -Name 123.10.in-addr.arpa -ReplicationScope Forest -DynamicUpdate None")
+
+	var zone struct {
+		ZoneName            string
+		ReplicationScope    string
+		DynamicUpdate       string
+		IsReverseLookupZone bool
+		IsDsIntegrated      bool
+		ZoneFile            string
+	}
+	out := mustRun(t, s, "Get-DnsServerZone -Name 123.10.in-addr.arpa | ConvertTo-Json -Depth 2")
+	if err := json.Unmarshal([]byte(out), &zone); err != nil {
+		t.Fatal(err)
+	}
+	if zone.ReplicationScope != "Forest" || zone.DynamicUpdate != "None" || !zone.IsReverseLookupZone || !zone.IsDsIntegrated {
+		t.Errorf("unexpected zone: %+v", zone)
+	}
+
+	out = mustRun(t, s, "Get-DnsServerZone -Name file.example.com | ConvertTo-Json -Depth 2")
+	if err := json.Unmarshal([]byte(out), &zone); err != nil {
+		t.Fatal(err)
+	}
+	if zone.ZoneFile != "file.example.com.dns" || zone.IsDsIntegrated || zone.ReplicationScope != "None" || zone.DynamicUpdate != "None" {
+		t.Errorf("unexpected zone: %+v", zone)
+	}
+
+	mustRun(t, s, "Remove-DnsServerZone -Force -Name file.example.com")
+	if got := s.Zones(); len(got) != 1 || got[0] != "123.10.in-addr.arpa" {
+		t.Errorf("zones = %q", got)
+	}
+}
+
+func TestServer_RunTryCatch(t *testing.T) {
+	s := newTestServer(t)
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11")
+
+	script := strings.Join([]string{
+		"$ErrorActionPreference = 'Stop'",
+		"@(",
+		"try { Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11 | Out-Null; @{ Index = 0; Error = $null } } catch { @{ Index = 0; Error = $_.Exception.Message; ErrorId = $_.FullyQualifiedErrorId } }",
+		"try { Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.12 | Out-Null; @{ Index = 1; Error = $null } } catch { @{ Index = 1; Error = $_.Exception.Message } }",
+		") | ConvertTo-Json -Compress",
+	}, "\n")
+	out := mustRun(t, s, script)
+
+	want := `[{"Index":0,"Error":"Failed to create resource record r1 in zone example.com on server dc1.","ErrorId":"WIN32 9711,Add-DnsServerResourceRecord"},{"Index":1,"Error":null}]`
+	if strings.TrimSpace(out) != want {
+		t.Errorf("output = %s, want %s", out, want)
+	}
+	if got := recordValues(t, s, "example.com", "r1", "A"); len(got) != 2 {
+		t.Errorf("records = %q, want the second value added", got)
+	}
+
+	result := s.Run("try { Get-Something } catch { Get-SomethingElse }")
+	if result.ExitCode != 1 || !strings.Contains(result.Stderr, "get-somethingelse") {
+		t.Errorf("expected errors in the catch block to fail the script, got %+v", result)
+	}
+}
+
+func TestServer_RunZoneDelegations(t *testing.T) {
+	s := newTestServer(t)
+	mustRun(t, s, "Add-DnsServerZoneDelegation -Name example.com -ChildZoneName team -NameServer ns1.team.example.com -IPAddress '203.0.113.53', '2001:DB8::53'")
+	mustRun(t, s, "Add-DnsServerZoneDelegation -Name example.com -ChildZoneName team.example.com -NameServer ns.example.net.")
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name @ -NS -NameServer dc1.example.com")
+
+	type recordJSON struct {
+		RecordType string
+		RecordData struct {
+			CimInstanceProperties []struct {
+				Name  string
+				Value string
+			}
+		}
+	}
+	var delegations []struct {
+		ChildZoneName string
+		NameServer    recordJSON
+		IPAddress     []recordJSON
+	}
+	out := mustRun(t, s, "Get-DnsServerZoneDelegation -Name example.com -ChildZoneName team | ConvertTo-Json -Depth 6")
+	if err := json.Unmarshal([]byte(out), &delegations); err != nil {
+		t.Fatal(err)
+	}
+	if len(delegations) != 2 {
+		t.Fatalf("got %d delegations, want 2: %s", len(delegations), out)
+	}
+	first := delegations[0]
+	if first.ChildZoneName != "team.example.com" || first.NameServer.RecordData.CimInstanceProperties[0].Value != "ns1.team.example.com." {
+		t.Errorf("unexpected delegation: %+v", first)
+	}
+	if len(first.IPAddress) != 2 || first.IPAddress[0].RecordType != "A" || first.IPAddress[1].RecordType != "AAAA" ||
+		first.IPAddress[1].RecordData.CimInstanceProperties[0].Value != "2001:db8::53" {
+		t.Errorf("unexpected glue records: %+v", first.IPAddress)
+	}
+	if len(delegations[1].IPAddress) != 0 {
+		t.Errorf("unexpected glue records: %+v", delegations[1].IPAddress)
+	}
+
+	// The name servers of the zone itself are not a delegation
+	out = mustRun(t, s, "Get-DnsServerZoneDelegation -Name example.com | ConvertTo-Json -Depth 6")
+	if n := strings.Count(out, "ChildZoneName"); n != 2 {
+		t.Errorf("got %d delegations, want 2: %s", n, out)
+	}
+
+	mustRun(t, s, "Set-DnsServerZoneDelegation -Name example.com -ChildZoneName team -NameServer ns1.team.example.com -IPAddress '203.0.113.54'")
+	out = mustRun(t, s, "Get-DnsServerZoneDelegation -Name example.com -ChildZoneName team | ConvertTo-Json -Depth 6")
+	if !strings.Contains(out, "203.0.113.54") || strings.Contains(out, "203.0.113.53") || strings.Contains(out, "2001:db8::53") {
+		t.Errorf("Set-DnsServerZoneDelegation didn't replace the glue records: %s", out)
+	}
+
+	mustRun(t, s, "Remove-DnsServerZoneDelegation -Force -Name example.com -ChildZoneName team -NameServer ns.example.net")
+	if got := recordValues(t, s, "example.com", "team", "NS"); len(got) != 1 || got[0] != "ns1.team.example.com." {
+		t.Errorf("records = %q after removing a name server", got)
+	}
+	mustRun(t, s, "Remove-DnsServerZoneDelegation -Force -Name example.com -ChildZoneName team")
+	if got := recordValues(t, s, "example.com", "team", "NS"); len(got) != 0 {
+		t.Errorf("records = %q after removing the delegation", got)
+	}
+	if got := recordValues(t, s, "example.com", "@", "NS"); len(got) != 1 {
+		t.Errorf("records = %q, want the zone name server to be kept", got)
+	}
+}
+
+func TestServer_RunSOA(t *testing.T) {
+	s := newTestServer(t)
+	soa := func() string {
+		t.Helper()
+		got := recordValues(t, s, "example.com", "@", "SOA")
+		if len(got) != 1 {
+			t.Fatalf("got %d SOA records, want 1", len(got))
+		}
+		return got[0]
+	}
+	if got := soa(); got != "dc1. hostmaster.example.com. 1 900 600 86400 3600" {
+		t.Errorf("SOA = %q for a new zone", got)
+	}
+
+	// Every change to the zone increments the serial number
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name r1 -A -IPv4Address 203.0.113.11")
+	mustRun(t, s, "Remove-DNSServerResourceRecord -ZoneName example.com -RRType A -Name r1 -Force")
+	if got := soa(); got != "dc1. hostmaster.example.com. 3 900 600 86400 3600" {
+		t.Errorf("SOA = %q after adding and removing a record", got)
+	}
+
+	mustRun(t, s, "$old = Get-DnsServerResourceRecord -ZoneName example.com -RRType Soa -ComputerName dc1; $new = $old.Clone(); "+
+		"$new.RecordData.RefreshInterval = [System.TimeSpan]::FromSeconds(3600); $new.RecordData.ResponsiblePerson = 'dns.example.com'; "+
+		"Set-DnsServerResourceRecord -ZoneName example.com -OldInputObject $old -NewInputObject $new -ComputerName dc1")
+	if got := soa(); got != "dc1. dns.example.com. 4 3600 600 86400 3600" {
+		t.Errorf("SOA = %q after setting it", got)
+	}
+
+	// A higher serial number is kept, while a lower one is ignored
+	mustRun(t, s, "$old = Get-DnsServerResourceRecord -ZoneName example.com -RRType Soa; $new = $old.Clone(); "+
+		"$new.RecordData.SerialNumber = 2024010100; Set-DnsServerResourceRecord -ZoneName example.com -OldInputObject $old -NewInputObject $new")
+	mustRun(t, s, "$old = Get-DnsServerResourceRecord -ZoneName example.com -RRType Soa; $new = $old.Clone(); "+
+		"$new.RecordData.SerialNumber = 10; Set-DnsServerResourceRecord -ZoneName example.com -OldInputObject $old -NewInputObject $new")
+	if got := soa(); got != "dc1. dns.example.com. 2024010101 3600 600 86400 3600" {
+		t.Errorf("SOA = %q after setting the serial number", got)
+	}
+
+	out := mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -RRType Soa | ConvertTo-Json -Depth 4 -Compress")
+	if !strings.Contains(out, `{"Name":"ExpireLimit","Value":"1.00:00:00",`) {
+		t.Errorf("expected the intervals to be written as TimeSpan strings: %s", out)
+	}
+
+	result := s.Run("Remove-DNSServerResourceRecord -ZoneName example.com -RRType Soa -Name @ -Force")
+	if result.ExitCode == 0 {
+		t.Errorf("expected removing the SOA record to fail")
+	}
+}
+
+func TestServer_RunUnknownRecordType(t *testing.T) {
+	s := newTestServer(t)
+	// 0 issue "ca.example.net"
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name @ -Type 257 -RecordData '0005697373756563612E6578616D706C652E6E6574'")
+	mustRun(t, s, "Add-DNSServerResourceRecord -ZoneName example.com -name @ -Type 257 -RecordData '000569737375653B'")
+	if got := recordValues(t, s, "example.com", "@", "CAA"); len(got) != 2 || got[0] != "0005697373756563612e6578616d706c652e6e6574" {
+		t.Errorf("records = %q", got)
+	}
+
+	out := mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -Name @ -Type 257 | ConvertTo-Json -Depth 4 -Compress")
+	if !strings.Contains(out, `"RecordType":"UNKNOWN","Type":257`) || !strings.Contains(out, `{"Name":"Data","Value":"000569737375653b",`) {
+		t.Errorf("unexpected output %s", out)
+	}
+
+	mustRun(t, s, "Get-DnsServerResourceRecord -ZoneName example.com -Name @ -Type 257 | Where-Object { $_.RecordData.Data -eq '000569737375653B' } | "+
+		"Remove-DnsServerResourceRecord -Force -ZoneName example.com")
+	if got := recordValues(t, s, "example.com", "@", "CAA"); len(got) != 1 {
+		t.Errorf("records = %q after removing one", got)
+	}
+
+	for _, script := range []string{
+		"Add-DNSServerResourceRecord -ZoneName example.com -name @ -Type 257 -RecordData 'not hex'",
+		"Add-DNSServerResourceRecord -ZoneName example.com -name @ -Type 1 -RecordData 'cb007101'",
+		"Add-DNSServerResourceRecord -ZoneName example.com -name @ -Type 99 -RecordData '00'",
+	} {
+		if result := s.Run(script); result.ExitCode == 0 {
+			t.Errorf("expected script %q to fail", script)
+		}
+	}
+}
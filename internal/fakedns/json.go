@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The types in this file mirror the objects ConvertTo-Json writes for the CIM objects returned by the DnsServer
+// cmdlets. Only a subset of the properties is included.
+
+type cimPropertyJSON struct {
+	Name            string `json:"Name"`
+	Value           any    `json:"Value"`
+	CimType         int    `json:"CimType"`
+	Flags           string `json:"Flags"`
+	IsValueModified bool   `json:"IsValueModified"`
+}
+
+type cimSystemPropertiesJSON struct {
+	Namespace  string `json:"Namespace"`
+	ServerName string `json:"ServerName"`
+	ClassName  string `json:"ClassName"`
+	Path       any    `json:"Path"`
+}
+
+type recordDataJSON struct {
+	CimClass              string                  `json:"CimClass"`
+	CimInstanceProperties []cimPropertyJSON       `json:"CimInstanceProperties"`
+	CimSystemProperties   cimSystemPropertiesJSON `json:"CimSystemProperties"`
+}
+
+type timeSpanJSON struct {
+	Ticks             int64   `json:"Ticks"`
+	Days              int64   `json:"Days"`
+	Hours             int64   `json:"Hours"`
+	Milliseconds      int64   `json:"Milliseconds"`
+	Minutes           int64   `json:"Minutes"`
+	Seconds           int64   `json:"Seconds"`
+	TotalDays         float64 `json:"TotalDays"`
+	TotalHours        float64 `json:"TotalHours"`
+	TotalMilliseconds float64 `json:"TotalMilliseconds"`
+	TotalMinutes      float64 `json:"TotalMinutes"`
+	TotalSeconds      float64 `json:"TotalSeconds"`
+}
+
+type recordJSON struct {
+	DistinguishedName string         `json:"DistinguishedName"`
+	HostName          string         `json:"HostName"`
+	RecordClass       string         `json:"RecordClass"`
+	RecordData        recordDataJSON `json:"RecordData"`
+	RecordType        string         `json:"RecordType"`
+	Type              int64          `json:"Type"`
+	Timestamp         any            `json:"Timestamp"`
+	TimeToLive        timeSpanJSON   `json:"TimeToLive"`
+	PSComputerName    any            `json:"PSComputerName"`
+}
+
+type zoneJSON struct {
+	DistinguishedName   any    `json:"DistinguishedName"`
+	DynamicUpdate       string `json:"DynamicUpdate"`
+	IsAutoCreated       bool   `json:"IsAutoCreated"`
+	IsDsIntegrated      bool   `json:"IsDsIntegrated"`
+	IsPaused            bool   `json:"IsPaused"`
+	IsReadOnly          bool   `json:"IsReadOnly"`
+	IsReverseLookupZone bool   `json:"IsReverseLookupZone"`
+	IsShutdown          bool   `json:"IsShutdown"`
+	ReplicationScope    string `json:"ReplicationScope"`
+	ZoneFile            any    `json:"ZoneFile"`
+	ZoneName            string `json:"ZoneName"`
+	ZoneType            string `json:"ZoneType"`
+	PSComputerName      any    `json:"PSComputerName"`
+}
+
+type delegationJSON struct {
+	ChildZoneName  string       `json:"ChildZoneName"`
+	IPAddress      []recordJSON `json:"IPAddress"`
+	NameServer     recordJSON   `json:"NameServer"`
+	PSComputerName any          `json:"PSComputerName"`
+}
+
+func newTimeSpanJSON(seconds int64) timeSpanJSON {
+	return timeSpanJSON{
+		Ticks:             seconds * 10_000_000,
+		Days:              seconds / 86400,
+		Hours:             seconds / 3600 % 24,
+		Minutes:           seconds / 60 % 60,
+		Seconds:           seconds % 60,
+		TotalDays:         float64(seconds) / 86400,
+		TotalHours:        float64(seconds) / 3600,
+		TotalMilliseconds: float64(seconds) * 1000,
+		TotalMinutes:      float64(seconds) / 60,
+		TotalSeconds:      float64(seconds),
+	}
+}
+
+func toJSONObject(obj any) any {
+	switch o := obj.(type) {
+	case *Record:
+		def := recordTypes[o.RecordType]
+		recordType := o.RecordType
+		if def.unknown {
+			recordType = "UNKNOWN"
+		}
+		className := "DnsServerResourceRecord" + recordType
+		data := recordDataJSON{
+			CimClass: "root/Microsoft/Windows/DNS:" + className,
+			CimSystemProperties: cimSystemPropertiesJSON{
+				Namespace: "root/Microsoft/Windows/DNS",
+				ClassName: className,
+			},
+		}
+		for _, p := range def.properties {
+			value := o.Data[p.name]
+			// Below the depth of ConvertTo-Json, a TimeSpan is written as its string
+			if t, ok := value.(timeSpan); ok {
+				value = t.String()
+			}
+			data.CimInstanceProperties = append(data.CimInstanceProperties, cimPropertyJSON{
+				Name:    p.name,
+				Value:   value,
+				CimType: p.cimType,
+				Flags:   "Property, NotModified",
+			})
+		}
+		return recordJSON{
+			DistinguishedName: fmt.Sprintf("DC=%s,DC=%s,cn=MicrosoftDNS,DC=DomainDnsZones,DC=example,DC=com", o.HostName, o.zone),
+			HostName:          o.HostName,
+			RecordClass:       "IN",
+			RecordData:        data,
+			RecordType:        recordType,
+			Type:              def.number,
+			TimeToLive:        newTimeSpanJSON(o.TTL),
+		}
+	case *delegation:
+		d := delegationJSON{
+			ChildZoneName: strings.TrimSuffix(fqdn(o.zone, o.nameServer.HostName), "."),
+			NameServer:    toJSONObject(o.nameServer).(recordJSON),
+		}
+		for _, glue := range o.glueRecords() {
+			d.IPAddress = append(d.IPAddress, toJSONObject(glue).(recordJSON))
+		}
+		return d
+	case *Zone:
+		z := zoneJSON{
+			DynamicUpdate:       o.DynamicUpdate,
+			IsDsIntegrated:      o.IsDsIntegrated(),
+			IsReverseLookupZone: o.IsReverse,
+			ReplicationScope:    o.ReplicationScope,
+			ZoneName:            o.Name,
+			ZoneType:            "Primary",
+		}
+		if o.IsDsIntegrated() {
+			z.DistinguishedName = fmt.Sprintf("DC=%s,cn=MicrosoftDNS,DC=DomainDnsZones,DC=example,DC=com", o.Name)
+		} else {
+			z.ReplicationScope = "None"
+			z.ZoneFile = o.ZoneFile
+		}
+		return z
+	case timeSpan:
+		return newTimeSpanJSON(int64(o))
+	default:
+		return obj
+	}
+}
+
+// MarshalJSON writes the entries of the hashtable in the order they were added.
+func (h *hashtable) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range h.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(toJSONObject(h.values[k]))
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// This file holds a small parser for the subset of PowerShell the provider generates: statements separated by `;`,
+// pipelines separated by `|`, try/catch statements and commands with named parameters. Values are bare words, single
+// or double quoted strings, parenthesized expressions, script blocks, array subexpressions and hashtable literals.
+// Parameter values may also be comma separated lists of values, like `-IPAddress '192.0.2.1', '192.0.2.2'`.
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenParen
+	tokenBlock
+	// tokenArray is an array subexpression, @( ... )
+	tokenArray
+	// tokenHashtable is a hashtable literal, @{ ... }
+	tokenHashtable
+	tokenPipe
+	tokenSemicolon
+	// tokenList is a comma separated list of parameter values, held in items
+	tokenList
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	items []token
+}
+
+// tokenize splits a script into tokens. Double quoted strings are expanded using vars.
+func tokenize(script string, vars map[string]any) ([]token, error) {
+	var tokens []token
+	runes := []rune(script)
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case c == '\n' || c == '\r' || c == ';':
+			tokens = append(tokens, token{kind: tokenSemicolon})
+			i++
+		case unicode.IsSpace(c):
+			i++
+		case c == '|':
+			tokens = append(tokens, token{kind: tokenPipe})
+			i++
+		case isSingleQuote(c):
+			value, n, err := readSingleQuoted(runes[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i += n
+		case c == '"':
+			value, n, err := readDoubleQuoted(runes[i:], vars)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i += n
+		case c == '@' && i+1 < len(runes) && (runes[i+1] == '(' || runes[i+1] == '{'):
+			inner, n, err := readBalanced(runes[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenArray
+			if runes[i+1] == '{' {
+				kind = tokenHashtable
+			}
+			tokens = append(tokens, token{kind: kind, value: inner})
+			i += n + 1
+		case c == '(' || c == '{':
+			inner, n, err := readBalanced(runes[i:])
+			if err != nil {
+				return nil, err
+			}
+			kind := tokenParen
+			if c == '{' {
+				kind = tokenBlock
+			}
+			tokens = append(tokens, token{kind: kind, value: inner})
+			i += n
+		default:
+			start := i
+			var word strings.Builder
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("|;{}\"", runes[i]) && !isSingleQuote(runes[i]) {
+				// Parentheses are part of words like [System.TimeSpan]::FromSeconds(300) or $_.Clone()
+				if runes[i] == '(' {
+					inner, n, err := readBalanced(runes[i:])
+					if err != nil {
+						return nil, err
+					}
+					word.WriteString("(" + inner + ")")
+					i += n
+					continue
+				}
+				if runes[i] == '`' && i+1 < len(runes) {
+					i++
+				}
+				word.WriteRune(runes[i])
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: word.String()})
+		}
+	}
+	return tokens, nil
+}
+
+// readSingleQuoted reads a verbatim string. Like PowerShell, the typographic single quotes are quotes too, and a
+// quote is escaped by another quote.
+func readSingleQuoted(runes []rune) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(runes); i++ {
+		if isSingleQuote(runes[i]) {
+			if i+1 < len(runes) && isSingleQuote(runes[i+1]) {
+				b.WriteRune(runes[i+1])
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		b.WriteRune(runes[i])
+	}
+	return "", 0, fmt.Errorf("missing terminator: '")
+}
+
+var backtickEscapes = map[rune]rune{
+	'0': 0, 'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+}
+
+// readDoubleQuoted reads an expandable string. Like PowerShell, variables are expanded and undefined variables
+// become empty, while backtick escapes the next character.
+func readDoubleQuoted(runes []rune, vars map[string]any) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '`' && i+1 < len(runes):
+			i++
+			if escaped, ok := backtickEscapes[runes[i]]; ok {
+				b.WriteRune(escaped)
+			} else {
+				b.WriteRune(runes[i])
+			}
+		case c == '"':
+			if i+1 < len(runes) && runes[i+1] == '"' {
+				b.WriteRune('"')
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '(':
+			return "", 0, fmt.Errorf("subexpressions are not supported in expandable strings")
+		case c == '$' && i+1 < len(runes) && isVariableStart(runes[i+1]):
+			j := i + 1
+			for j < len(runes) && isVariableChar(runes[j]) {
+				j++
+			}
+			if value, ok := vars[strings.ToLower(string(runes[i+1:j]))]; ok && value != nil {
+				b.WriteString(fmt.Sprint(value))
+			}
+			i = j - 1
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return "", 0, fmt.Errorf("missing terminator: \"")
+}
+
+func isSingleQuote(c rune) bool {
+	switch c {
+	case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+		return true
+	}
+	return false
+}
+
+func isVariableStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func isVariableChar(c rune) bool {
+	return c == '_' || c == ':' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// readBalanced reads a parenthesized expression or script block, and returns the text inside the brackets.
+func readBalanced(runes []rune) (string, int, error) {
+	open := runes[0]
+	closing := ')'
+	if open == '{' {
+		closing = '}'
+	}
+
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case isSingleQuote(runes[i]):
+			_, n, err := readSingleQuoted(runes[i:])
+			if err != nil {
+				return "", 0, err
+			}
+			i += n - 1
+		case runes[i] == '"':
+			_, n, err := readDoubleQuoted(runes[i:], nil)
+			if err != nil {
+				return "", 0, err
+			}
+			i += n - 1
+		case runes[i] == '`':
+			i++
+		case runes[i] == open:
+			depth++
+		case runes[i] == closing:
+			depth--
+			if depth == 0 {
+				return string(runes[1:i]), i + 1, nil
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("missing closing %q", closing)
+}
+
+// command is one command in a pipeline, with its parameters. Switch parameters have a nil value.
+// A pipeline may start with an expression instead, like `$records | ConvertTo-Json`.
+type command struct {
+	expr   *token
+	name   string
+	params map[string]*token
+	args   []token
+	// piped is set for commands getting their input from the pipeline
+	piped bool
+}
+
+func (c *command) has(name string) bool {
+	_, ok := c.params[strings.ToLower(name)]
+	return ok
+}
+
+func (c *command) param(name string) *token {
+	return c.params[strings.ToLower(name)]
+}
+
+type statement struct {
+	// assignment target for statements like `$x = ...` or `$x.Prop = ...`, empty for pipelines
+	target   string
+	pipeline []*command
+	tokens   []token
+	// the script blocks of a try/catch statement
+	tryBlock   string
+	catchBlock string
+}
+
+// parse splits tokens into statements, and statements into pipelines of commands.
+func parse(tokens []token) ([]*statement, error) {
+	var statements []*statement
+	var current []token
+
+	flush := func() error {
+		if len(current) == 0 {
+			return nil
+		}
+		stmt, err := parseStatement(current)
+		if err != nil {
+			return err
+		}
+		statements = append(statements, stmt)
+		current = nil
+		return nil
+	}
+
+	for _, t := range tokens {
+		if t.kind == tokenSemicolon {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		current = append(current, t)
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func parseStatement(tokens []token) (*statement, error) {
+	if tokens[0].kind == tokenWord && strings.EqualFold(tokens[0].value, "try") {
+		if len(tokens) != 4 || tokens[1].kind != tokenBlock || tokens[2].kind != tokenWord ||
+			!strings.EqualFold(tokens[2].value, "catch") || tokens[3].kind != tokenBlock {
+			return nil, fmt.Errorf("try must be followed by a script block and a catch block")
+		}
+		return &statement{tryBlock: tokens[1].value, catchBlock: tokens[3].value}, nil
+	}
+	if len(tokens) >= 3 && tokens[0].kind == tokenWord && strings.HasPrefix(tokens[0].value, "$") &&
+		tokens[1].kind == tokenWord && tokens[1].value == "=" {
+		return &statement{target: tokens[0].value, tokens: tokens[2:]}, nil
+	}
+
+	var pipeline []*command
+	var current []token
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].kind != tokenPipe {
+			current = append(current, tokens[i])
+			continue
+		}
+		if len(current) == 0 {
+			return nil, fmt.Errorf("empty pipe element")
+		}
+		cmd, err := parseCommand(current)
+		if err != nil {
+			return nil, err
+		}
+		pipeline = append(pipeline, cmd)
+		current = nil
+	}
+	return &statement{pipeline: pipeline}, nil
+}
+
+func parseCommand(tokens []token) (*command, error) {
+	if tokens[0].kind != tokenWord || strings.HasPrefix(tokens[0].value, "$") {
+		if len(tokens) != 1 {
+			return nil, fmt.Errorf("unexpected token %q after expression", tokens[1].value)
+		}
+		return &command{expr: &tokens[0]}, nil
+	}
+
+	cmd := &command{
+		name:   strings.ToLower(tokens[0].value),
+		params: make(map[string]*token),
+	}
+	for i := 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if isParameterName(t) {
+			name := strings.ToLower(strings.TrimPrefix(t.value, "-"))
+			if i+1 < len(tokens) && !isParameterName(tokens[i+1]) {
+				value := tokens[i+1]
+				i++
+				if i+2 < len(tokens) && isComma(tokens[i+1]) {
+					value = token{kind: tokenList, items: []token{value}}
+					for i+2 < len(tokens) && isComma(tokens[i+1]) {
+						value.items = append(value.items, tokens[i+2])
+						i += 2
+					}
+				}
+				cmd.params[name] = &value
+			} else {
+				cmd.params[name] = nil
+			}
+			continue
+		}
+		cmd.args = append(cmd.args, t)
+	}
+	return cmd, nil
+}
+
+func isComma(t token) bool {
+	return t.kind == tokenWord && t.value == ","
+}
+
+func isParameterName(t token) bool {
+	return t.kind == tokenWord && len(t.value) > 1 && t.value[0] == '-' && unicode.IsLetter(rune(t.value[1]))
+}
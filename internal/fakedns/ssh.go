@@ -0,0 +1,351 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHServer serves a fake DNS server over SSH, like a Windows server with OpenSSH and the DnsServer module.
+// Commands must be on the form `powershell.exe -EncodedCommand <base64>`, as sent by the provider. The persistent
+// session host of the provider is emulated, running the scripts read from stdin.
+// The server also forwards TCP connections, so it can be used as a bastion host.
+type SSHServer struct {
+	DNS *Server
+
+	config         *ssh.ServerConfig
+	hostKey        ssh.Signer
+	listener       net.Listener
+	authorizedKeys [][]byte
+
+	mx       sync.Mutex
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	commands int
+}
+
+// NewSSHServer returns an SSH server for the DNS server, accepting the username and password.
+// The server has a new ed25519 host key.
+func NewSSHServer(dns *Server, username, password string) (*SSHServer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SSHServer{
+		DNS:     dns,
+		hostKey: hostKey,
+		conns:   make(map[net.Conn]struct{}),
+	}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if conn.User() == username && password != "" && string(pass) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mx.Lock()
+			defer s.mx.Unlock()
+			for _, k := range s.authorizedKeys {
+				if conn.User() == username && bytes.Equal(k, key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %s", conn.User())
+		},
+	}
+	s.config.AddHostKey(hostKey)
+	return s, nil
+}
+
+// AddAuthorizedKey allows the user to log in with the public key.
+func (s *SSHServer) AddAuthorizedKey(key ssh.PublicKey) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.authorizedKeys = append(s.authorizedKeys, key.Marshal())
+}
+
+// HostKey returns the public host key of the server.
+func (s *SSHServer) HostKey() ssh.PublicKey {
+	return s.hostKey.PublicKey()
+}
+
+// Start starts listening on a random port on localhost.
+func (s *SSHServer) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mx.Lock()
+			s.conns[conn] = struct{}{}
+			s.mx.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.handleConn(conn)
+				s.mx.Lock()
+				delete(s.conns, conn)
+				s.mx.Unlock()
+			}()
+		}
+	}()
+	return nil
+}
+
+// Commands returns the number of commands run on the server, counting a persistent session as one command.
+func (s *SSHServer) Commands() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.commands
+}
+
+// Connections returns the number of open connections.
+func (s *SSHServer) Connections() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len(s.conns)
+}
+
+// CloseConnections closes the open connections, like a server restart or a firewall dropping them, while new
+// connections are still accepted.
+func (s *SSHServer) CloseConnections() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Host returns the address the server listens on.
+func (s *SSHServer) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens on.
+func (s *SSHServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the server and closes all connections.
+func (s *SSHServer) Close() error {
+	err := s.listener.Close()
+	s.mx.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mx.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *SSHServer) handleConn(conn net.Conn) {
+	defer conn.Close()
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(newChannel)
+		case "direct-tcpip":
+			go handleDirectTCPIP(newChannel)
+		default:
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+		}
+	}
+}
+
+func (s *SSHServer) handleSession(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range requests {
+		switch req.Type {
+		case "env", "pty-req":
+			_ = req.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			s.mx.Lock()
+			s.commands++
+			s.mx.Unlock()
+
+			if script, err := decodeCommandLine(payload.Command); err == nil && isSessionHost(script) {
+				serveSession(s.DNS, channel)
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+			result := runCommand(s.DNS, payload.Command)
+			_, _ = io.WriteString(channel, result.Stdout)
+			_, _ = io.WriteString(channel.Stderr(), result.Stderr)
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(result.ExitCode)}))
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// maxCommandLineLength is the longest command line cmd.exe runs, which OpenSSH on Windows runs commands with
+const maxCommandLineLength = 8191
+
+// runCommand runs a command line on the form `powershell.exe [-NoProfile] [-NonInteractive] -EncodedCommand <base64>`
+// on the DNS server. Like cmd.exe, it fails for command lines longer than maxCommandLineLength.
+func runCommand(dns *Server, command string) *Result {
+	if len(command) > maxCommandLineLength {
+		return &Result{Stderr: "The command line is too long.\r\n", ExitCode: 1}
+	}
+	script, err := decodeCommandLine(command)
+	if err != nil {
+		return &Result{Stderr: fmt.Sprintf("fakedns: %s\n", err), ExitCode: 1}
+	}
+	return dns.Run(script)
+}
+
+// decodeCommandLine returns the script of a powershell.exe command line.
+func decodeCommandLine(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) < 3 || !strings.EqualFold(fields[0], "powershell.exe") || !strings.EqualFold(fields[len(fields)-2], "-EncodedCommand") {
+		return "", fmt.Errorf("unsupported command: %s", command)
+	}
+	for _, flag := range fields[1 : len(fields)-2] {
+		if !strings.EqualFold(flag, "-NoProfile") && !strings.EqualFold(flag, "-NonInteractive") {
+			return "", fmt.Errorf("unsupported command: %s", command)
+		}
+	}
+	script, err := decodeCommand(fields[len(fields)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid encoded command: %s", err)
+	}
+	return script, nil
+}
+
+// decodeCommand decodes the argument of -EncodedCommand, which is base64 encoded UTF-16LE.
+func decodeCommand(encoded string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(raw)%2 != 0 {
+		return "", fmt.Errorf("odd number of bytes in UTF-16 string")
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(raw[2*i:])
+	}
+	return string(utf16.Decode(units)), nil
+}
+
+// isSessionHost returns true for scripts reading scripts to run from stdin, like the provider's persistent session.
+func isSessionHost(script string) bool {
+	return strings.Contains(script, "[Console]::In.ReadLine()")
+}
+
+// serveSession emulates the persistent session of the provider: each line read is a base64 encoded UTF-8 script,
+// and the result is written as a JSON line prefixed with #windns-result.
+func serveSession(dns *Server, channel ssh.Channel) {
+	scanner := bufio.NewScanner(channel)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result *Result
+		script, err := base64.StdEncoding.DecodeString(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			result = &Result{Stderr: fmt.Sprintf("fakedns: invalid script: %s\n", err), ExitCode: 1}
+		} else {
+			result = dns.Run(string(script))
+		}
+
+		out, err := json.Marshal(map[string]any{
+			"Stdout":   result.Stdout,
+			"Stderr":   result.Stderr,
+			"ExitCode": result.ExitCode,
+		})
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(channel, "#windns-result %s\r\n", out); err != nil {
+			return
+		}
+	}
+}
+
+// handleDirectTCPIP forwards a connection, like `ssh -J` uses the bastion host.
+func handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(target, channel)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(channel, target)
+		done <- struct{}{}
+	}()
+	<-done
+	channel.Close()
+	target.Close()
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/exp/slices"
+)
+
+func startSSHServer(t *testing.T) *fakedns.SSHServer {
+	t.Helper()
+	dns := fakedns.NewServer("dc1")
+	if err := dns.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	server, err := fakedns.NewSSHServer(dns, "tester", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestSSHServer_RecordLifecycle(t *testing.T) {
+	server := startSSHServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: server.Host(),
+		SshPort:     server.Port(),
+		SshHostKey:  ssh.FingerprintSHA256(server.HostKey()),
+		DnsServer:   "dc1",
+	})
+	ctx := context.Background()
+
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeMX,
+		Records:    []string{"10 mail1.example.com", "20 mail2.example.com"},
+		TTL:        300,
+	}
+	id, err := record.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10 mail1.example.com.", "20 mail2.example.com."}; !slices.Equal(got.Records, want) || got.TTL != 300 {
+		t.Errorf("got records %q with TTL %d, want %q with TTL 300", got.Records, got.TTL, want)
+	}
+
+	err = record.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if !errors.Is(err, dnshelper.ErrNotFound) {
+		t.Errorf("expected ObjectNotFound after delete, got %v", err)
+	}
+}
+
+func TestSSHServer_CommandLineLength(t *testing.T) {
+	server := startSSHServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: server.Host(),
+		SshPort:     server.Port(),
+		SshHostKey:  ssh.FingerprintSHA256(server.HostKey()),
+		DnsServer:   "dc1",
+	})
+	ctx := context.Background()
+
+	script := "Get-DnsServerZone # " + strings.Repeat("x", config.MaxCommandLineLength)
+	result, err := conf.Executor.Execute(ctx, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 1 || !strings.Contains(result.StdErr, "The command line is too long") {
+		t.Errorf("got exit code %d and stderr %q for a long command line", result.ExitCode, result.StdErr)
+	}
+
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeA,
+		TTL:        300,
+	}
+	for i := 1; i <= 100; i++ {
+		record.Records = append(record.Records, fmt.Sprintf("203.0.113.%d", i))
+	}
+	id, err := record.Create(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Records) != len(record.Records) {
+		t.Errorf("got %d records, want %d", len(got.Records), len(record.Records))
+	}
+}
+
+func TestSSHServer_PersistentSession(t *testing.T) {
+	server := startSSHServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		SshUsername:          "tester",
+		SshPassword:          "secret",
+		SshHostname:          server.Host(),
+		SshPort:              server.Port(),
+		SshHostKey:           ssh.FingerprintSHA256(server.HostKey()),
+		SshPersistentSession: true,
+	})
+	ctx := context.Background()
+
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeTXT,
+		Records:    []string{"first line", "second"},
+	}
+	id, err := record.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first line", "second"}; !slices.Equal(got.Records, want) {
+		t.Errorf("got records %q, want %q", got.Records, want)
+	}
+
+	_, err = dnshelper.GetDNSRecordFromId(ctx, conf, "missing_example.com_A")
+	if !errors.Is(err, dnshelper.ErrNotFound) {
+		t.Errorf("expected ObjectNotFound for a missing record, got %v", err)
+	}
+
+	if n := server.Commands(); n != 1 {
+		t.Errorf("ran %d commands, want all scripts in one session", n)
+	}
+}
+
+func TestSSHServer_Bastion(t *testing.T) {
+	bastion := startSSHServer(t)
+	target := startSSHServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		SshUsername: "tester",
+		SshPassword: "secret",
+		SshHostname: target.Host(),
+		SshPort:     target.Port(),
+		SshHostKey:  ssh.FingerprintSHA256(target.HostKey()),
+		Bastion: &config.BastionSettings{
+			Hostname: bastion.Host(),
+			Port:     bastion.Port(),
+			Username: "tester",
+			Password: "secret",
+			HostKey:  ssh.FingerprintSHA256(bastion.HostKey()),
+		},
+	})
+
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeA,
+		Records:    []string{"203.0.113.11"},
+	}
+	if _, err := record.Create(context.Background(), conf); err != nil {
+		t.Fatal(err)
+	}
+	if zone := target.DNS.Zone("example.com"); len(zone.Records) != 1 {
+		t.Errorf("expected the record to be created on the target, got %d records", len(zone.Records))
+	}
+	if zone := bastion.DNS.Zone("example.com"); len(zone.Records) != 0 {
+		t.Errorf("expected no records on the bastion, got %d records", len(zone.Records))
+	}
+}
+
+func TestSSHServer_WrongPassword(t *testing.T) {
+	server := startSSHServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		SshUsername: "tester",
+		SshPassword: "wrong",
+		SshHostname: server.Host(),
+		SshPort:     server.Port(),
+		SshHostKey:  ssh.FingerprintSHA256(server.HostKey()),
+	})
+
+	_, err := conf.Executor.Execute(context.Background(), "Get-DnsServerZone")
+	if err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestSSHServer_ContextTimeout(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("persistent-session-%t", persistent), func(t *testing.T) {
+			server := startSSHServer(t)
+			server.DNS.SetLatency(time.Minute)
+			conf := config.NewProviderConf(&config.Settings{
+				SshUsername:          "tester",
+				SshPassword:          "secret",
+				SshHostname:          server.Host(),
+				SshPort:              server.Port(),
+				SshHostKey:           ssh.FingerprintSHA256(server.HostKey()),
+				SshPersistentSession: persistent,
+				DnsServer:            "dc1",
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			record := &dnshelper.Record{
+				ZoneName:   "example.com",
+				HostName:   "r1",
+				RecordType: dnshelper.RecordTypeA,
+				Records:    []string{"203.0.113.11"},
+			}
+			_, err := record.Create(ctx, conf)
+			if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+				t.Errorf("Create() error = %v, want a deadline exceeded error", err)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("Create() returned after %s", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTTL = 3600
+
+// CIM types of record data properties, as serialized by ConvertTo-Json. TimeSpan properties are DateTime intervals.
+const (
+	cimTypeUInt16   = 4
+	cimTypeUInt32   = 6
+	cimTypeDateTime = 13
+	cimTypeString   = 14
+)
+
+// propertyDef describes one property of the record data of a record type, and the Add-DnsServerResourceRecord
+// parameter setting it. Properties are listed in the order the DNS server returns them.
+type propertyDef struct {
+	name    string
+	param   string
+	cimType int
+	// fqdn properties are domain names, which the DNS server stores with a trailing dot
+	fqdn bool
+}
+
+type recordTypeDef struct {
+	// switchParam is the Add-DnsServerResourceRecord switch selecting the record type, e.g. -A or -CName. It is
+	// empty for the SOA record, which is created with the zone, and for unknown record types.
+	switchParam string
+	// number is the number of the record type, which -Type selects it by
+	number     int64
+	properties []propertyDef
+	// unknown record types are added with -Type and -RecordData in hexadecimal, and returned with the UNKNOWN
+	// record type, like by DNS servers without support for the type
+	unknown bool
+}
+
+var recordTypes = map[string]recordTypeDef{
+	"A": {
+		switchParam: "a",
+		number:      1,
+		properties:  []propertyDef{{name: "IPv4Address", param: "ipv4address", cimType: cimTypeString}},
+	},
+	"AAAA": {
+		switchParam: "aaaa",
+		number:      28,
+		properties:  []propertyDef{{name: "IPv6Address", param: "ipv6address", cimType: cimTypeString}},
+	},
+	"CNAME": {
+		switchParam: "cname",
+		number:      5,
+		properties:  []propertyDef{{name: "HostNameAlias", param: "hostnamealias", cimType: cimTypeString, fqdn: true}},
+	},
+	"PTR": {
+		switchParam: "ptr",
+		number:      12,
+		properties:  []propertyDef{{name: "PtrDomainName", param: "ptrdomainname", cimType: cimTypeString, fqdn: true}},
+	},
+	"NS": {
+		switchParam: "ns",
+		number:      2,
+		properties:  []propertyDef{{name: "NameServer", param: "nameserver", cimType: cimTypeString, fqdn: true}},
+	},
+	"TXT": {
+		switchParam: "txt",
+		number:      16,
+		properties:  []propertyDef{{name: "DescriptiveText", param: "descriptivetext", cimType: cimTypeString}},
+	},
+	"MX": {
+		switchParam: "mx",
+		number:      15,
+		properties: []propertyDef{
+			{name: "MailExchange", param: "mailexchange", cimType: cimTypeString, fqdn: true},
+			{name: "Preference", param: "preference", cimType: cimTypeUInt16},
+		},
+	},
+	"SRV": {
+		switchParam: "srv",
+		number:      33,
+		properties: []propertyDef{
+			{name: "DomainName", param: "domainname", cimType: cimTypeString, fqdn: true},
+			{name: "Port", param: "port", cimType: cimTypeUInt16},
+			{name: "Priority", param: "priority", cimType: cimTypeUInt16},
+			{name: "Weight", param: "weight", cimType: cimTypeUInt16},
+		},
+	},
+	"SOA": {
+		number: 6,
+		properties: []propertyDef{
+			{name: "ExpireLimit", cimType: cimTypeDateTime},
+			{name: "MinimumTimeToLive", cimType: cimTypeDateTime},
+			{name: "PrimaryServer", cimType: cimTypeString, fqdn: true},
+			{name: "RefreshInterval", cimType: cimTypeDateTime},
+			{name: "ResponsiblePerson", cimType: cimTypeString, fqdn: true},
+			{name: "RetryDelay", cimType: cimTypeDateTime},
+			{name: "SerialNumber", cimType: cimTypeUInt32},
+		},
+	},
+	"CAA": {
+		number:     257,
+		properties: []propertyDef{{name: "Data", param: "recorddata", cimType: cimTypeString}},
+		unknown:    true,
+	},
+}
+
+// Record is a resource record stored in the fake DNS server. Values are strings for string properties, int64 for
+// numeric properties and timeSpan for TimeSpan properties.
+type Record struct {
+	HostName   string
+	RecordType string
+	Data       map[string]any
+	TTL        int64
+
+	zone string
+	// glue holds the IP addresses of the name server of an NS record added by Add-DnsServerZoneDelegation
+	glue []string
+}
+
+// Clone returns a copy of the record, like the Clone() method of the CIM record objects.
+func (r *Record) Clone() *Record {
+	data := make(map[string]any, len(r.Data))
+	for k, v := range r.Data {
+		data[k] = v
+	}
+	glue := append([]string(nil), r.glue...)
+	return &Record{HostName: r.HostName, RecordType: r.RecordType, Data: data, TTL: r.TTL, zone: r.zone, glue: glue}
+}
+
+// Value returns the record data on the format used in the records list of windns_record.
+func (r *Record) Value() string {
+	def := recordTypes[r.RecordType]
+	switch r.RecordType {
+	case "MX":
+		return fmt.Sprintf("%d %s", r.Data["Preference"], r.Data["MailExchange"])
+	case "SRV":
+		return fmt.Sprintf("%d %d %d %s", r.Data["Priority"], r.Data["Weight"], r.Data["Port"], r.Data["DomainName"])
+	case "SOA":
+		return fmt.Sprintf("%s %s %d %d %d %d %d", r.Data["PrimaryServer"], r.Data["ResponsiblePerson"], r.Data["SerialNumber"],
+			r.Data["RefreshInterval"], r.Data["RetryDelay"], r.Data["ExpireLimit"], r.Data["MinimumTimeToLive"])
+	default:
+		return fmt.Sprint(r.Data[def.properties[0].name])
+	}
+}
+
+// sameData reports whether two records of the same type have the same record data. Domain names are compared
+// case insensitively, like the DNS server does.
+func (r *Record) sameData(other *Record) bool {
+	if r.RecordType != other.RecordType {
+		return false
+	}
+	for _, p := range recordTypes[r.RecordType].properties {
+		a, b := r.Data[p.name], other.Data[p.name]
+		if p.fqdn {
+			if !strings.EqualFold(fmt.Sprint(a), fmt.Sprint(b)) {
+				return false
+			}
+		} else if a != b {
+			return false
+		}
+	}
+	return true
+}
+
+// Zone is a primary zone in the fake DNS server.
+type Zone struct {
+	Name             string
+	ReplicationScope string
+	ZoneFile         string
+	DynamicUpdate    string
+	IsReverse        bool
+	// SOA is the start of authority record, which every zone has. It is not in Records, since it can't be removed.
+	SOA     *Record
+	Records []*Record
+}
+
+// IsDsIntegrated reports whether the zone is stored in Active Directory rather than in a zone file.
+func (z *Zone) IsDsIntegrated() bool {
+	return z.ZoneFile == ""
+}
+
+// Server is an in-memory Windows DNS server. It interprets the subset of the DnsServer PowerShell module the
+// provider uses, see Run.
+type Server struct {
+	mx      sync.Mutex
+	name    string
+	zones   map[string]*Zone
+	latency time.Duration
+}
+
+// NewServer returns an empty DNS server. The name is used in error messages when no -ComputerName is given.
+func NewServer(name string) *Server {
+	return &Server{
+		name:  name,
+		zones: make(map[string]*Zone),
+	}
+}
+
+// SetLatency makes every script wait before it is run, like a slow domain controller.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.latency = latency
+}
+
+// AddZone adds an Active Directory integrated primary zone, like a zone created outside of Terraform.
+func (s *Server) AddZone(name string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	_, err := s.addZone(&Zone{Name: name, ReplicationScope: "Domain", DynamicUpdate: "Secure"})
+	return err
+}
+
+// Zone returns a copy of a zone and its records, or nil if the zone doesn't exist.
+func (s *Server) Zone(name string) *Zone {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	zone := s.zones[strings.ToLower(strings.TrimSuffix(name, "."))]
+	if zone == nil {
+		return nil
+	}
+	c := *zone
+	c.SOA = zone.SOA.Clone()
+	c.Records = nil
+	for _, r := range zone.Records {
+		c.Records = append(c.Records, r.Clone())
+	}
+	return &c
+}
+
+// Zones returns the names of all zones, sorted.
+func (s *Server) Zones() []string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var names []string
+	for _, z := range s.zones {
+		names = append(names, z.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) addZone(zone *Zone) (*Zone, error) {
+	key := strings.ToLower(zone.Name)
+	if _, ok := s.zones[key]; ok {
+		return nil, &psError{
+			message:  fmt.Sprintf("The zone %s already exists on server %s.", zone.Name, s.name),
+			category: "ResourceExists",
+			target:   zone.Name,
+			errorId:  "WIN32 9609",
+		}
+	}
+	zone.IsReverse = strings.HasSuffix(key, ".in-addr.arpa") || strings.HasSuffix(key, ".ip6.arpa")
+	// The defaults of the DNS server for new zones
+	zone.SOA = &Record{
+		HostName:   "@",
+		RecordType: "SOA",
+		Data: map[string]any{
+			"ExpireLimit":       timeSpan(86400),
+			"MinimumTimeToLive": timeSpan(3600),
+			"PrimaryServer":     s.name + ".",
+			"RefreshInterval":   timeSpan(900),
+			"ResponsiblePerson": "hostmaster." + zone.Name + ".",
+			"RetryDelay":        timeSpan(600),
+			"SerialNumber":      int64(1),
+		},
+		TTL:  defaultTTL,
+		zone: zone.Name,
+	}
+	s.zones[key] = zone
+	return zone, nil
+}
+
+func (s *Server) getZone(name string) (*Zone, error) {
+	zone, ok := s.zones[strings.ToLower(strings.TrimSuffix(name, "."))]
+	if !ok {
+		return nil, &psError{
+			message:  fmt.Sprintf("The zone %s was not found on server %s.", name, s.name),
+			category: "ObjectNotFound",
+			target:   name,
+			errorId:  "WIN32 9601",
+		}
+	}
+	return zone, nil
+}
+
+// relativeName returns the name of a record relative to the zone, with "@" for the zone apex.
+func relativeName(zone *Zone, name string) string {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || name == "@" || strings.EqualFold(name, zone.Name) {
+		return "@"
+	}
+	if suffix := "." + zone.Name; len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name[:len(name)-len(suffix)]
+	}
+	return name
+}
+
+// fqdn returns the fully qualified name of a record in the zone, with a trailing dot.
+func fqdn(zone *Zone, hostName string) string {
+	if hostName == "@" {
+		return zone.Name + "."
+	}
+	return hostName + "." + zone.Name + "."
+}
+
+// findRecords returns the records with the name and type, including the SOA record. An empty name or type matches
+// all records.
+func (z *Zone) findRecords(hostName, recordType string) []*Record {
+	var found []*Record
+	for _, r := range append([]*Record{z.SOA}, z.Records...) {
+		if hostName != "" && !strings.EqualFold(r.HostName, hostName) {
+			continue
+		}
+		if recordType != "" && r.RecordType != recordType {
+			continue
+		}
+		found = append(found, r)
+	}
+	return found
+}
+
+func (z *Zone) addRecord(server string, record *Record) error {
+	for _, r := range z.findRecords(record.HostName, "") {
+		conflict := r.RecordType == "CNAME" || record.RecordType == "CNAME"
+		if r.RecordType == record.RecordType && r.sameData(record) {
+			return &psError{
+				message:  fmt.Sprintf("Failed to create resource record %s in zone %s on server %s.", record.HostName, z.Name, server),
+				category: "ResourceExists",
+				target:   record.HostName,
+				errorId:  "WIN32 9711",
+			}
+		}
+		if conflict {
+			return &psError{
+				message:  fmt.Sprintf("Failed to create resource record %s in zone %s on server %s. A CNAME record can't coexist with other records.", record.HostName, z.Name, server),
+				category: "InvalidArgument",
+				target:   record.HostName,
+				errorId:  "WIN32 9709",
+			}
+		}
+	}
+	record.zone = z.Name
+	z.Records = append(z.Records, record)
+	z.incrementSerial()
+	return nil
+}
+
+func (z *Zone) removeRecord(record *Record) bool {
+	for i, r := range z.Records {
+		if r == record {
+			z.Records = append(z.Records[:i:i], z.Records[i+1:]...)
+			z.incrementSerial()
+			return true
+		}
+	}
+	return false
+}
+
+// incrementSerial increments the serial number of the zone, like the DNS server does for every change to the zone.
+func (z *Zone) incrementSerial() {
+	z.SOA.Data["SerialNumber"] = z.SOA.Data["SerialNumber"].(int64) + 1
+}
+
+// reverseName returns the fully qualified reverse lookup name of an IP address, without a trailing dot.
+func reverseName(ip net.IP) string {
+	var labels []string
+	if ip4 := ip.To4(); ip4 != nil {
+		for i := len(ip4) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(ip4[i])))
+		}
+		return strings.Join(append(labels, "in-addr", "arpa"), ".")
+	}
+	nibbles := fmt.Sprintf("%x", []byte(ip.To16()))
+	for i := len(nibbles) - 1; i >= 0; i-- {
+		labels = append(labels, string(nibbles[i]))
+	}
+	return strings.Join(append(labels, "ip6", "arpa"), ".")
+}
+
+// reverseZoneFromNetworkId returns the name of the reverse lookup zone for a network ID like 10.1.0.0/16.
+func reverseZoneFromNetworkId(networkId string) (string, error) {
+	ip, ipNet, err := net.ParseCIDR(networkId)
+	if err != nil {
+		return "", err
+	}
+	ones, _ := ipNet.Mask.Size()
+	labels := strings.Split(reverseName(ip), ".")
+	if ip.To4() != nil {
+		if ones%8 != 0 || ones == 0 || ones > 24 {
+			return "", fmt.Errorf("invalid network ID %s", networkId)
+		}
+		return strings.Join(labels[4-ones/8:], "."), nil
+	}
+	if ones%4 != 0 || ones == 0 || ones > 124 {
+		return "", fmt.Errorf("invalid network ID %s", networkId)
+	}
+	return strings.Join(labels[32-ones/4:], "."), nil
+}
+
+// reverseZoneFor returns the most specific reverse lookup zone containing the IP address.
+func (s *Server) reverseZoneFor(ip net.IP) (*Zone, string) {
+	name := reverseName(ip)
+	var best *Zone
+	for _, z := range s.zones {
+		if !z.IsReverse || !strings.HasSuffix(name, "."+strings.ToLower(z.Name)) {
+			continue
+		}
+		if best == nil || len(z.Name) > len(best.Name) {
+			best = z
+		}
+	}
+	if best == nil {
+		return nil, ""
+	}
+	return best, relativeName(best, name)
+}
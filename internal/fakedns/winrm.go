@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	actionCreate  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Create"
+	actionDelete  = "http://schemas.xmlsoap.org/ws/2004/09/transfer/Delete"
+	actionCommand = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Command"
+	actionReceive = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Receive"
+	actionSignal  = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/Signal"
+
+	commandStateDone = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/CommandState/Done"
+)
+
+// WinRMServer serves a fake DNS server over WinRM, like a Windows server with WinRM and the DnsServer module.
+// It supports the shell operations used to run a command with the winrm client, over HTTP with basic
+// authentication. Like powershell.exe run over WinRM, errors are written to stderr as CLIXML.
+type WinRMServer struct {
+	DNS *Server
+
+	username string
+	password string
+	server   *http.Server
+	listener net.Listener
+
+	mx       sync.Mutex
+	nextId   int
+	commands map[string]*Result
+}
+
+// NewWinRMServer returns a WinRM server for the DNS server, accepting the username and password.
+func NewWinRMServer(dns *Server, username, password string) *WinRMServer {
+	s := &WinRMServer{
+		DNS:      dns,
+		username: username,
+		password: password,
+		commands: make(map[string]*Result),
+	}
+	s.server = &http.Server{Handler: s}
+	return s
+}
+
+// Start starts listening on a random port on localhost.
+func (s *WinRMServer) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() { _ = s.server.Serve(listener) }()
+	return nil
+}
+
+// Host returns the address the server listens on.
+func (s *WinRMServer) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens on.
+func (s *WinRMServer) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the server and closes all connections.
+func (s *WinRMServer) Close() error {
+	return s.server.Close()
+}
+
+type soapEnvelope struct {
+	Header struct {
+		Action string `xml:"Action"`
+	} `xml:"Header"`
+	Body struct {
+		CommandLine struct {
+			Command   string   `xml:"Command"`
+			Arguments []string `xml:"Arguments"`
+		} `xml:"CommandLine"`
+		Receive struct {
+			DesiredStream struct {
+				CommandId string `xml:"CommandId,attr"`
+			} `xml:"DesiredStream"`
+		} `xml:"Receive"`
+		Signal struct {
+			CommandId string `xml:"CommandId,attr"`
+		} `xml:"Signal"`
+	} `xml:"Body"`
+}
+
+func (s *WinRMServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, pass, ok := r.BasicAuth()
+	if !ok || user != s.username || s.password == "" || pass != s.password {
+		w.Header().Set("WWW-Authenticate", `Basic realm="WSMAN"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/wsman" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var envelope soapEnvelope
+	if err := xml.NewDecoder(r.Body).Decode(&envelope); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body string
+	switch envelope.Header.Action {
+	case actionCreate:
+		body = fmt.Sprintf(`<x:ResourceCreated><a:ReferenceParameters><w:SelectorSet><w:Selector Name="ShellId">%s</w:Selector></w:SelectorSet></a:ReferenceParameters></x:ResourceCreated>`, s.newId())
+	case actionCommand:
+		command := strings.Join(append([]string{envelope.Body.CommandLine.Command}, envelope.Body.CommandLine.Arguments...), " ")
+		result := runCommand(s.DNS, command)
+		id := s.newId()
+		s.mx.Lock()
+		s.commands[id] = result
+		s.mx.Unlock()
+		body = fmt.Sprintf(`<rsp:CommandResponse><rsp:CommandId>%s</rsp:CommandId></rsp:CommandResponse>`, id)
+	case actionReceive:
+		id := envelope.Body.Receive.DesiredStream.CommandId
+		s.mx.Lock()
+		result, ok := s.commands[id]
+		s.mx.Unlock()
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown command %s", id), http.StatusBadRequest)
+			return
+		}
+		body = receiveResponse(id, result)
+	case actionSignal:
+		s.mx.Lock()
+		delete(s.commands, envelope.Body.Signal.CommandId)
+		s.mx.Unlock()
+		body = `<rsp:SignalResponse/>`
+	case actionDelete:
+		body = ""
+	default:
+		http.Error(w, fmt.Sprintf("unsupported action %s", envelope.Header.Action), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/soap+xml;charset=UTF-8")
+	_, _ = io.WriteString(w, `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" `+
+		`xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" `+
+		`xmlns:x="http://schemas.xmlsoap.org/ws/2004/09/transfer" `+
+		`xmlns:w="http://schemas.dmtf.org/wbem/wsman/1/wsman.xsd" `+
+		`xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell">`+
+		`<s:Header><a:Action>`+envelope.Header.Action+`Response</a:Action></s:Header>`+
+		`<s:Body>`+body+`</s:Body></s:Envelope>`)
+}
+
+func (s *WinRMServer) newId() string {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.nextId++
+	return fmt.Sprintf("00000000-0000-0000-0000-%012X", s.nextId)
+}
+
+// receiveResponse returns all output of the command in one response, with the command done.
+func receiveResponse(id string, result *Result) string {
+	var b strings.Builder
+	b.WriteString("<rsp:ReceiveResponse>")
+	for _, stream := range []struct{ name, data string }{
+		{"stdout", result.Stdout},
+		{"stderr", encodeCLIXML(result.Stderr)},
+	} {
+		if stream.data == "" {
+			continue
+		}
+		fmt.Fprintf(&b, `<rsp:Stream Name="%s" CommandId="%s">%s</rsp:Stream>`, stream.name, id, base64.StdEncoding.EncodeToString([]byte(stream.data)))
+	}
+	fmt.Fprintf(&b, `<rsp:CommandState CommandId="%s" State="%s"><rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState>`, id, commandStateDone, result.ExitCode)
+	b.WriteString("</rsp:ReceiveResponse>")
+	return b.String()
+}
+
+// encodeCLIXML encodes stderr like powershell.exe does when its error stream is serialized, with one string per
+// line and line breaks escaped as _x000D__x000A_.
+func encodeCLIXML(stderr string) string {
+	if stderr == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("#< CLIXML\r\n")
+	b.WriteString(`<Objs Version="1.1.0.1" xmlns="http://schemas.microsoft.com/powershell/2004/04">`)
+	for _, line := range strings.SplitAfter(stderr, "\n") {
+		if line == "" {
+			continue
+		}
+		b.WriteString(`<S S="Error">`)
+		_ = xml.EscapeText(&b, []byte(strings.TrimSuffix(line, "\n")))
+		if strings.HasSuffix(line, "\n") {
+			b.WriteString("_x000D__x000A_")
+		}
+		b.WriteString(`</S>`)
+	}
+	b.WriteString(`</Objs>`)
+	return b.String()
+}
@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MIT
+
+package fakedns_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/exp/slices"
+)
+
+func startWinRMServer(t *testing.T) *fakedns.WinRMServer {
+	t.Helper()
+	dns := fakedns.NewServer("dc1")
+	if err := dns.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	server := fakedns.NewWinRMServer(dns, "tester", "secret")
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server
+}
+
+func TestWinRMServer_RecordLifecycle(t *testing.T) {
+	server := startWinRMServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		Transport:     config.TransportWinRM,
+		WinrmHostname: server.Host(),
+		WinrmPort:     server.Port(),
+		WinrmUsername: "tester",
+		WinrmPassword: "secret",
+		DnsServer:     "dc1",
+	})
+	ctx := context.Background()
+
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeA,
+		Records:    []string{"203.0.113.11", "203.0.113.12"},
+		TTL:        300,
+	}
+	id, err := record.Create(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"203.0.113.11", "203.0.113.12"}; !slices.Equal(got.Records, want) || got.TTL != 300 {
+		t.Errorf("got records %q with TTL %d, want %q with TTL 300", got.Records, got.TTL, want)
+	}
+
+	err = record.Delete(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if !errors.Is(err, dnshelper.ErrNotFound) || strings.Contains(err.Error(), "CLIXML") {
+		t.Errorf("expected a decoded ObjectNotFound error after delete, got %v", err)
+	}
+}
+
+func TestWinRMServer_WrongPassword(t *testing.T) {
+	server := startWinRMServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		Transport:     config.TransportWinRM,
+		WinrmHostname: server.Host(),
+		WinrmPort:     server.Port(),
+		WinrmUsername: "tester",
+		WinrmPassword: "wrong",
+	})
+
+	_, err := conf.Executor.Execute(context.Background(), "Get-DnsServerZone")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+}
+
+func TestWinRMServer_Kerberos(t *testing.T) {
+	server := startWinRMServer(t)
+	krbConfig := filepath.Join(t.TempDir(), "krb5.conf")
+	conf := config.NewProviderConf(&config.Settings{
+		Transport:           config.TransportWinRM,
+		WinrmHostname:       server.Host(),
+		WinrmPort:           server.Port(),
+		WinrmUsername:       "tester",
+		WinrmPassword:       "secret",
+		WinrmUseKerberos:    true,
+		WinrmKerberosRealm:  "EXAMPLE.COM",
+		WinrmKerberosConfig: krbConfig,
+	})
+
+	// The fake server only has basic authentication, so the request fails before it is sent, when the Kerberos
+	// configuration is loaded
+	_, err := conf.Executor.Execute(context.Background(), "Get-DnsServerZone")
+	if err == nil || !strings.Contains(err.Error(), krbConfig) {
+		t.Errorf("expected an error loading the Kerberos configuration, got %v", err)
+	}
+}
+
+func TestWinRMServer_CommandLineLength(t *testing.T) {
+	server := startWinRMServer(t)
+	conf := config.NewProviderConf(&config.Settings{
+		Transport:     config.TransportWinRM,
+		WinrmHostname: server.Host(),
+		WinrmPort:     server.Port(),
+		WinrmUsername: "tester",
+		WinrmPassword: "secret",
+		DnsServer:     "dc1",
+	})
+	ctx := context.Background()
+
+	// The values don't fit in one command line, so the batch is split
+	record := &dnshelper.Record{
+		ZoneName:   "example.com",
+		HostName:   "r1",
+		RecordType: dnshelper.RecordTypeA,
+		TTL:        300,
+	}
+	for i := 1; i <= 100; i++ {
+		record.Records = append(record.Records, fmt.Sprintf("203.0.113.%d", i))
+	}
+	id, err := record.Create(ctx, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dnshelper.GetDNSRecordFromId(ctx, conf, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Records) != len(record.Records) {
+		t.Errorf("got %d records, want %d", len(got.Records), len(record.Records))
+	}
+}
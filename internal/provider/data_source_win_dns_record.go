@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+func dataSourceDNSRecord() *schema.Resource {
+	return &schema.Resource{
+		Description: "`windns_record` looks up an existing DNS record set in a Windows DNS Server.",
+		ReadContext: dataSourceDNSRecordRead,
+		Schema: map[string]*schema.Schema{
+			"zone_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The zone name for the dns records.",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The name of the dns records.",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The type of the dns records. (AAAA, A, CNAME, TXT, PTR, MX, SRV, NS or CAA)",
+			},
+			"records": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "A list of records, in the same format as the `windns_record` resource.",
+				Set:         schema.HashString,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"mx": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "The MX records, if the type is MX.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"preference": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The preference of the mail server.",
+						},
+						"exchange": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the mail server.",
+						},
+					},
+				},
+			},
+			"srv": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "The SRV records, if the type is SRV.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"priority": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The priority of the target.",
+						},
+						"weight": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The weight of the target.",
+						},
+						"port": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The port of the service on the target.",
+						},
+						"target": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the host providing the service.",
+						},
+					},
+				},
+			},
+			"caa": {
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Description: "The CAA records, if the type is CAA.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"flags": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The flags of the record.",
+						},
+						"tag": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The property tag.",
+						},
+						"value": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The property value.",
+						},
+					},
+				},
+			},
+			"ttl": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The time to live (TTL) of the dns records, in seconds.",
+			},
+			"timestamp": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The time the dns records were last refreshed, in RFC 3339 format. Empty for static records.",
+			},
+		},
+	}
+}
+
+func dataSourceDNSRecordRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	zoneName, err := dnshelper.SanitiseTFInput(d, "zone_name")
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+	hostName, err := dnshelper.SanitiseTFInput(d, "name")
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+	recordType, err := dnshelper.SanitiseTFInput(d, "type")
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	lookup := dnshelper.Record{
+		ZoneName:   zoneName,
+		HostName:   hostName,
+		RecordType: recordType,
+	}
+
+	record, err := dnshelper.GetDNSRecordFromId(ctx, meta.(*config.ProviderConf), lookup.Id())
+	if err != nil {
+		return diag.Errorf("error while reading record %q of type %s in zone %q: %s", hostName, recordType, zoneName, err)
+	}
+
+	d.SetId(lookup.Id())
+	_ = d.Set("records", record.Records)
+	if block := dnshelper.RecordDataBlock(record.RecordType); block != "" {
+		blocks, err := dnshelper.RecordDataToBlocks(record.RecordType, record.Records)
+		if err != nil {
+			return diag.Errorf("error while reading record %q of type %s in zone %q: %s", hostName, recordType, zoneName, err)
+		}
+		_ = d.Set(block, blocks)
+	}
+	_ = d.Set("ttl", record.TTL)
+	_ = d.Set("timestamp", record.Timestamp)
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+const testAccDataSourceDNSRecordConfigBasic = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+  records   = ["203.0.113.11", "203.0.113.12"]
+  ttl       = 300
+}
+
+data "windns_record" "d1" {
+  name      = windns_record.r1.name
+  zone_name = windns_record.r1.zone_name
+  type      = windns_record.r1.type
+}
+`
+
+func TestAccDataSourceDNSRecord_Basic(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceDNSRecordConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.windns_record.d1", "records.#", "2"),
+					resource.TestCheckTypeSetElemAttr("data.windns_record.d1", "records.*", "203.0.113.11"),
+					resource.TestCheckTypeSetElemAttr("data.windns_record.d1", "records.*", "203.0.113.12"),
+					resource.TestCheckResourceAttr("data.windns_record.d1", "ttl", "300"),
+					resource.TestCheckResourceAttr("data.windns_record.d1", "timestamp", ""),
+				),
+			},
+		},
+	})
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+func dataSourceDNSZoneRecords() *schema.Resource {
+	return &schema.Resource{
+		Description: "`windns_zone_records` lists the DNS records in a zone in a Windows DNS Server. Records of types not supported by " +
+			"`windns_record`, other than SOA, are left out, as are records whose record data can't be read.",
+		ReadContext: dataSourceDNSZoneRecordsRead,
+		Schema: map[string]*schema.Schema{
+			"zone_name": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "The zone name to list dns records for.",
+			},
+			"type": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only list dns records of this type.",
+			},
+			"name_regex": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringIsValidRegExp,
+				Description:  "Only list dns records with a name matching this regular expression.",
+			},
+			"records": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The dns records in the zone, grouped by name and type.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the dns records.",
+						},
+						"type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The type of the dns records.",
+						},
+						"records": {
+							Type:        schema.TypeList,
+							Computed:    true,
+							Description: "A list of records, in the same format as the `windns_record` resource.",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"ttl": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "The time to live (TTL) of the dns records, in seconds.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceDNSZoneRecordsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	zoneName := d.Get("zone_name").(string)
+
+	var nameRegex *regexp.Regexp
+	if v := d.Get("name_regex").(string); v != "" {
+		nameRegex = regexp.MustCompile(v)
+	}
+
+	records, err := dnshelper.GetDNSRecordsInZone(ctx, meta.(*config.ProviderConf), zoneName, d.Get("type").(string))
+	if err != nil {
+		return diag.Errorf("error while listing records in zone %q: %s", zoneName, err)
+	}
+
+	var flattened []map[string]interface{}
+	for _, record := range records {
+		if nameRegex != nil && !nameRegex.MatchString(record.HostName) {
+			continue
+		}
+		flattened = append(flattened, map[string]interface{}{
+			"name":    record.HostName,
+			"type":    record.RecordType,
+			"records": record.Records,
+			"ttl":     record.TTL,
+		})
+	}
+
+	d.SetId(zoneName)
+	_ = d.Set("records", flattened)
+
+	return nil
+}
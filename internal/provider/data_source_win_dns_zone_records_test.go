@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+)
+
+const testAccDataSourceDNSZoneRecordsConfigBasic = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+  records   = ["203.0.113.11", "203.0.113.12"]
+}
+
+resource "windns_record" "r2" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "TXT"
+  records   = ["TXTDATA"]
+}
+
+data "windns_zone_records" "d1" {
+  zone_name  = "example.com"
+  name_regex = "^${var.windns_record_name}$"
+
+  depends_on = [windns_record.r1, windns_record.r2]
+}
+
+data "windns_zone_records" "d2" {
+  zone_name  = "example.com"
+  type       = "TXT"
+  name_regex = "^${var.windns_record_name}$"
+
+  depends_on = [windns_record.r1, windns_record.r2]
+}
+`
+
+func TestAccDataSourceDNSZoneRecords_Basic(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDataSourceDNSZoneRecordsConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.windns_zone_records.d1", "records.#", "2"),
+					resource.TestCheckResourceAttr("data.windns_zone_records.d1", "records.0.type", "A"),
+					resource.TestCheckResourceAttr("data.windns_zone_records.d1", "records.0.records.#", "2"),
+					resource.TestCheckResourceAttr("data.windns_zone_records.d1", "records.1.type", "TXT"),
+					resource.TestCheckResourceAttr("data.windns_zone_records.d2", "records.#", "1"),
+					resource.TestCheckResourceAttr("data.windns_zone_records.d2", "records.0.records.0", "TXTDATA"),
+				),
+			},
+		},
+	})
+}
@@ -4,11 +4,13 @@ package provider
 
 import (
 	"context"
+	"sync"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/nrkno/terraform-provider-windns/internal/config"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 // Provider exports the provider schema
@@ -16,23 +18,255 @@ func Provider(version string) func() *schema.Provider {
 	return func() *schema.Provider {
 		p := &schema.Provider{
 			Schema: map[string]*schema.Schema{
+				"transport": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_TRANSPORT", config.TransportSSH),
+					ValidateFunc: validation.StringInSlice([]string{config.TransportSSH, config.TransportWinRM, config.TransportLocal}, false),
+					Description:  "How PowerShell is run. `ssh` runs it on `ssh_hostname` over SSH, `winrm` runs it on `winrm_hostname` over WinRM, while `local` runs a local pwsh or powershell.exe process. Defaults to `ssh`. (Environment variable: WINDNS_TRANSPORT)",
+				},
+				"local_powershell_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_LOCAL_POWERSHELL_PATH", ""),
+					Description: "The PowerShell executable used by the `local` transport. Defaults to pwsh or powershell.exe in PATH. (Environment variable: WINDNS_LOCAL_POWERSHELL_PATH)",
+				},
 				"ssh_username": {
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_USERNAME", ""),
-					Description: "The username used to authenticate to the server's SSH service. (Environment variable: WINDNS_SSH_USERNAME)",
+					Description: "The username used to authenticate to the server's SSH service. Required for the `ssh` transport. (Environment variable: WINDNS_SSH_USERNAME)",
 				},
 				"ssh_password": {
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_PASSWORD", ""),
 					Description: "The password used to authenticate to the server's SSH service. (Environment variable: WINDNS_SSH_PASSWORD)",
 				},
+				"ssh_private_key": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_PRIVATE_KEY", ""),
+					Description: "The PEM encoded private key used to authenticate to the server's SSH service. Conflicts with `ssh_private_key_path`. (Environment variable: WINDNS_SSH_PRIVATE_KEY)",
+				},
+				"ssh_private_key_path": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_PRIVATE_KEY_PATH", ""),
+					Description: "The path to the private key used to authenticate to the server's SSH service. Conflicts with `ssh_private_key`. (Environment variable: WINDNS_SSH_PRIVATE_KEY_PATH)",
+				},
+				"ssh_private_key_passphrase": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_PRIVATE_KEY_PASSPHRASE", ""),
+					Description: "The passphrase of the private key, if it is encrypted. (Environment variable: WINDNS_SSH_PRIVATE_KEY_PASSPHRASE)",
+				},
+				"ssh_use_agent": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_USE_AGENT", false),
+					Description: "Use the keys in the ssh-agent given by SSH_AUTH_SOCK to authenticate to the server's SSH service. (Environment variable: WINDNS_SSH_USE_AGENT)",
+				},
+				"ssh_known_hosts_file": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_KNOWN_HOSTS_FILE", ""),
+					Description: "The known hosts file used to verify the server's SSH host key. Defaults to ~/.ssh/known_hosts. (Environment variable: WINDNS_SSH_KNOWN_HOSTS_FILE)",
+				},
+				"ssh_host_key": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_HOST_KEY", ""),
+					Description: "The server's SSH host key, either as a public key in authorized_keys format or as a SHA256 fingerprint (`SHA256:...`). Takes precedence over the known hosts file. (Environment variable: WINDNS_SSH_HOST_KEY)",
+				},
+				"ssh_strict_host_key_checking": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_SSH_STRICT_HOST_KEY_CHECKING", config.StrictHostKeyCheckingAcceptNew),
+					ValidateFunc: validation.StringInSlice([]string{config.StrictHostKeyCheckingYes, config.StrictHostKeyCheckingAcceptNew, config.StrictHostKeyCheckingNo}, false),
+					Description:  "How the server's SSH host key is verified. `yes` requires a pinned or known host key, `accept-new` adds unknown host keys to the known hosts file but rejects changed keys, and `no` accepts any host key. Defaults to `accept-new`. (Environment variable: WINDNS_SSH_STRICT_HOST_KEY_CHECKING)",
+				},
 				"ssh_hostname": {
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_HOSTNAME", ""),
-					Description: "The hostname of the server we will use to run powershell scripts over SSH. (Environment variable: WINDNS_SSH_HOSTNAME)",
+					Description: "The hostname of the server we will use to run powershell scripts over SSH. Required for the `ssh` transport. (Environment variable: WINDNS_SSH_HOSTNAME)",
+				},
+				"ssh_port": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_SSH_PORT", 22),
+					ValidateFunc: validation.IsPortNumber,
+					Description:  "The port of the server's SSH service. Defaults to 22. (Environment variable: WINDNS_SSH_PORT)",
+				},
+				"ssh_persistent_session": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_SSH_PERSISTENT_SESSION", false),
+					Description: "Run the scripts in a long-lived PowerShell session per SSH connection, instead of starting powershell.exe for every script. Modules like DnsServer are then only loaded once per connection. (Environment variable: WINDNS_SSH_PERSISTENT_SESSION)",
+				},
+				"ssh_max_connections": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_SSH_MAX_CONNECTIONS", 5),
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "The maximum number of SSH connections open at a time. Further commands wait for a connection to be available. Defaults to 5. (Environment variable: WINDNS_SSH_MAX_CONNECTIONS)",
+				},
+				"ssh_idle_timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_SSH_IDLE_TIMEOUT", 300),
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "The number of seconds an unused SSH connection is kept open. 0 keeps connections open until the provider exits. Defaults to 300. (Environment variable: WINDNS_SSH_IDLE_TIMEOUT)",
+				},
+				"ssh_keepalive_interval": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_SSH_KEEPALIVE_INTERVAL", 30),
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "The number of seconds between keepalive requests on open SSH connections. 0 disables keepalives. Defaults to 30. (Environment variable: WINDNS_SSH_KEEPALIVE_INTERVAL)",
+				},
+				"bastion": {
+					Type:        schema.TypeList,
+					Optional:    true,
+					MaxItems:    1,
+					Description: "An SSH bastion host the connection to `ssh_hostname` is tunneled through, like ProxyJump in OpenSSH.",
+					Elem: &schema.Resource{
+						Schema: map[string]*schema.Schema{
+							"host": {
+								Type:        schema.TypeString,
+								Required:    true,
+								Description: "The hostname of the bastion host.",
+							},
+							"port": {
+								Type:         schema.TypeInt,
+								Optional:     true,
+								Default:      22,
+								ValidateFunc: validation.IsPortNumber,
+								Description:  "The port of the bastion host's SSH service. Defaults to 22.",
+							},
+							"username": {
+								Type:        schema.TypeString,
+								Required:    true,
+								Description: "The username used to authenticate to the bastion host.",
+							},
+							"password": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Sensitive:   true,
+								Description: "The password used to authenticate to the bastion host.",
+							},
+							"private_key": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Sensitive:   true,
+								Description: "The PEM encoded private key used to authenticate to the bastion host.",
+							},
+							"private_key_path": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The path to the private key used to authenticate to the bastion host.",
+							},
+							"private_key_passphrase": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Sensitive:   true,
+								Description: "The passphrase of the private key, if it is encrypted.",
+							},
+							"use_agent": {
+								Type:        schema.TypeBool,
+								Optional:    true,
+								Description: "Use the keys in the ssh-agent given by SSH_AUTH_SOCK to authenticate to the bastion host.",
+							},
+							"host_key": {
+								Type:        schema.TypeString,
+								Optional:    true,
+								Description: "The bastion host's SSH host key, either as a public key in authorized_keys format or as a SHA256 fingerprint. Otherwise it is verified like the server's host key.",
+							},
+						},
+					},
+				},
+				"winrm_hostname": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_HOSTNAME", ""),
+					Description: "The hostname of the server we will use to run powershell scripts over WinRM. Required for the `winrm` transport. (Environment variable: WINDNS_WINRM_HOSTNAME)",
+				},
+				"winrm_username": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_USERNAME", ""),
+					Description: "The username used to authenticate to the server's WinRM service. Required for the `winrm` transport, unless `winrm_kerberos_ccache` is set. (Environment variable: WINDNS_WINRM_USERNAME)",
+				},
+				"winrm_password": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Sensitive:   true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_PASSWORD", ""),
+					Description: "The password used to authenticate to the server's WinRM service. Required for the `winrm` transport, unless `winrm_kerberos_ccache` is set. (Environment variable: WINDNS_WINRM_PASSWORD)",
+				},
+				"winrm_port": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_WINRM_PORT", 0),
+					ValidateFunc: validation.IntBetween(0, 65535),
+					Description:  "The port of the server's WinRM service. Defaults to 5985, or 5986 with `winrm_use_https`. (Environment variable: WINDNS_WINRM_PORT)",
+				},
+				"winrm_use_https": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_USE_HTTPS", false),
+					Description: "Connect to the server's WinRM service over HTTPS. (Environment variable: WINDNS_WINRM_USE_HTTPS)",
+				},
+				"winrm_insecure": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_INSECURE", false),
+					Description: "Skip verification of the server's TLS certificate. Requires `winrm_use_https`. (Environment variable: WINDNS_WINRM_INSECURE)",
+				},
+				"winrm_ca_cert": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_CA_CERT", ""),
+					Description: "The PEM encoded CA certificate used to verify the server's TLS certificate. Requires `winrm_use_https`. (Environment variable: WINDNS_WINRM_CA_CERT)",
+				},
+				"winrm_use_ntlm": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_USE_NTLM", false),
+					Description: "Authenticate to the server's WinRM service with NTLM instead of basic authentication. (Environment variable: WINDNS_WINRM_USE_NTLM)",
+				},
+				"winrm_use_kerberos": {
+					Type:        schema.TypeBool,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_USE_KERBEROS", false),
+					Description: "Authenticate to the server's WinRM service with Kerberos instead of basic authentication. (Environment variable: WINDNS_WINRM_USE_KERBEROS)",
+				},
+				"winrm_kerberos_realm": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_KERBEROS_REALM", ""),
+					Description: "The Kerberos realm of `winrm_username`, e.g. `EXAMPLE.COM`. Required with `winrm_use_kerberos`, unless `winrm_kerberos_ccache` is set. (Environment variable: WINDNS_WINRM_KERBEROS_REALM)",
+				},
+				"winrm_kerberos_config": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_KERBEROS_CONFIG", ""),
+					Description: "The path of the Kerberos configuration file. Defaults to `/etc/krb5.conf`. (Environment variable: WINDNS_WINRM_KERBEROS_CONFIG)",
+				},
+				"winrm_kerberos_spn": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_KERBEROS_SPN", ""),
+					Description: "The service principal name of the server's WinRM service. Defaults to `HTTP/<winrm_hostname>`. (Environment variable: WINDNS_WINRM_KERBEROS_SPN)",
+				},
+				"winrm_kerberos_ccache": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					DefaultFunc: schema.EnvDefaultFunc("WINDNS_WINRM_KERBEROS_CCACHE", ""),
+					Description: "The path of a Kerberos credential cache, e.g. from `kinit`, used instead of `winrm_username` and `winrm_password`. (Environment variable: WINDNS_WINRM_KERBEROS_CCACHE)",
 				},
 				"dns_server": {
 					Type:        schema.TypeString,
@@ -40,10 +274,30 @@ func Provider(version string) func() *schema.Provider {
 					DefaultFunc: schema.EnvDefaultFunc("WINDNS_DNS_SERVER_HOSTNAME", ""),
 					Description: "The hostname of the DNS server. (Environment variable: WINDNS_DNS_SERVER_HOSTNAME)",
 				},
+				"max_retries": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_MAX_RETRIES", 3),
+					ValidateFunc: validation.IntAtLeast(0),
+					Description:  "The number of times a command failing with a transient error, like a connection timeout or an unreachable DNS server, is retried. Defaults to 3. (Environment variable: WINDNS_MAX_RETRIES)",
+				},
+				"retry_max_wait": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					DefaultFunc:  schema.EnvDefaultFunc("WINDNS_RETRY_MAX_WAIT", 30),
+					ValidateFunc: validation.IntAtLeast(1),
+					Description:  "The maximum number of seconds to wait between retries. The wait starts at one second and is doubled for every retry. Defaults to 30. (Environment variable: WINDNS_RETRY_MAX_WAIT)",
+				},
+			},
+			DataSourcesMap: map[string]*schema.Resource{
+				"windns_record":       dataSourceDNSRecord(),
+				"windns_zone_records": dataSourceDNSZoneRecords(),
 			},
-			DataSourcesMap: map[string]*schema.Resource{},
 			ResourcesMap: map[string]*schema.Resource{
-				"windns_record": resourceDNSRecord(),
+				"windns_record":          resourceDNSRecord(),
+				"windns_zone":            resourceDNSZone(),
+				"windns_zone_delegation": resourceDNSZoneDelegation(),
+				"windns_zone_soa":        resourceDNSZoneSOA(),
 			},
 			ConfigureContextFunc: providerConfigure,
 		}
@@ -57,5 +311,35 @@ func providerConfigure(ctx context.Context, d *schema.ResourceData) (any, diag.D
 		return nil, diag.FromErr(err)
 	}
 	pcfg := config.NewProviderConf(cfg)
+
+	configuredMx.Lock()
+	configured = append(configured, pcfg)
+	configuredMx.Unlock()
+
+	// Close the pooled connections when Terraform stops the provider
+	if stopCtx, ok := schema.StopContext(ctx); ok {
+		go func() {
+			<-stopCtx.Done()
+			pcfg.Close()
+		}()
+	}
 	return pcfg, nil
 }
+
+// configured holds the configurations of the providers served by the plugin, so their connections can be closed
+// when it exits
+var (
+	configuredMx sync.Mutex
+	configured   []*config.ProviderConf
+)
+
+// Close closes the pooled connections of the configured providers. It is called when the plugin server exits, since
+// Terraform usually doesn't stop the provider before that.
+func Close() {
+	configuredMx.Lock()
+	defer configuredMx.Unlock()
+	for _, pcfg := range configured {
+		pcfg.Close()
+	}
+	configured = nil
+}
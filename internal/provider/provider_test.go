@@ -3,10 +3,14 @@
 package provider
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/nrkno/terraform-provider-windns/internal/fakedns"
+	"golang.org/x/crypto/ssh"
 )
 
 var (
@@ -23,6 +27,57 @@ func init() {
 	}
 }
 
+// TestMain starts an in-process fake DNS server when WINDNS_TEST_FAKE_SERVER is set, so the acceptance tests can run
+// without a Windows DNS server. The provider is configured for the fake server through the environment.
+func TestMain(m *testing.M) {
+	if os.Getenv("TF_ACC") == "" || os.Getenv("WINDNS_TEST_FAKE_SERVER") == "" {
+		os.Exit(m.Run())
+	}
+
+	server, err := startFakeDNSServer()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start fake DNS server: %s\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+func startFakeDNSServer() (*fakedns.SSHServer, error) {
+	dns := fakedns.NewServer("fakedns")
+	for _, zone := range []string{"example.com", "10.10.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"} {
+		if err := dns.AddZone(zone); err != nil {
+			return nil, err
+		}
+	}
+
+	server, err := fakedns.NewSSHServer(dns, "windns", "windns")
+	if err != nil {
+		return nil, err
+	}
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{
+		"WINDNS_SSH_HOSTNAME": server.Host(),
+		"WINDNS_SSH_PORT":     strconv.Itoa(server.Port()),
+		"WINDNS_SSH_USERNAME": "windns",
+		"WINDNS_SSH_PASSWORD": "windns",
+		"WINDNS_SSH_HOST_KEY": ssh.FingerprintSHA256(server.HostKey()),
+	}
+	if os.Getenv("TF_VAR_windns_record_name") == "" {
+		env["TF_VAR_windns_record_name"] = "tf-acc-record"
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return server, nil
+}
+
 func TestProvider(t *testing.T) {
 	if err := Provider("dev")().InternalValidate(); err != nil {
 		t.Fatalf("err: %s", err)
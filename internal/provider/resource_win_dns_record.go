@@ -4,11 +4,15 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/nrkno/terraform-provider-windns/internal/config"
 	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
 )
@@ -23,6 +27,12 @@ func resourceDNSRecord() *schema.Resource {
 		CreateContext: resourceDNSRecordCreate,
 		UpdateContext: resourceDNSRecordUpdate,
 		DeleteContext: resourceDNSRecordDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
 		Schema: map[string]*schema.Schema{
 			"zone_name": {
 				Type:             schema.TypeString,
@@ -34,29 +44,127 @@ func resourceDNSRecord() *schema.Resource {
 				Type:             schema.TypeString,
 				Required:         true,
 				DiffSuppressFunc: suppressCaseDiff,
-				Description:      "The name of the dns records.",
+				Description:      "The name of the dns records. Use `@` for records at the zone apex.",
 			},
 			"type": {
 				Type:             schema.TypeString,
 				Required:         true,
 				DiffSuppressFunc: suppressCaseDiff,
-				Description:      "The type of the dns records.",
+				Description:      "The type of the dns records. (AAAA, A, CNAME, TXT, PTR, MX, SRV, NS or CAA)",
 			},
 			"records": {
 				Type:             schema.TypeSet,
-				Required:         true,
-				Description:      "A list of records.",
+				Optional:         true,
+				Computed:         true,
+				ExactlyOneOf:     recordDataKeys,
+				Description:      "A list of records. MX records are given as `<preference> <exchange>`, e.g. `10 mail.example.com`, or in `mx` blocks instead. SRV records are given as `<priority> <weight> <port> <target>`, e.g. `0 5 88 dc1.example.com`, or in `srv` blocks instead. CAA records are given as `<flags> <tag> \"<value>\"`, e.g. `0 issue \"letsencrypt.org\"`, or in `caa` blocks instead. TXT values longer than 255 bytes are stored as several strings of at most 255 bytes, and read back as one value.",
 				DiffSuppressFunc: suppressRecordDiff,
 				Set:              schema.HashString,
 				Elem:             &schema.Schema{Type: schema.TypeString},
 				MinItems:         1,
 			},
+			"mx": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: recordDataKeys,
+				Set:          hashMXRecordData,
+				Description:  "An MX record, as an alternative to the records list. The records list is computed from the blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"preference": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(0, 65535),
+							Description:  "The preference of the mail server. Lower values are preferred.",
+						},
+						"exchange": {
+							Type:             schema.TypeString,
+							Required:         true,
+							DiffSuppressFunc: suppressDomainNameDiff,
+							Description:      "The name of the mail server, e.g. `mail.example.com`.",
+						},
+					},
+				},
+			},
+			"srv": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: recordDataKeys,
+				Set:          hashSRVRecordData,
+				Description:  "An SRV record, as an alternative to the records list. The records list is computed from the blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"priority": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(0, 65535),
+							Description:  "The priority of the target. Lower values are preferred.",
+						},
+						"weight": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(0, 65535),
+							Description:  "The weight of the target among targets with the same priority.",
+						},
+						"port": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(0, 65535),
+							Description:  "The port of the service on the target.",
+						},
+						"target": {
+							Type:             schema.TypeString,
+							Required:         true,
+							DiffSuppressFunc: suppressDomainNameDiff,
+							Description:      "The name of the host providing the service, e.g. `dc1.example.com`.",
+						},
+					},
+				},
+			},
+			"caa": {
+				Type:         schema.TypeSet,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: recordDataKeys,
+				Set:          hashCAARecordData,
+				Description:  "A CAA record, as an alternative to the records list. The records list is computed from the blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"flags": {
+							Type:         schema.TypeInt,
+							Required:     true,
+							ValidateFunc: validation.IntBetween(0, 255),
+							Description:  "The flags of the record. 128 marks the property as critical.",
+						},
+						"tag": {
+							Type:             schema.TypeString,
+							Required:         true,
+							DiffSuppressFunc: suppressCaseDiff,
+							Description:      "The property tag, e.g. `issue`, `issuewild` or `iodef`.",
+						},
+						"value": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "The property value, without quotes, e.g. `letsencrypt.org`.",
+						},
+					},
+				},
+			},
 			"create_ptr": {
 				Type:        schema.TypeBool,
 				Required:    false,
 				Optional:    true,
 				Description: "Create PTR records for requested (A or AAAA) records.",
 			},
+			"ttl": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "The time to live (TTL) of the dns records, in seconds. Defaults to the zone default when not set. Removing `ttl` from the configuration keeps the current TTL of the records, instead of resetting it to the zone default.",
+			},
 		},
 		CustomizeDiff: customdiff.All(
 			customdiff.ForceNewIfChange("zone_name", func(ctx context.Context, old, new, meta any) bool {
@@ -71,19 +179,94 @@ func resourceDNSRecord() *schema.Resource {
 			customdiff.ForceNewIfChange("type", func(ctx context.Context, old, new, meta any) bool {
 				return new.(string) != old.(string)
 			}),
+			customizeRecordDataDiff,
 		),
 	}
 }
 
+// recordDataKeys are the attributes the values of the records can be given in. The records list takes any record
+// type, while the blocks take the structured values of one type.
+var recordDataKeys = []string{"records", "mx", "srv", "caa"}
+
+// hashMXRecordData hashes an MX record the way the DNS server compares them, so an exchange read back with a
+// trailing dot is the same element of the set.
+func hashMXRecordData(v interface{}) int {
+	m := v.(map[string]interface{})
+	return schema.HashString(fmt.Sprintf("%d %s", m["preference"].(int), dnshelper.NameServerKey(m["exchange"].(string))))
+}
+
+// hashSRVRecordData hashes an SRV record the way the DNS server compares them, so a target read back with a
+// trailing dot is the same element of the set.
+func hashSRVRecordData(v interface{}) int {
+	m := v.(map[string]interface{})
+	return schema.HashString(fmt.Sprintf("%d %d %d %s", m["priority"].(int), m["weight"].(int), m["port"].(int),
+		dnshelper.NameServerKey(m["target"].(string))))
+}
+
+// hashCAARecordData hashes a CAA record the way the DNS server compares them, so a tag read back in lower case is
+// the same element of the set.
+func hashCAARecordData(v interface{}) int {
+	m := v.(map[string]interface{})
+	return schema.HashString(fmt.Sprintf("%d %s %s", m["flags"].(int), strings.ToLower(m["tag"].(string)), m["value"].(string)))
+}
+
+// customizeRecordDataDiff computes the records list from the structured record data blocks, or the blocks from the
+// records list, depending on which of them is in the configuration. The plan then shows the values in both forms.
+func customizeRecordDataDiff(ctx context.Context, d *schema.ResourceDiff, meta any) error {
+	rawConfig := d.GetRawConfig()
+	if rawConfig.IsNull() {
+		return nil
+	}
+	recordType := d.Get("type").(string)
+	block := dnshelper.RecordDataBlock(recordType)
+	for _, key := range recordDataKeys[1:] {
+		if key != block && isConfigured(rawConfig.GetAttr(key)) {
+			return fmt.Errorf("%s blocks can't be used with %s records", key, recordType)
+		}
+	}
+	if block == "" {
+		return nil
+	}
+
+	if blocks := rawConfig.GetAttr(block); isConfigured(blocks) {
+		if !blocks.IsWhollyKnown() {
+			return d.SetNewComputed("records")
+		}
+		if !d.HasChange(block) {
+			return nil
+		}
+		return d.SetNew("records", dnshelper.RecordDataFromBlocks(recordType, d.Get(block).(*schema.Set).List()))
+	}
+
+	if !rawConfig.GetAttr("records").IsWhollyKnown() {
+		return d.SetNewComputed(block)
+	}
+	if !d.HasChange("records") {
+		return nil
+	}
+	blocks, err := dnshelper.RecordDataToBlocks(recordType, setToStringSlice(d.Get("records").(*schema.Set)))
+	if err != nil {
+		return err
+	}
+	return d.SetNew(block, blocks)
+}
+
 func resourceDNSRecordCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	record, err := dnshelper.NewDNSRecordFromResource(d)
 	if err != nil {
 		return diag.Errorf("error when mapping input data: %s", err)
 	}
 
-	id, err := record.Create(meta.(*config.ProviderConf))
+	id, err := record.Create(ctx, meta.(*config.ProviderConf))
 	if err != nil {
-		return diag.Errorf("error while creating new record object: %s", err)
+		var updateErr *dnshelper.RecordUpdateError
+		if errors.As(err, &updateErr) && !updateErr.RolledBack() && len(updateErr.Actual) > 0 {
+			// Some values were created and couldn't be removed, so we keep them in the state. The resource is
+			// tainted since Create failed, and will be replaced on the next apply.
+			d.SetId(record.Id())
+			_ = setRecordData(d, record.RecordType, updateErr.Actual)
+		}
+		return errorDiagnostics(err, "error while creating new record object: %s", err)
 	}
 	d.SetId(id)
 
@@ -97,7 +280,7 @@ func resourceDNSRecordRead(ctx context.Context, d *schema.ResourceData, meta int
 
 	record, err := dnshelper.GetDNSRecordFromId(ctx, meta.(*config.ProviderConf), d.Id())
 	if err != nil {
-		if strings.Contains(err.Error(), "ObjectNotFound") {
+		if errors.Is(err, dnshelper.ErrNotFound) {
 			// Resource no longer exists
 			d.SetId("")
 			return nil
@@ -108,9 +291,26 @@ func resourceDNSRecordRead(ctx context.Context, d *schema.ResourceData, meta int
 	_ = d.Set("zone_name", record.ZoneName)
 	_ = d.Set("name", record.HostName)
 	_ = d.Set("type", record.RecordType)
-	_ = d.Set("records", record.Records)
+	err = setRecordData(d, record.RecordType, record.Records)
+	if err != nil {
+		return diag.Errorf("error while reading record with id %q: %s", d.Id(), err)
+	}
 	_ = d.Set("create_ptr", record.CreatePtr)
+	_ = d.Set("ttl", record.TTL)
+
+	return nil
+}
 
+// setRecordData sets the records list, and the block of the record type if it has one.
+func setRecordData(d *schema.ResourceData, recordType string, records []string) error {
+	_ = d.Set("records", records)
+	if block := dnshelper.RecordDataBlock(recordType); block != "" {
+		blocks, err := dnshelper.RecordDataToBlocks(recordType, records)
+		if err != nil {
+			return err
+		}
+		_ = d.Set(block, blocks)
+	}
 	return nil
 }
 
@@ -119,17 +319,32 @@ func resourceDNSRecordUpdate(ctx context.Context, d *schema.ResourceData, meta i
 	if err != nil {
 		return diag.Errorf("error when mapping input data: %s", err)
 	}
-	keys := []string{"records"}
 	changes := make(map[string]interface{})
-	for _, key := range keys {
-		if d.HasChange(key) {
-			changes[key] = d.Get(key)
+	if d.HasChanges(recordDataKeys...) {
+		// The records list is unknown in the plan when it is computed from blocks with unknown values, so the values
+		// are taken from the record
+		records := make([]interface{}, len(record.Records))
+		for i, v := range record.Records {
+			records[i] = v
 		}
+		changes["records"] = schema.NewSet(schema.HashString, records)
+	}
+	if d.HasChange("ttl") {
+		changes["ttl"] = d.Get("ttl")
 	}
 
 	err = record.Update(ctx, meta.(*config.ProviderConf), changes)
 	if err != nil {
-		return diag.Errorf("error while updating record with id %q: %s", d.Id(), err)
+		var updateErr *dnshelper.RecordUpdateError
+		if errors.As(err, &updateErr) && !updateErr.RolledBack() && updateErr.Actual != nil {
+			// The changes couldn't be rolled back, so the values read back from the DNS server are stored
+			_ = setRecordData(d, record.RecordType, updateErr.Actual)
+		} else {
+			// The record set is either unchanged, or what it holds isn't known, so the prior state is kept instead of
+			// the planned values
+			d.Partial(true)
+		}
+		return errorDiagnostics(err, "error while updating record with id %q: %s", d.Id(), err)
 	}
 	return resourceDNSRecordRead(ctx, d, meta)
 }
@@ -143,9 +358,9 @@ func resourceDNSRecordDelete(ctx context.Context, d *schema.ResourceData, meta i
 		return diag.Errorf("error when mapping input data: %s", err)
 	}
 
-	err = record.Delete(meta.(*config.ProviderConf))
+	err = record.Delete(ctx, meta.(*config.ProviderConf))
 	if err != nil {
-		return diag.Errorf("error while deleting a record object with id %q: %s", d.Id(), err)
+		return errorDiagnostics(err, "error while deleting a record object with id %q: %s", d.Id(), err)
 	}
 
 	return nil
@@ -4,12 +4,14 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 	"github.com/nrkno/terraform-provider-windns/internal/config"
 	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
@@ -22,8 +24,12 @@ Prerequisites for acceptance tests
 	- example.com
 	- 10.10.in-addr.arpa
 	- 8.b.d.0.1.0.0.2.ip6.arpa
+- A Windows DNS server where the test user may create and delete zones (for the windns_zone tests).
 - A Windows server with SSH enabled and the Powershell DnsServer module installed.
 	- This could be the same as running the DNS server, or another to jump through.
+
+Alternatively, set WINDNS_TEST_FAKE_SERVER=1 to run the tests against the in-process fake DNS server in
+internal/fakedns, which is started with the zones above.
 */
 
 const testAccResourceDNSRecordConfigBasicPTR = `
@@ -86,10 +92,26 @@ resource "windns_record" "r1" {
   name      = var.windns_record_name
   zone_name = "example.com"
   type      = "TXT"
-  records   = ["TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~"]
+  records   = ["TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~ \"quoted\" $env:PATH"]
 }
 `
 
+// testAccDKIMRecord is a TXT value longer than 255 bytes, which is stored as several character-strings
+var testAccDKIMRecord = `v=DKIM1; k=rsa; n="quoted \\ notes"; p=` + strings.Repeat("MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA", 8)
+
+func testAccResourceDNSRecordConfigLongTXT() string {
+	return fmt.Sprintf(`
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "TXT"
+  records   = [%q]
+}
+`, testAccDKIMRecord)
+}
+
 const testAccResourceDNSRecordConfigMultiple = `
 variable "windns_record_name" {}
 
@@ -151,6 +173,242 @@ resource "windns_record" "r1" {
 }
 `
 
+const testAccResourceDNSRecordConfigNS = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "NS"
+  records   = ["ns1.example.net", "ns2.example.net."]
+}
+`
+
+const testAccResourceDNSRecordConfigTTL = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+  records   = ["203.0.113.11", "203.0.113.12"]
+  ttl       = 300
+}
+`
+
+const testAccResourceDNSRecordConfigTTLUpdated = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+  records   = ["203.0.113.11", "203.0.113.12"]
+  ttl       = 600
+}
+`
+
+const testAccResourceDNSRecordConfigTTLRemoved = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+  records   = ["203.0.113.11", "203.0.113.12"]
+}
+`
+
+const testAccResourceDNSRecordConfigMX = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "MX"
+  records   = ["10 mail1.example.com", "20 mail2.example.com."]
+}
+`
+
+const testAccResourceDNSRecordConfigMXUpdated = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "MX"
+  records   = ["10 mail1.example.com", "30 mail2.example.com."]
+}
+`
+
+const testAccResourceDNSRecordConfigMXBlocks = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "MX"
+
+  mx {
+    preference = 10
+    exchange   = "mail1.example.com"
+  }
+  mx {
+    preference = 20
+    exchange   = "mail2.example.com."
+  }
+}
+`
+
+const testAccResourceDNSRecordConfigMXBlocksUpdated = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "MX"
+
+  mx {
+    preference = 10
+    exchange   = "mail1.example.com"
+  }
+  mx {
+    preference = 30
+    exchange   = "mail3.example.com"
+  }
+}
+`
+
+const testAccResourceDNSRecordConfigMXBlocksAsRecords = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "MX"
+  records   = ["10 mail1.example.com", "30 mail3.example.com"]
+}
+`
+
+const testAccResourceDNSRecordConfigMXBlockWrongType = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "A"
+
+  mx {
+    preference = 10
+    exchange   = "mail1.example.com"
+  }
+}
+`
+
+const testAccResourceDNSRecordConfigApex = `
+resource "windns_record" "r1" {
+  name      = "@"
+  zone_name = "example.com"
+  type      = "MX"
+  records   = ["10 mail1.example.com"]
+}
+
+resource "windns_record" "r2" {
+  name      = "@"
+  zone_name = "example.com"
+  type      = "TXT"
+  records   = ["v=spf1 mx -all"]
+}
+`
+
+const testAccResourceDNSRecordConfigSRV = `
+resource "windns_record" "r1" {
+  name      = "_kerberos._tcp"
+  zone_name = "example.com"
+  type      = "SRV"
+  records   = ["0 100 88 dc1.example.com", "10 100 88 dc2.example.com."]
+}
+`
+
+const testAccResourceDNSRecordConfigSRVBlocks = `
+resource "windns_record" "r1" {
+  name      = "_ldap._tcp"
+  zone_name = "example.com"
+  type      = "SRV"
+
+  srv {
+    priority = 0
+    weight   = 100
+    port     = 389
+    target   = "dc1.example.com"
+  }
+  srv {
+    priority = 10
+    weight   = 100
+    port     = 389
+    target   = "dc2.example.com."
+  }
+}
+`
+
+const testAccResourceDNSRecordConfigSRVBlocksUpdated = `
+resource "windns_record" "r1" {
+  name      = "_ldap._tcp"
+  zone_name = "example.com"
+  type      = "SRV"
+
+  srv {
+    priority = 0
+    weight   = 50
+    port     = 636
+    target   = "dc1.example.com"
+  }
+}
+`
+
+const testAccResourceDNSRecordConfigCAA = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "CAA"
+  records   = ["0 issue \"letsencrypt.org\"", "0 ISSUEWILD ;"]
+}
+`
+
+const testAccResourceDNSRecordConfigCAAUpdated = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "CAA"
+  records   = ["0 issue \"letsencrypt.org\"", "0 iodef \"mailto:security@example.com\""]
+}
+`
+
+const testAccResourceDNSRecordConfigCAABlocks = `
+variable "windns_record_name" {}
+
+resource "windns_record" "r1" {
+  name      = var.windns_record_name
+  zone_name = "example.com"
+  type      = "CAA"
+
+  caa {
+    flags = 0
+    tag   = "issue"
+    value = "letsencrypt.org"
+  }
+  caa {
+    flags = 0
+    tag   = "IODEF"
+    value = "mailto:security@example.com"
+  }
+}
+`
+
 const testAccResourceDNSRecordConfigIllegalCharacter = `
 variable "windns_record_name" {}
 
@@ -294,13 +552,38 @@ func TestAccResourceDNSRecord_BasicTXT(t *testing.T) {
 		PreCheck:          func() { testAccPreCheck(t, envVars) },
 		ProviderFactories: testAccProviderFactories,
 		CheckDestroy: resource.ComposeTestCheckFunc(
-			testAccResourceDNSRecordExists("windns_record.r1", []string{"TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~"}, dnshelper.RecordTypeTXT, false),
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~ \"quoted\" $env:PATH"}, dnshelper.RecordTypeTXT, false),
 		),
 		Steps: []resource.TestStep{
 			{
 				Config: testAccResourceDNSRecordConfigBasicTXT,
 				Check: resource.ComposeTestCheckFunc(
-					testAccResourceDNSRecordExists("windns_record.r1", []string{"TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~"}, dnshelper.RecordTypeTXT, true),
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"TxTdATa9 &!#$%&'()*+,-./:;<=>?@[]^_{|}~ \"quoted\" $env:PATH"}, dnshelper.RecordTypeTXT, true),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_LongTXT(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{testAccDKIMRecord}, dnshelper.RecordTypeTXT, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigLongTXT(),
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{testAccDKIMRecord}, dnshelper.RecordTypeTXT, true),
 				),
 			},
 			{
@@ -408,6 +691,295 @@ func TestAccResourceDNSRecord_CNAME(t *testing.T) {
 	})
 }
 
+func TestAccResourceDNSRecord_NS(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"ns1.example.net", "ns2.example.net."}, dnshelper.RecordTypeNS, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigNS,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"ns1.example.net", "ns2.example.net."}, dnshelper.RecordTypeNS, true),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_TTL(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"203.0.113.11", "203.0.113.12"}, dnshelper.RecordTypeA, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigTTL,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"203.0.113.11", "203.0.113.12"}, dnshelper.RecordTypeA, true),
+					resource.TestCheckResourceAttr("windns_record.r1", "ttl", "300"),
+				),
+			},
+			{
+				Config: testAccResourceDNSRecordConfigTTLUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"203.0.113.11", "203.0.113.12"}, dnshelper.RecordTypeA, true),
+					resource.TestCheckResourceAttr("windns_record.r1", "ttl", "600"),
+				),
+			},
+			{
+				// Removing ttl keeps the current TTL instead of resetting it to the zone default
+				Config: testAccResourceDNSRecordConfigTTLRemoved,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("windns_record.r1", "ttl", "600"),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_MX(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "30 mail2.example.com."}, dnshelper.RecordTypeMX, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigMX,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "20 mail2.example.com."}, dnshelper.RecordTypeMX, true),
+				),
+			},
+			{
+				Config: testAccResourceDNSRecordConfigMXUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "30 mail2.example.com."}, dnshelper.RecordTypeMX, true),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_MXBlocks(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "30 mail3.example.com"}, dnshelper.RecordTypeMX, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigMXBlocks,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "20 mail2.example.com"}, dnshelper.RecordTypeMX, true),
+					resource.TestCheckTypeSetElemAttr("windns_record.r1", "records.*", "20 mail2.example.com."),
+					resource.TestCheckResourceAttr("windns_record.r1", "mx.#", "2"),
+				),
+			},
+			{
+				Config: testAccResourceDNSRecordConfigMXBlocksUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com", "30 mail3.example.com"}, dnshelper.RecordTypeMX, true),
+					resource.TestCheckTypeSetElemAttr("windns_record.r1", "records.*", "30 mail3.example.com."),
+					resource.TestCheckTypeSetElemNestedAttrs("windns_record.r1", "mx.*", map[string]string{"preference": "30"}),
+				),
+			},
+			{
+				// The records list and the blocks hold the same values, so switching between them doesn't change anything
+				Config:   testAccResourceDNSRecordConfigMXBlocksAsRecords,
+				PlanOnly: true,
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config:      testAccResourceDNSRecordConfigMXBlockWrongType,
+				ExpectError: regexp.MustCompile("mx blocks can't be used with A records"),
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_SRV(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"0 100 88 dc1.example.com", "10 100 88 dc2.example.com."}, dnshelper.RecordTypeSRV, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigSRV,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"0 100 88 dc1.example.com", "10 100 88 dc2.example.com."}, dnshelper.RecordTypeSRV, true),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_SRVBlocks(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"0 50 636 dc1.example.com"}, dnshelper.RecordTypeSRV, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigSRVBlocks,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"0 100 389 dc1.example.com", "10 100 389 dc2.example.com"}, dnshelper.RecordTypeSRV, true),
+					resource.TestCheckResourceAttr("windns_record.r1", "srv.#", "2"),
+				),
+			},
+			{
+				Config: testAccResourceDNSRecordConfigSRVBlocksUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"0 50 636 dc1.example.com"}, dnshelper.RecordTypeSRV, true),
+					resource.TestCheckResourceAttr("windns_record.r1", "records.#", "1"),
+					resource.TestCheckTypeSetElemAttr("windns_record.r1", "records.*", "0 50 636 dc1.example.com."),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_CAA(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{`0 issue "letsencrypt.org"`, `0 iodef "mailto:security@example.com"`}, dnshelper.RecordTypeCAA, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigCAA,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{`0 issue "letsencrypt.org"`, `0 issuewild ";"`}, dnshelper.RecordTypeCAA, true),
+				),
+			},
+			{
+				Config: testAccResourceDNSRecordConfigCAAUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{`0 issue "letsencrypt.org"`, `0 iodef "mailto:security@example.com"`}, dnshelper.RecordTypeCAA, true),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_CAABlocks(t *testing.T) {
+	envVars := []string{"TF_VAR_windns_record_name"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, envVars) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{`0 issue "letsencrypt.org"`, `0 iodef "mailto:security@example.com"`}, dnshelper.RecordTypeCAA, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigCAABlocks,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{`0 issue "letsencrypt.org"`, `0 iodef "mailto:security@example.com"`}, dnshelper.RecordTypeCAA, true),
+					resource.TestCheckTypeSetElemAttr("windns_record.r1", "records.*", `0 iodef "mailto:security@example.com"`),
+					resource.TestCheckResourceAttr("windns_record.r1", "caa.#", "2"),
+				),
+			},
+			{
+				// The records list and the blocks hold the same values, so switching between them doesn't change anything
+				Config:   testAccResourceDNSRecordConfigCAAUpdated,
+				PlanOnly: true,
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSRecord_Apex(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com"}, dnshelper.RecordTypeMX, false),
+			testAccResourceDNSRecordExists("windns_record.r2", []string{"v=spf1 mx -all"}, dnshelper.RecordTypeTXT, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSRecordConfigApex,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSRecordExists("windns_record.r1", []string{"10 mail1.example.com"}, dnshelper.RecordTypeMX, true),
+					testAccResourceDNSRecordExists("windns_record.r2", []string{"v=spf1 mx -all"}, dnshelper.RecordTypeTXT, true),
+					resource.TestCheckResourceAttr("windns_record.r1", "name", "@"),
+				),
+			},
+			{
+				ResourceName:      "windns_record.r1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				ResourceName:      "windns_record.r2",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
 func TestAccResourceDNSRecord_IllegalCharacter(t *testing.T) {
 	envVars := []string{"TF_VAR_windns_record_name"}
 
@@ -436,7 +1008,7 @@ func testAccResourceDNSRecordExists(resource string, expectedRecords []string, e
 
 		r, err := dnshelper.GetDNSRecordFromId(ctx, testAccProvider.Meta().(*config.ProviderConf), rs.Primary.ID)
 		if err != nil {
-			if strings.Contains(err.Error(), "ObjectNotFound") && !expected {
+			if errors.Is(err, dnshelper.ErrNotFound) && !expected {
 				return nil
 			}
 			return err
@@ -450,3 +1022,43 @@ func testAccResourceDNSRecordExists(resource string, expectedRecords []string, e
 		return nil
 	}
 }
+
+// failingRollbackExecutor reads the record set once, fails to add the last value and fails everything after that,
+// so neither the rollback nor reading the record set back works
+type failingRollbackExecutor struct {
+	reads int
+}
+
+func (e *failingRollbackExecutor) Execute(ctx context.Context, script string) (*config.ExecuteResult, error) {
+	switch {
+	case strings.Contains(script, "Get-DnsServerResourceRecord") && e.reads == 0:
+		e.reads++
+		return &config.ExecuteResult{Stdout: `[{"HostName":"r1","RecordType":"A","RecordData":{"CimInstanceProperties":[{"Name":"IPv4Address","Value":"203.0.113.11"}]},"TimeToLive":{"TotalSeconds":3600}}]`}, nil
+	case strings.Contains(script, "Add-DNSServerResourceRecord"):
+		return &config.ExecuteResult{Stdout: `[{"Index":0,"Error":null},{"Index":1,"Error":{"Message":"Failed to create resource record r1 in zone example.com on server dc1."}}]`}, nil
+	default:
+		return nil, errors.New("run error: EOF")
+	}
+}
+
+func TestResourceDNSRecordUpdate_RollbackFailure(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceDNSRecord().Schema, map[string]interface{}{
+		"zone_name": "example.com",
+		"name":      "r1",
+		"type":      "A",
+		"records":   []interface{}{"203.0.113.11", "203.0.113.12", "203.0.113.13"},
+	})
+	d.SetId("r1_example.com_A_false")
+	conf := config.NewProviderConf(&config.Settings{DnsServer: "dc1"})
+	conf.Executor = &failingRollbackExecutor{}
+
+	diags := resourceDNSRecordUpdate(context.Background(), d, conf)
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, "Rolling back the applied changes failed") {
+		t.Fatalf("resourceDNSRecordUpdate() = %v, want a failed rollback", diags)
+	}
+
+	// The record set isn't known, so the planned values must not be stored
+	if n := d.State().Attributes["records.#"]; n == "3" {
+		t.Errorf("the planned records were stored after a failed rollback")
+	}
+}
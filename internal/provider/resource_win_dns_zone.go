@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+func resourceDNSZone() *schema.Resource {
+	return &schema.Resource{
+		Description: "`windns_zone` manages primary zones in a Windows DNS Server.",
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		ReadContext:   resourceDNSZoneRead,
+		CreateContext: resourceDNSZoneCreate,
+		UpdateContext: resourceDNSZoneUpdate,
+		DeleteContext: resourceDNSZoneDelete,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressCaseDiff,
+				ExactlyOneOf:     []string{"name", "network_id"},
+				Description:      "The name of the zone. Computed for reverse lookup zones created with `network_id`.",
+			},
+			"network_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "The network ID of a reverse lookup zone, e.g. `10.10.0.0/16` or `2001:db8::/32`. Computed for reverse lookup zones created with `name`.",
+			},
+			"replication_scope": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"Forest", "Domain", "Legacy", "Custom"}, false),
+				ExactlyOneOf: []string{"replication_scope", "zone_file"},
+				Description:  "The Active Directory replication scope of the zone. (Forest, Domain, Legacy or Custom)",
+			},
+			"zone_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "The name of the zone file, for zones that are not stored in Active Directory.",
+			},
+			"dynamic_update": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.StringInSlice([]string{"None", "Secure", "NonsecureAndSecure"}, false),
+				Description:  "How the zone accepts dynamic updates. (None, Secure or NonsecureAndSecure)",
+			},
+			"is_reverse_lookup_zone": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the zone is a reverse lookup zone.",
+			},
+		},
+		CustomizeDiff: customdiff.All(
+			// Moving a zone between a zone file and Active Directory requires recreating it.
+			customdiff.ForceNewIfChange("replication_scope", func(ctx context.Context, old, new, meta any) bool {
+				return old.(string) == "" || new.(string) == ""
+			}),
+		),
+	}
+}
+
+func resourceDNSZoneCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	zone, err := dnshelper.NewDNSZoneFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	id, err := zone.Create(ctx, meta.(*config.ProviderConf))
+	if err != nil {
+		return errorDiagnostics(err, "error while creating new zone object: %s", err)
+	}
+	d.SetId(id)
+
+	return resourceDNSZoneRead(ctx, d, meta)
+}
+
+func resourceDNSZoneRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Id() == "" {
+		return nil
+	}
+
+	zone, err := dnshelper.GetDNSZoneFromId(ctx, meta.(*config.ProviderConf), d.Id())
+	if err != nil {
+		if errors.Is(err, dnshelper.ErrNotFound) {
+			// Resource no longer exists
+			d.SetId("")
+			return nil
+		}
+		return diag.Errorf("error while reading zone with id %q: %s", d.Id(), err)
+	}
+
+	_ = d.Set("name", zone.ZoneName)
+	_ = d.Set("replication_scope", zone.ReplicationScope)
+	_ = d.Set("zone_file", zone.ZoneFile)
+	_ = d.Set("dynamic_update", zone.DynamicUpdate)
+	_ = d.Set("is_reverse_lookup_zone", zone.IsReverseZone)
+
+	if zone.IsReverseZone {
+		// Keep the configured spelling of the network ID as long as it still names this zone.
+		networkId := d.Get("network_id").(string)
+		if name, err := dnshelper.ReverseZoneNameFromNetworkId(networkId); err != nil || !strings.EqualFold(name, zone.ZoneName) {
+			networkId, _ = dnshelper.NetworkIdFromReverseZoneName(zone.ZoneName)
+		}
+		_ = d.Set("network_id", networkId)
+	}
+
+	return nil
+}
+
+func resourceDNSZoneUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	zone, err := dnshelper.NewDNSZoneFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+	keys := []string{"replication_scope", "dynamic_update"}
+	changes := make(map[string]interface{})
+	for _, key := range keys {
+		if d.HasChange(key) {
+			changes[key] = d.Get(key)
+		}
+	}
+
+	err = zone.Update(ctx, meta.(*config.ProviderConf), changes)
+	if err != nil {
+		return errorDiagnostics(err, "error while updating zone with id %q: %s", d.Id(), err)
+	}
+	return resourceDNSZoneRead(ctx, d, meta)
+}
+
+func resourceDNSZoneDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Id() == "" {
+		return nil
+	}
+	zone, err := dnshelper.NewDNSZoneFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	err = zone.Delete(ctx, meta.(*config.ProviderConf))
+	if err != nil {
+		return errorDiagnostics(err, "error while deleting a zone object with id %q: %s", d.Id(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+	"golang.org/x/exp/slices"
+)
+
+func resourceDNSZoneDelegation() *schema.Resource {
+	return &schema.Resource{
+		Description: "`windns_zone_delegation` delegates a child zone to other name servers in a Windows DNS Server. The id is `<name>_<zone_name>`.",
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		ReadContext:   resourceDNSZoneDelegationRead,
+		CreateContext: resourceDNSZoneDelegationCreate,
+		UpdateContext: resourceDNSZoneDelegationUpdate,
+		DeleteContext: resourceDNSZoneDelegationDelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"zone_name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressCaseDiff,
+				Description:      "The zone the child zone is delegated from.",
+			},
+			"name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressCaseDiff,
+				Description:      "The name of the delegated child zone, relative to the zone, e.g. `team` to delegate `team.example.com` from `example.com`.",
+			},
+			"name_server": {
+				Type:        schema.TypeSet,
+				Required:    true,
+				MinItems:    1,
+				Set:         hashDelegationNameServer,
+				Description: "A name server of the child zone.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:             schema.TypeString,
+							Required:         true,
+							DiffSuppressFunc: suppressDomainNameDiff,
+							Description:      "The name of the name server, e.g. `ns1.team.example.com`.",
+						},
+						"ip_addresses": {
+							Type:     schema.TypeSet,
+							Optional: true,
+							Set:      hashIPAddress,
+							Elem: &schema.Schema{
+								Type:             schema.TypeString,
+								ValidateFunc:     validation.IsIPAddress,
+								DiffSuppressFunc: suppressIPAddressDiff,
+							},
+							Description: "The glue IPv4 and IPv6 addresses of the name server. They are needed when the name server is within the child zone.",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// hashDelegationNameServer hashes a name server the way the DNS server compares them, so a name server read back
+// with a trailing dot, or with IPv6 addresses written differently, is the same element of the set.
+func hashDelegationNameServer(v interface{}) int {
+	m := v.(map[string]interface{})
+	var addresses []string
+	if ips, ok := m["ip_addresses"].(*schema.Set); ok {
+		for _, ip := range ips.List() {
+			addresses = append(addresses, dnshelper.IPAddressKey(ip.(string)))
+		}
+	}
+	slices.Sort(addresses)
+	return schema.HashString(fmt.Sprintf("%s %s", dnshelper.NameServerKey(m["name"].(string)), strings.Join(addresses, ",")))
+}
+
+func hashIPAddress(v interface{}) int {
+	return schema.HashString(dnshelper.IPAddressKey(v.(string)))
+}
+
+// Get-DnsServerZoneDelegation returns IPv6 addresses in their canonical form, e.g. lower case.
+func suppressIPAddressDiff(key, old, new string, d *schema.ResourceData) bool {
+	return old != "" && new != "" && dnshelper.IPAddressKey(old) == dnshelper.IPAddressKey(new)
+}
+
+func resourceDNSZoneDelegationCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	delegation, err := dnshelper.NewZoneDelegationFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	id, err := delegation.Create(ctx, meta.(*config.ProviderConf))
+	if err != nil {
+		return errorDiagnostics(err, "error while creating new zone delegation object: %s", err)
+	}
+	d.SetId(id)
+
+	return resourceDNSZoneDelegationRead(ctx, d, meta)
+}
+
+func resourceDNSZoneDelegationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Id() == "" {
+		return nil
+	}
+
+	delegation, err := dnshelper.GetZoneDelegationFromId(ctx, meta.(*config.ProviderConf), d.Id())
+	if err != nil {
+		if errors.Is(err, dnshelper.ErrNotFound) {
+			// Resource no longer exists
+			d.SetId("")
+			return nil
+		}
+		return diag.Errorf("error while reading zone delegation with id %q: %s", d.Id(), err)
+	}
+
+	var nameServers []interface{}
+	for _, ns := range delegation.NameServers {
+		var addresses []interface{}
+		for _, ip := range ns.IPAddresses {
+			addresses = append(addresses, ip)
+		}
+		nameServers = append(nameServers, map[string]interface{}{
+			"name":         ns.Name,
+			"ip_addresses": addresses,
+		})
+	}
+
+	_ = d.Set("zone_name", delegation.ZoneName)
+	_ = d.Set("name", delegation.ChildZoneName)
+	_ = d.Set("name_server", nameServers)
+
+	return nil
+}
+
+func resourceDNSZoneDelegationUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	delegation, err := dnshelper.NewZoneDelegationFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	conf := meta.(*config.ProviderConf)
+	existing, err := dnshelper.GetZoneDelegationFromId(ctx, conf, d.Id())
+	if err != nil {
+		return diag.Errorf("error while reading zone delegation with id %q: %s", d.Id(), err)
+	}
+
+	err = delegation.Update(ctx, conf, existing)
+	if err != nil {
+		// Some of the changes may have been made, so the state is refreshed from the DNS server
+		diags := errorDiagnostics(err, "error while updating zone delegation with id %q: %s", d.Id(), err)
+		return append(diags, resourceDNSZoneDelegationRead(ctx, d, meta)...)
+	}
+	return resourceDNSZoneDelegationRead(ctx, d, meta)
+}
+
+func resourceDNSZoneDelegationDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Id() == "" {
+		return nil
+	}
+	delegation, err := dnshelper.NewZoneDelegationFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	err = delegation.Delete(ctx, meta.(*config.ProviderConf))
+	if err != nil {
+		return errorDiagnostics(err, "error while deleting a zone delegation object with id %q: %s", d.Id(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+const testAccResourceDNSZoneDelegationConfigBasic = `
+resource "windns_zone_delegation" "d1" {
+  zone_name = "example.com"
+  name      = "tf-acc-delegation"
+
+  name_server {
+    name         = "ns1.tf-acc-delegation.example.com"
+    ip_addresses = ["203.0.113.53", "2001:DB8::53"]
+  }
+
+  name_server {
+    name = "ns.example.net."
+  }
+}
+`
+
+const testAccResourceDNSZoneDelegationConfigUpdated = `
+resource "windns_zone_delegation" "d1" {
+  zone_name = "example.com"
+  name      = "tf-acc-delegation"
+
+  name_server {
+    name         = "ns1.tf-acc-delegation.example.com"
+    ip_addresses = ["203.0.113.54"]
+  }
+
+  name_server {
+    name = "ns2.example.net"
+  }
+}
+`
+
+func TestAccResourceDNSZoneDelegation_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSZoneDelegationExists("windns_zone_delegation.d1", nil, false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSZoneDelegationConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneDelegationExists("windns_zone_delegation.d1", []dnshelper.DelegationNameServer{
+						{Name: "ns.example.net."},
+						{Name: "ns1.tf-acc-delegation.example.com.", IPAddresses: []string{"203.0.113.53", "2001:db8::53"}},
+					}, true),
+					resource.TestCheckResourceAttr("windns_zone_delegation.d1", "id", "tf-acc-delegation_example.com"),
+					resource.TestCheckResourceAttr("windns_zone_delegation.d1", "name_server.#", "2"),
+				),
+			},
+			{
+				Config: testAccResourceDNSZoneDelegationConfigUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneDelegationExists("windns_zone_delegation.d1", []dnshelper.DelegationNameServer{
+						{Name: "ns1.tf-acc-delegation.example.com.", IPAddresses: []string{"203.0.113.54"}},
+						{Name: "ns2.example.net."},
+					}, true),
+				),
+			},
+			{
+				ResourceName:      "windns_zone_delegation.d1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testAccResourceDNSZoneDelegationExists(resource string, expectedNameServers []dnshelper.DelegationNameServer, expected bool) resource.TestCheckFunc {
+	ctx := context.Background()
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[resource]
+		if !ok {
+			return fmt.Errorf("%s key not found in state", resource)
+		}
+
+		delegation, err := dnshelper.GetZoneDelegationFromId(ctx, testAccProvider.Meta().(*config.ProviderConf), rs.Primary.ID)
+		if err != nil {
+			if errors.Is(err, dnshelper.ErrNotFound) && !expected {
+				return nil
+			}
+			return err
+		}
+
+		if !expected {
+			return fmt.Errorf("zone delegation %s still exists", rs.Primary.ID)
+		}
+		if fmt.Sprint(delegation.NameServers) != fmt.Sprint(expectedNameServers) {
+			return fmt.Errorf("zone delegation %s has name servers %v, expected %v", rs.Primary.ID, delegation.NameServers, expectedNameServers)
+		}
+		return nil
+	}
+}
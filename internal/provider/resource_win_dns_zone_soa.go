@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+func resourceDNSZoneSOA() *schema.Resource {
+	return &schema.Resource{
+		Description: "`windns_zone_soa` manages the SOA record of a zone in a Windows DNS Server. The zone must already exist, " +
+			"and destroying the resource leaves the SOA record as it is. The id is the zone name.",
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+		ReadContext:   resourceDNSZoneSOARead,
+		CreateContext: resourceDNSZoneSOACreate,
+		UpdateContext: resourceDNSZoneSOAUpdate,
+		DeleteContext: resourceDNSZoneSOADelete,
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(5 * time.Minute),
+			Read:   schema.DefaultTimeout(5 * time.Minute),
+			Update: schema.DefaultTimeout(5 * time.Minute),
+			Delete: schema.DefaultTimeout(5 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"zone_name": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressCaseDiff,
+				Description:      "The name of the zone.",
+			},
+			"primary_server": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				DiffSuppressFunc: suppressDomainNameDiff,
+				Description:      "The primary name server of the zone, e.g. `ns1.example.com`.",
+			},
+			"responsible_person": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				DiffSuppressFunc: suppressDomainNameDiff,
+				Description:      "The mailbox of the person responsible for the zone, with the `@` written as a dot, e.g. `hostmaster.example.com`.",
+			},
+			"refresh_interval": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "How often secondary servers check for changes to the zone, in seconds.",
+			},
+			"retry_delay": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "How long secondary servers wait before retrying a failed refresh, in seconds.",
+			},
+			"expire_limit": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "How long secondary servers keep answering for the zone when they can't refresh it, in seconds.",
+			},
+			"minimum_ttl": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Computed:     true,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "The TTL of negative answers from the zone, in seconds.",
+			},
+			"serial_number": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The serial number of the zone. The DNS server increments it for every change to the zone, so it isn't managed by Terraform.",
+			},
+		},
+	}
+}
+
+// soaKeys are the attributes of the SOA record that can be changed
+var soaKeys = []string{"primary_server", "responsible_person", "refresh_interval", "retry_delay", "expire_limit", "minimum_ttl"}
+
+func resourceDNSZoneSOACreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	soa, err := dnshelper.NewZoneSOAFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+
+	// The SOA record is created with the zone, so only the fields that are set in the configuration are changed.
+	// The raw configuration is checked, since d.GetOk doesn't tell a zero minimum_ttl from an unset one.
+	changes := make(map[string]interface{})
+	rawConfig := d.GetRawConfig()
+	for _, key := range soaKeys {
+		if !rawConfig.GetAttr(key).IsNull() {
+			changes[key] = d.Get(key)
+		}
+	}
+
+	err = soa.Update(ctx, meta.(*config.ProviderConf), changes)
+	if err != nil {
+		return errorDiagnostics(err, "error while setting the SOA record of zone %q: %s", soa.ZoneName, err)
+	}
+	d.SetId(soa.Id())
+
+	return resourceDNSZoneSOARead(ctx, d, meta)
+}
+
+func resourceDNSZoneSOARead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	if d.Id() == "" {
+		return nil
+	}
+
+	soa, err := dnshelper.GetZoneSOAFromId(ctx, meta.(*config.ProviderConf), d.Id())
+	if err != nil {
+		if errors.Is(err, dnshelper.ErrNotFound) {
+			// The zone no longer exists
+			d.SetId("")
+			return nil
+		}
+		return diag.Errorf("error while reading the SOA record of zone %q: %s", d.Id(), err)
+	}
+
+	_ = d.Set("zone_name", soa.ZoneName)
+	_ = d.Set("primary_server", soa.PrimaryServer)
+	_ = d.Set("responsible_person", soa.ResponsiblePerson)
+	_ = d.Set("refresh_interval", soa.RefreshInterval)
+	_ = d.Set("retry_delay", soa.RetryDelay)
+	_ = d.Set("expire_limit", soa.ExpireLimit)
+	_ = d.Set("minimum_ttl", soa.MinimumTTL)
+	_ = d.Set("serial_number", soa.SerialNumber)
+
+	return nil
+}
+
+func resourceDNSZoneSOAUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	soa, err := dnshelper.NewZoneSOAFromResource(d)
+	if err != nil {
+		return diag.Errorf("error when mapping input data: %s", err)
+	}
+	changes := make(map[string]interface{})
+	for _, key := range soaKeys {
+		if d.HasChange(key) {
+			changes[key] = d.Get(key)
+		}
+	}
+
+	err = soa.Update(ctx, meta.(*config.ProviderConf), changes)
+	if err != nil {
+		return errorDiagnostics(err, "error while updating the SOA record of zone %q: %s", d.Id(), err)
+	}
+	return resourceDNSZoneSOARead(ctx, d, meta)
+}
+
+// resourceDNSZoneSOADelete only removes the resource from the state, since a zone can't be without its SOA record
+func resourceDNSZoneSOADelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	return nil
+}
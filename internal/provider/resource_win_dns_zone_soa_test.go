@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+const testAccResourceDNSZoneSOAConfigBasic = `
+resource "windns_zone" "z1" {
+  name              = "tf-acc-soa.example.com"
+  replication_scope = "Domain"
+}
+
+resource "windns_zone_soa" "s1" {
+  zone_name          = windns_zone.z1.name
+  responsible_person = "hostmaster.example.com"
+  refresh_interval   = 3600
+  retry_delay        = 600
+  minimum_ttl        = 0
+}
+`
+
+const testAccResourceDNSZoneSOAConfigUpdated = `
+resource "windns_zone" "z1" {
+  name              = "tf-acc-soa.example.com"
+  replication_scope = "Domain"
+}
+
+resource "windns_zone_soa" "s1" {
+  zone_name          = windns_zone.z1.name
+  responsible_person = "dns.example.com."
+  refresh_interval   = 3600
+  retry_delay        = 900
+  expire_limit       = 1209600
+  minimum_ttl        = 300
+}
+
+resource "windns_record" "r1" {
+  zone_name = windns_zone.z1.name
+  name      = "www"
+  type      = "A"
+  records   = ["203.0.113.11"]
+
+  depends_on = [windns_zone_soa.s1]
+}
+`
+
+func TestAccResourceDNSZoneSOA_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSZoneSOAConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneSOAExists("windns_zone_soa.s1", "hostmaster.example.com.", 3600, 600),
+					resource.TestCheckResourceAttr("windns_zone_soa.s1", "id", "tf-acc-soa.example.com"),
+					resource.TestCheckResourceAttrSet("windns_zone_soa.s1", "primary_server"),
+					resource.TestCheckResourceAttrSet("windns_zone_soa.s1", "expire_limit"),
+					resource.TestCheckResourceAttr("windns_zone_soa.s1", "minimum_ttl", "0"),
+					resource.TestCheckResourceAttrSet("windns_zone_soa.s1", "serial_number"),
+				),
+			},
+			{
+				// Adding a record changes the serial number of the zone, which must not cause a diff
+				Config: testAccResourceDNSZoneSOAConfigUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneSOAExists("windns_zone_soa.s1", "dns.example.com.", 3600, 900),
+					resource.TestCheckResourceAttr("windns_zone_soa.s1", "expire_limit", "1209600"),
+					resource.TestCheckResourceAttr("windns_zone_soa.s1", "minimum_ttl", "300"),
+				),
+			},
+			{
+				ResourceName:            "windns_zone_soa.s1",
+				ImportState:             true,
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"serial_number"},
+			},
+		},
+	})
+}
+
+func testAccResourceDNSZoneSOAExists(resource, responsiblePerson string, refreshInterval, retryDelay int64) resource.TestCheckFunc {
+	ctx := context.Background()
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[resource]
+		if !ok {
+			return fmt.Errorf("%s key not found in state", resource)
+		}
+
+		soa, err := dnshelper.GetZoneSOAFromId(ctx, testAccProvider.Meta().(*config.ProviderConf), rs.Primary.ID)
+		if err != nil {
+			return err
+		}
+		if soa.ResponsiblePerson != responsiblePerson || soa.RefreshInterval != refreshInterval || soa.RetryDelay != retryDelay {
+			return fmt.Errorf("SOA record of zone %s is %s, expected responsible person %s, refresh %d and retry %d",
+				rs.Primary.ID, soa, responsiblePerson, refreshInterval, retryDelay)
+		}
+		return nil
+	}
+}
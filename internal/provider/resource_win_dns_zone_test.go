@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: MIT
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/nrkno/terraform-provider-windns/internal/config"
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
+
+const testAccResourceDNSZoneConfigBasic = `
+resource "windns_zone" "z1" {
+  name              = "tf-acc-zone.example.com"
+  replication_scope = "Domain"
+  dynamic_update    = "Secure"
+}
+`
+
+const testAccResourceDNSZoneConfigBasicUpdated = `
+resource "windns_zone" "z1" {
+  name              = "tf-acc-zone.example.com"
+  replication_scope = "Forest"
+  dynamic_update    = "None"
+}
+`
+
+const testAccResourceDNSZoneConfigZoneFile = `
+resource "windns_zone" "z1" {
+  name      = "tf-acc-file-zone.example.com"
+  zone_file = "tf-acc-file-zone.example.com.dns"
+}
+`
+
+const testAccResourceDNSZoneConfigReverse = `
+resource "windns_zone" "z1" {
+  network_id        = "10.123.0.0/16"
+  replication_scope = "Domain"
+}
+`
+
+func TestAccResourceDNSZone_Basic(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSZoneExists("windns_zone.z1", false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSZoneConfigBasic,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneExists("windns_zone.z1", true),
+					resource.TestCheckResourceAttr("windns_zone.z1", "replication_scope", "Domain"),
+					resource.TestCheckResourceAttr("windns_zone.z1", "dynamic_update", "Secure"),
+				),
+			},
+			{
+				Config: testAccResourceDNSZoneConfigBasicUpdated,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneExists("windns_zone.z1", true),
+					resource.TestCheckResourceAttr("windns_zone.z1", "replication_scope", "Forest"),
+					resource.TestCheckResourceAttr("windns_zone.z1", "dynamic_update", "None"),
+				),
+			},
+			{
+				ResourceName:      "windns_zone.z1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSZone_ZoneFile(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSZoneExists("windns_zone.z1", false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSZoneConfigZoneFile,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneExists("windns_zone.z1", true),
+					resource.TestCheckResourceAttr("windns_zone.z1", "zone_file", "tf-acc-file-zone.example.com.dns"),
+				),
+			},
+			{
+				ResourceName:      "windns_zone.z1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func TestAccResourceDNSZone_Reverse(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		PreCheck:          func() { testAccPreCheck(t, []string{}) },
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccResourceDNSZoneExists("windns_zone.z1", false),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccResourceDNSZoneConfigReverse,
+				Check: resource.ComposeTestCheckFunc(
+					testAccResourceDNSZoneExists("windns_zone.z1", true),
+					resource.TestCheckResourceAttr("windns_zone.z1", "name", "123.10.in-addr.arpa"),
+					resource.TestCheckResourceAttr("windns_zone.z1", "network_id", "10.123.0.0/16"),
+					resource.TestCheckResourceAttr("windns_zone.z1", "is_reverse_lookup_zone", "true"),
+				),
+			},
+			{
+				ResourceName:      "windns_zone.z1",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
+}
+
+func testAccResourceDNSZoneExists(resource string, expected bool) resource.TestCheckFunc {
+	ctx := context.Background()
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[resource]
+		if !ok {
+			return fmt.Errorf("%s key not found in state", resource)
+		}
+
+		_, err := dnshelper.GetDNSZoneFromId(ctx, testAccProvider.Meta().(*config.ProviderConf), rs.Primary.ID)
+		if err != nil {
+			if errors.Is(err, dnshelper.ErrNotFound) && !expected {
+				return nil
+			}
+			return err
+		}
+
+		if !expected {
+			return fmt.Errorf("zone %s still exists", rs.Primary.ID)
+		}
+		return nil
+	}
+}
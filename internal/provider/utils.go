@@ -3,9 +3,12 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
 	"golang.org/x/exp/slices"
@@ -17,6 +20,11 @@ func suppressCaseDiff(key, old, new string, d *schema.ResourceData) bool {
 	return strings.EqualFold(old, new)
 }
 
+// The DNS server returns domain names like name servers with a trailing dot, which users usually leave out.
+func suppressDomainNameDiff(key, old, new string, d *schema.ResourceData) bool {
+	return dnshelper.NameServerKey(old) == dnshelper.NameServerKey(new)
+}
+
 func suppressRecordDiff(key, old, new string, d *schema.ResourceData) bool {
 	// For a list, the key is path to the element, rather than the list.
 	// E.g. "windns_record.2.records.0"
@@ -43,10 +51,15 @@ func suppressRecordDiff(key, old, new string, d *schema.ResourceData) bool {
 }
 
 func suppressRecordDiffForType(oldRecords, newRecords []string, rrType string) bool {
+	if rrType == dnshelper.RecordTypeCAA {
+		return suppressCAADiff(oldRecords, newRecords)
+	}
+
 	slices.Sort(oldRecords)
 	slices.Sort(newRecords)
 
-	if rrType == dnshelper.RecordTypePTR || rrType == dnshelper.RecordTypeCNAME {
+	if rrType == dnshelper.RecordTypePTR || rrType == dnshelper.RecordTypeCNAME || rrType == dnshelper.RecordTypeMX || rrType == dnshelper.RecordTypeSRV ||
+		rrType == dnshelper.RecordTypeNS {
 		return suppressDotDiff(oldRecords, newRecords)
 	}
 	return suppressListCaseDiff(oldRecords, newRecords)
@@ -60,7 +73,7 @@ func suppressListCaseDiff(oldRecords, newRecords []string) bool {
 	})
 }
 
-// Get-DNSResourceRecord always adds a `.` after the PTR and CNAME record types.
+// Get-DNSResourceRecord always adds a `.` after the PTR, CNAME and NS record types, and after the MX exchange and SRV target.
 // To avoid change if the user did not add it, we need to add it before we compare.
 func suppressDotDiff(oldRecords, newRecords []string) bool {
 	var newRecordsWithDot []string
@@ -75,6 +88,25 @@ func suppressDotDiff(oldRecords, newRecords []string) bool {
 	return slices.Equal(oldRecords, newRecordsWithDot)
 }
 
+// Get-DNSResourceRecord returns CAA records with the tag in lower case and the value in quotes.
+// To avoid change if the user wrote them differently, we normalize them before we compare.
+func suppressCAADiff(oldRecords, newRecords []string) bool {
+	normalize := func(records []string) []string {
+		var normalized []string
+		for _, v := range records {
+			normalized = append(normalized, dnshelper.NormalizeCAARecordData(v))
+		}
+		slices.Sort(normalized)
+		return normalized
+	}
+	return slices.Equal(normalize(oldRecords), normalize(newRecords))
+}
+
+// isConfigured returns true if an optional block or list is set in the configuration, or may be once it is known.
+func isConfigured(v cty.Value) bool {
+	return !v.IsKnown() || (!v.IsNull() && v.LengthInt() > 0)
+}
+
 func setToStringSlice(d *schema.Set) []string {
 	var data []string
 	for _, v := range d.List() {
@@ -82,3 +114,25 @@ func setToStringSlice(d *schema.Set) []string {
 	}
 	return data
 }
+
+// errorDiagnostics returns an error diagnostic for a failed change, explaining the common failures of the DNS server
+// in the detail.
+func errorDiagnostics(err error, format string, a ...any) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  fmt.Sprintf(format, a...),
+		Detail:   errorDetail(err),
+	}}
+}
+
+func errorDetail(err error) string {
+	switch {
+	case errors.Is(err, dnshelper.ErrAccessDenied):
+		return "The user running PowerShell isn't allowed to change the DNS server. It needs to be a member of DnsAdmins, or be granted access to the zone."
+	case errors.Is(err, dnshelper.ErrZoneNotFound):
+		return "The zone doesn't exist on the DNS server."
+	case errors.Is(err, dnshelper.ErrAlreadyExists):
+		return "It already exists on the DNS server. Use terraform import to manage it with Terraform."
+	}
+	return ""
+}
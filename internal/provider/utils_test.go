@@ -2,7 +2,14 @@
 
 package provider
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nrkno/terraform-provider-windns/internal/dnshelper"
+)
 
 func Test_suppressRecordDiffForType(t *testing.T) {
 	tests := []struct {
@@ -49,6 +56,31 @@ func Test_suppressRecordDiffForType(t *testing.T) {
 		{
 			"test-dot-ptr", "PTR", []string{"example-host.example.com."}, []string{"example-host.example.com"}, true,
 		},
+		// rrType NS test cases
+		{
+			"test-dot-ns", "NS", []string{"ns1.example.com.", "ns2.example.net."}, []string{"ns2.example.net", "ns1.example.com"}, true,
+		},
+		// rrType MX test cases
+		{
+			"test-dot-mx", "MX", []string{"10 mail.example.com."}, []string{"10 mail.example.com"}, true,
+		},
+		{
+			"test-preference-mx", "MX", []string{"10 mail.example.com."}, []string{"20 mail.example.com"}, false,
+		},
+		// rrType SRV test cases
+		{
+			"test-dot-srv", "SRV", []string{"0 5 88 dc1.example.com."}, []string{"0 5 88 dc1.example.com"}, true,
+		},
+		{
+			"test-port-srv", "SRV", []string{"0 5 88 dc1.example.com."}, []string{"0 5 389 dc1.example.com"}, false,
+		},
+		// rrType CAA test cases
+		{
+			"test-quotes-caa", "CAA", []string{`0 issue "ca.example.net"`, `0 issuewild ";"`}, []string{"0 ISSUEWILD ;", "0 issue ca.example.net"}, true,
+		},
+		{
+			"test-value-caa", "CAA", []string{`0 issue "ca.example.net"`}, []string{`0 issue "ca.example.org"`}, false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -59,3 +91,24 @@ func Test_suppressRecordDiffForType(t *testing.T) {
 		})
 	}
 }
+
+func Test_errorDetail(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"access-denied", &dnshelper.PowerShellError{Category: "PermissionDenied"}, "DnsAdmins"},
+		{"zone-not-found", fmt.Errorf("Add-DnsServerResourceRecord failed: %w", &dnshelper.PowerShellError{Win32ErrorCode: 9601}), "zone doesn't exist"},
+		{"already-exists", &dnshelper.PowerShellError{Win32ErrorCode: 9609}, "terraform import"},
+		{"other", errors.New("run error: EOF"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorDetail(tt.err)
+			if (tt.want == "" && got != "") || !strings.Contains(got, tt.want) {
+				t.Errorf("errorDetail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
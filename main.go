@@ -26,4 +26,7 @@ func main() {
 	}
 
 	plugin.Serve(opts)
+
+	// Serve returns when Terraform shuts the plugin down
+	provider.Close()
 }